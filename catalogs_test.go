@@ -3,9 +3,14 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/database"
+	"github.com/italia/developers-italia-api/internal/handlers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -23,6 +28,10 @@ const (
 	italiaPublisherID = "2ded32eb-c45e-4167-9166-a44e18b8adde" // catalog_id = italiaID
 	swissPublisherID  = "47807e0c-0613-4aea-9917-5455cc6eddad" // catalog_id = swissID
 	rootPublisherID   = "d6ddc11a-ff85-4f0f-bb87-df38b2a9b394" // catalog_id IS NULL (root)
+
+	// Catalog in the trash, with a publisher in the trash.
+	trashedCatalogID   = "c0a7a8f9-2d3e-4f4c-8a5f-1e6d7c8b9a0f"
+	trashedPublisherID = "4d2e9f6b-8c3a-4f7a-b1e5-6a9d3c0f2b84"
 )
 
 func TestCatalogEndpoints(t *testing.T) {
@@ -430,7 +439,7 @@ func TestCatalogEndpoints(t *testing.T) {
 		// POST /catalogs/:id/software
 		{
 			description: "POST catalog software",
-			query:       "POST /v1/catalogs/" + italiaID + "/software",
+			query:       "POST /v1/catalogs/" + italiaID + "/software?validation=off",
 			body:        `{"url": "https://example.org/new-sw", "publiccodeYml": "-"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -445,7 +454,7 @@ func TestCatalogEndpoints(t *testing.T) {
 		},
 		{
 			description: "POST catalog software by alternativeId",
-			query:       "POST /v1/catalogs/italia/software",
+			query:       "POST /v1/catalogs/italia/software?validation=off",
 			body:        `{"url": "https://example.org/new-sw-2", "publiccodeYml": "-"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -459,7 +468,7 @@ func TestCatalogEndpoints(t *testing.T) {
 		},
 		{
 			description: "POST catalog software - root catalog (∅)",
-			query:       "POST /v1/catalogs/%E2%88%85/software",
+			query:       "POST /v1/catalogs/%E2%88%85/software?validation=off",
 			body:        `{"url": "https://example.org/root-sw", "publiccodeYml": "-"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -495,7 +504,7 @@ func TestCatalogEndpoints(t *testing.T) {
 		// PATCH /catalogs/:id/software/:softwareId
 		{
 			description: "PATCH catalog software",
-			query:       "PATCH /v1/catalogs/" + italiaID + "/software/" + italiaSoftwareID,
+			query:       "PATCH /v1/catalogs/" + italiaID + "/software/" + italiaSoftwareID + "?validation=off",
 			body:        `{"publiccodeYml": "updated"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -510,7 +519,7 @@ func TestCatalogEndpoints(t *testing.T) {
 		},
 		{
 			description: "PATCH catalog software by alternativeId",
-			query:       "PATCH /v1/catalogs/italia/software/" + italiaSoftwareID,
+			query:       "PATCH /v1/catalogs/italia/software/" + italiaSoftwareID + "?validation=off",
 			body:        `{"publiccodeYml": "updated-via-alt"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -522,9 +531,25 @@ func TestCatalogEndpoints(t *testing.T) {
 				assert.Equal(t, italiaSoftwareID, response["id"])
 			},
 		},
+		{
+			description: "PATCH catalog software with JSON Patch - add vitality",
+			query:       "PATCH /v1/catalogs/" + italiaID + "/software/" + italiaSoftwareID + "?validation=off",
+			body:        `[{"op": "add", "path": "/vitality", "value": 99}]`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json-patch+json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				// The vitality is computed from its data points
+				assert.Nil(t, response["vitality"])
+				assert.Equal(t, 0, dbCount(t, "software", "vitality_score IS NOT NULL AND id", italiaSoftwareID))
+			},
+		},
 		{
 			description: "PATCH catalog software - wrong catalog returns 404",
-			query:       "PATCH /v1/catalogs/" + swissID + "/software/" + italiaSoftwareID,
+			query:       "PATCH /v1/catalogs/" + swissID + "/software/" + italiaSoftwareID + "?validation=off",
 			body:        `{"publiccodeYml": "x"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -536,7 +561,7 @@ func TestCatalogEndpoints(t *testing.T) {
 		},
 		{
 			description: "PATCH catalog software - root catalog (∅)",
-			query:       "PATCH /v1/catalogs/%E2%88%85/software/" + rootSoftwareID,
+			query:       "PATCH /v1/catalogs/%E2%88%85/software/" + rootSoftwareID + "?validation=off",
 			body:        `{"publiccodeYml": "updated-root"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -550,7 +575,7 @@ func TestCatalogEndpoints(t *testing.T) {
 		},
 		{
 			description: "PATCH catalog software - catalog-scoped software rejected for root catalog",
-			query:       "PATCH /v1/catalogs/%E2%88%85/software/" + italiaSoftwareID,
+			query:       "PATCH /v1/catalogs/%E2%88%85/software/" + italiaSoftwareID + "?validation=off",
 			body:        `{"publiccodeYml": "x"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -594,6 +619,50 @@ func TestCatalogEndpoints(t *testing.T) {
 				assert.Equal(t, "c353756e-8597-4e46-a99b-7da2e141603b", data[0]["id"])
 			},
 		},
+		{
+			description:         "GET catalog software filtered by category",
+			query:               "GET /v1/catalogs/" + italiaID + "/software?category=cms",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				assert.Equal(t, 1, len(data))
+				assert.Equal(t, "c353756e-8597-4e46-a99b-7da2e141603b", data[0]["id"])
+			},
+		},
+		{
+			description:         "GET catalog software filtered by category of software in other catalogs",
+			query:               "GET /v1/catalogs/" + italiaID + "/software?category=crm",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				assert.Equal(t, 0, len(data))
+			},
+		},
+		{
+			description:         "GET catalog software facets",
+			query:               "GET /v1/catalogs/" + italiaID + "/software/facets?facets=category,catalog",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{"value": "cms", "count": 1.0},
+				}, response["category"])
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{"value": italiaID, "count": 1.0},
+				}, response["catalog"])
+			},
+		},
+		{
+			description:         "GET software facets of non-existent catalog",
+			query:               "GET /v1/catalogs/nonexistent/software/facets",
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Software facets","detail":"Catalog was not found","status":404}`,
+		},
 		{
 			description:         "GET catalog software filtered by url",
 			query:               "GET /v1/catalogs/" + italiaID + "/software?url=https://1-a.example.org/code/repo",
@@ -658,7 +727,7 @@ func TestCatalogSoftwareDBChecks(t *testing.T) {
 
 		req, err := newTestRequest(
 			"POST",
-			"/v1/catalogs/"+italiaID+"/software",
+			"/v1/catalogs/"+italiaID+"/software?validation=off",
 			strings.NewReader(`{"url":"https://example.org/cat-sw","publiccodeYml":"-"}`),
 		)
 		require.NoError(t, err)
@@ -683,7 +752,7 @@ func TestCatalogSoftwareDBChecks(t *testing.T) {
 
 		req, err := newTestRequest(
 			"PATCH",
-			fmt.Sprintf("/v1/catalogs/%s/software/%s", italiaID, italiaSoftwareID),
+			fmt.Sprintf("/v1/catalogs/%s/software/%s?validation=off", italiaID, italiaSoftwareID),
 			strings.NewReader(`{"publiccodeYml":"patched-yml"}`),
 		)
 		require.NoError(t, err)
@@ -700,44 +769,1713 @@ func TestCatalogSoftwareDBChecks(t *testing.T) {
 	})
 }
 
-func TestCatalogDeleteDBChecks(t *testing.T) {
-	t.Run("DELETE catalog removes it and its sources from DB", func(t *testing.T) {
-		loadFixtures(t)
+func TestCatalogSoftwarePut(t *testing.T) {
+	tests := []TestCase{
+		{
+			description: "PUT catalog software creates it in the catalog",
+			query:       "PUT /v1/catalogs/" + swissID + "/software?url=https://upsert.example.org&validation=off",
+			body:        `{"url": "https://upsert.example.org", "publiccodeYml": "-"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        201,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, swissID, response["catalogId"])
+			},
+		},
+		{
+			description: "PUT catalog software updates software of the catalog",
+			query:       "PUT /v1/catalogs/" + italiaID + "/software?url=https://1-a.example.org/code/repo&validation=off",
+			body:        `{"url": "https://1-a.example.org/code/repo", "publiccodeYml": "name: Medusa"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, italiaSoftwareID, response["id"])
+				assert.Equal(t, italiaID, response["catalogId"])
+				assert.Empty(t, response["aliases"])
+			},
+		},
+		{
+			description: "PUT catalog software with the URL of software of another catalog",
+			query:       "PUT /v1/catalogs/" + swissID + "/software?url=https://1-a.example.org/code/repo&validation=off",
+			body:        `{"url": "https://1-a.example.org/code/repo", "publiccodeYml": "-"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        201,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.NotEqual(t, italiaSoftwareID, response["id"])
+				assert.Equal(t, swissID, response["catalogId"])
+				assert.Equal(t, "https://1-a.example.org/code/repo", response["url"])
 
-		body := `{"name": "To Delete", "sources": [{"url": "https://github.com/example/to-delete"}]}`
-		req, err := newTestRequest("POST", "/v1/catalogs", strings.NewReader(body))
-		require.NoError(t, err)
-		req.Header = map[string][]string{
-			"Authorization": {goodToken},
-			"Content-Type":  {"application/json"},
-		}
+				assert.Equal(t, 2, dbCount(t, "software_urls", "url", "https://1-a.example.org/code/repo"))
+			},
+		},
+		{
+			description: "PUT root catalog software with the URL of software of a catalog",
+			query:       "PUT /v1/catalogs/%E2%88%85/software?url=https://1-a.example.org/code/repo&validation=off",
+			body:        `{"url": "https://1-a.example.org/code/repo", "publiccodeYml": "-"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        201,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.NotEqual(t, italiaSoftwareID, response["id"])
+				assert.NotContains(t, response, "catalogId")
+			},
+		},
+		{
+			description: "PUT catalog software in a non-existent catalog",
+			query:       "PUT /v1/catalogs/NO_SUCH_CATALOG/software?url=https://upsert.example.org&validation=off",
+			body:        `{"url": "https://upsert.example.org", "publiccodeYml": "-"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create or update Software","detail":"Catalog was not found","status":404}`,
+		},
+		{
+			description: "POST catalog software with an existing URL",
+			query:       "POST /v1/catalogs/" + italiaID + "/software?validation=off",
+			body:        `{"url": "https://1-a.example.org/code/repo", "publiccodeYml": "-"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Software","detail":"url already exists","status":409}`,
+		},
+		{
+			description: "POST catalog software with the URL of software of another catalog",
+			query:       "POST /v1/catalogs/" + swissID + "/software?validation=off",
+			body:        `{"url": "https://1-a.example.org/code/repo", "publiccodeYml": "-"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, swissID, response["catalogId"])
+				assert.Equal(t, "https://1-a.example.org/code/repo", response["url"])
+			},
+		},
+	}
 
-		res, err := app.Test(req, -1)
-		require.NoError(t, err)
-		require.Equal(t, 200, res.StatusCode)
+	runTestCases(t, tests)
+}
 
-		var created map[string]interface{}
-		require.NoError(t, json.NewDecoder(res.Body).Decode(&created))
-		catalogID := created["id"].(string)
+func TestCatalogRuns(t *testing.T) {
+	const (
+		closedRunID  = "6f8d4a5b-0e1c-4d9f-8a3b-4c5d6e7f8a9b" // italiaID, closed
+		italiaRunID  = "3c5a1d2e-7b8f-4a6c-9d0e-1f2a3b4c5d6e" // italiaID, saw an alias of italiaSoftwareID
+		swissRunID   = "4d6b2e3f-8c9a-4b7d-8e1f-2a3b4c5d6e7f" // swissID, saw nothing
+		strictRunID  = "5e7c3f4a-9d0b-4c8e-9f2a-3b4c5d6e7f8a" // swissID, saw nothing, maxDeactivated 10
+		runsEndpoint = "/v1/catalogs/"
+	)
 
-		req, err = newTestRequest("DELETE", "/v1/catalogs/"+catalogID, nil)
-		require.NoError(t, err)
-		req.Header = map[string][]string{"Authorization": {goodToken}}
+	tests := []TestCase{
+		{
+			description:         "GET catalog runs",
+			query:               "GET " + runsEndpoint + italiaID + "/runs",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				runs := assertListResponse(t, response)
+				require.Len(t, runs, 2)
 
-		res, err = app.Test(req, -1)
-		require.NoError(t, err)
-		assert.Equal(t, 204, res.StatusCode)
+				assert.Equal(t, italiaRunID, runs[0]["id"])
+				assert.Equal(t, closedRunID, runs[1]["id"])
+			},
+		},
+		{
+			description:         "GET catalog run",
+			query:               "GET " + runsEndpoint + "italia/runs/" + closedRunID,
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, closedRunID, response["id"])
+				assert.Equal(t, italiaID, response["catalogId"])
+				assert.Equal(t, "closed", response["status"])
+				assert.Equal(t, 2.0, response["seen"])
+				assert.Equal(t, "2020-02-02T00:00:00Z", response["closedAt"])
 
-		assert.Equal(t, 0, dbCount(t, "catalogs", "id", catalogID))
-		assert.Equal(t, 0, dbCount(t, "catalog_sources", "catalog_id", catalogID))
-	})
+				assertOnlyKeys(t, response, "id", "catalogId", "status", "maxDeactivated", "seen", "deactivated", "closedAt", "createdAt", "updatedAt")
+			},
+		},
+		{
+			description:         "GET catalog run of another catalog",
+			query:               "GET " + runsEndpoint + swissID + "/runs/" + closedRunID,
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Run","detail":"Run was not found","status":404}`,
+		},
+		{
+			description: "POST catalog run",
+			query:       "POST " + runsEndpoint + italiaID + "/runs",
+			body:        `{}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assertUUID(t, response["id"])
+				assert.Equal(t, italiaID, response["catalogId"])
+				assert.Equal(t, "open", response["status"])
+				assert.Equal(t, 10.0, response["maxDeactivated"])
+				assert.Equal(t, 0.0, response["seen"])
+				assert.NotContains(t, response, "closedAt")
+			},
+		},
+		{
+			description: "POST root catalog run",
+			query:       "POST " + runsEndpoint + "%E2%88%85/runs",
+			body:        `{"maxDeactivated": 50}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.NotContains(t, response, "catalogId")
+				assert.Equal(t, 50.0, response["maxDeactivated"])
+			},
+		},
+		{
+			description: "POST catalog run with invalid maxDeactivated",
+			query:       "POST " + runsEndpoint + italiaID + "/runs",
+			body:        `{"maxDeactivated": 101}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "can't create Run", response["title"])
+			},
+		},
+		{
+			description: "POST run in a non-existent catalog",
+			query:       "POST " + runsEndpoint + "NO_SUCH_CATALOG/runs",
+			body:        `{}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Run","detail":"Catalog was not found","status":404}`,
+		},
+		{
+			description: "POST catalog run - wrong token",
+			query:       "POST " + runsEndpoint + italiaID + "/runs",
+			body:        `{}`,
+			headers: map[string][]string{
+				"Authorization": {badToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        401,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"token authentication failed","status":401}`,
+		},
+		{
+			description: "POST catalog run URLs",
+			query:       "POST " + runsEndpoint + italiaID + "/runs/" + italiaRunID + "/urls",
+			body:        `{"urls": ["https://www.1-b.example.org/code/repo/", "https://1-a.example.org/code/repo", "https://1-a.example.org/code/repo"]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "open", response["status"])
+				assert.Equal(t, 2.0, response["seen"])
+			},
+		},
+		{
+			description: "POST catalog run URLs to a closed run",
+			query:       "POST " + runsEndpoint + italiaID + "/runs/" + closedRunID + "/urls",
+			body:        `{"urls": ["https://1-a.example.org/code/repo"]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't add Run URLs","detail":"Run is not open","status":409}`,
+		},
+		{
+			description: "POST catalog run URLs with no URLs",
+			query:       "POST " + runsEndpoint + italiaID + "/runs/" + italiaRunID + "/urls",
+			body:        `{"urls": []}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "can't add Run URLs", response["title"])
+			},
+		},
+		{
+			description: "POST catalog run close as dry run",
+			query:       "POST " + runsEndpoint + swissID + "/runs/" + swissRunID + "/close?dryRun=true",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "closed", response["status"])
+				assert.Equal(t, 1.0, response["deactivated"])
+				assert.Equal(t, []interface{}{swissSoftwareID}, response["software"])
+
+				assert.Equal(t, "open", dbValue(t, "catalog_runs", "status", "id", swissRunID))
+				assert.Equal(t, 1, dbCount(t, "software", "id", swissSoftwareID))
+				assert.Equal(t, 0, dbCount(t, "revisions", "entity_id", swissSoftwareID))
+			},
+		},
+		{
+			description: "POST catalog run close over maxDeactivated as dry run",
+			query:       "POST " + runsEndpoint + swissID + "/runs/" + strictRunID + "/close?dryRun=true",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "aborted", response["status"])
+				assert.Equal(t, 0.0, response["deactivated"])
+				assert.Equal(t, []interface{}{swissSoftwareID}, response["software"])
+			},
+		},
+		{
+			description: "POST catalog run close over maxDeactivated",
+			query:       "POST " + runsEndpoint + swissID + "/runs/" + strictRunID + "/close",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't close Run","detail":"1 of the 1 active Software would be deactivated, more than maxDeactivated (10%)","status":409}`,
+		},
+		{
+			description: "POST catalog run close of a closed run",
+			query:       "POST " + runsEndpoint + italiaID + "/runs/" + closedRunID + "/close",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't close Run","detail":"Run is not open","status":409}`,
+		},
+	}
+
+	runTestCases(t, tests)
+
+	post := func(t *testing.T, path string, body string) map[string]interface{} {
+		t.Helper()
+
+		req, err := newTestRequest("POST", path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		var response map[string]interface{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+
+		return response
+	}
+
+	t.Run("POST catalog run close deactivates the software not seen", func(t *testing.T) {
+		loadFixtures(t)
+
+		run := post(t, runsEndpoint+swissID+"/runs/"+swissRunID+"/close", "")
+
+		assert.Equal(t, "closed", run["status"])
+		assert.NotEmpty(t, run["closedAt"])
+
+		assert.Equal(t, "closed", dbValue(t, "catalog_runs", "status", "id", swissRunID))
+		assert.Equal(t, "1", dbValue(t, "catalog_runs", "deactivated", "id", swissRunID))
+		assert.Equal(t, 0, dbCount(t, "software", "active = true AND id", swissSoftwareID))
+		assert.Equal(t, "update", dbValue(t, "revisions", "type", "entity_id", swissSoftwareID))
+		assert.Contains(t, dbValue(t, "revisions", "data", "entity_id", swissSoftwareID), `"active":false`)
+	})
+
+	t.Run("POST catalog run close keeps the software seen by alias", func(t *testing.T) {
+		loadFixtures(t)
+
+		run := post(t, runsEndpoint+italiaID+"/runs/"+italiaRunID+"/close", "")
+
+		assert.Equal(t, "closed", run["status"])
+		assert.Equal(t, 0.0, run["deactivated"])
+
+		assert.Equal(t, 1, dbCount(t, "software", "active = true AND id", italiaSoftwareID))
+		assert.Equal(t, 0, dbCount(t, "catalog_run_urls", "catalog_run_id", italiaRunID))
+	})
+
+	t.Run("POST catalog run close over maxDeactivated aborts it", func(t *testing.T) {
+		loadFixtures(t)
+
+		req, err := newTestRequest("POST", runsEndpoint+swissID+"/runs/"+strictRunID+"/close", nil)
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 409, res.StatusCode)
+
+		assert.Equal(t, "aborted", dbValue(t, "catalog_runs", "status", "id", strictRunID))
+		assert.Equal(t, 1, dbCount(t, "software", "active = true AND id", swissSoftwareID))
+	})
+
+	t.Run("POST catalog run close keeps the software created during the run", func(t *testing.T) {
+		loadFixtures(t)
+
+		run := post(t, runsEndpoint+"%E2%88%85/runs", `{"maxDeactivated": 100}`)
+		runID := run["id"].(string)
+
+		software := post(t, "/v1/catalogs/%E2%88%85/software?validation=off", `{"url": "https://new.example.org/repo", "publiccodeYml": "-"}`)
+		post(t, runsEndpoint+"%E2%88%85/runs/"+runID+"/urls", `{"urls": ["https://11-a.example.org/code/repo"]}`)
+
+		run = post(t, runsEndpoint+"%E2%88%85/runs/"+runID+"/close", "")
+
+		assert.Equal(t, "closed", run["status"])
+		assert.NotContains(t, run["software"], software["id"])
+		assert.NotContains(t, run["software"], dbValue(t, "software_urls", "software_id", "url", "https://11-a.example.org/code/repo"))
+		assert.Equal(t, 1, dbCount(t, "software", "active = true AND id", software["id"].(string)))
+		assert.Equal(t, 0, dbCount(t, "software", "active = true AND catalog_id IS NULL AND id", rootSoftwareID))
+	})
+}
+
+func TestCatalogDeleteDBChecks(t *testing.T) {
+	t.Run("DELETE catalog moves it to the trash, keeping its sources", func(t *testing.T) {
+		loadFixtures(t)
+
+		body := `{"name": "To Delete", "sources": [{"url": "https://github.com/example/to-delete"}]}`
+		req, err := newTestRequest("POST", "/v1/catalogs", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		var created map[string]interface{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+		catalogID := created["id"].(string)
+
+		req, err = newTestRequest("DELETE", "/v1/catalogs/"+catalogID, nil)
+		require.NoError(t, err)
+		req.Header = map[string][]string{"Authorization": {goodToken}}
+
+		res, err = app.Test(req, -1)
+		require.NoError(t, err)
+		assert.Equal(t, 204, res.StatusCode)
+
+		assert.Equal(t, 1, dbCount(t, "catalogs", "deleted_at IS NOT NULL AND id", catalogID))
+		assert.Equal(t, 1, dbCount(t, "catalog_sources", "catalog_id", catalogID))
+
+		// The revisions of the catalog are kept
+		assert.Equal(t, 2, dbCount(t, "revisions", "entity_id", catalogID))
+	})
+}
+
+func TestCatalogCascadeDelete(t *testing.T) {
+	tests := []TestCase{
+		{
+			description: "DELETE catalog with cascade",
+			query:       "DELETE /v1/catalogs/swiss?cascade=true",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, swissID, response["catalogId"])
+				assert.Equal(t, "done", response["status"])
+				assert.EqualValues(t, 1, response["software"])
+				assert.EqualValues(t, 2, response["softwareUrls"])
+				assert.EqualValues(t, 1, response["publishers"])
+				assert.EqualValues(t, 2, response["codeHosting"])
+				assert.EqualValues(t, 0, response["logs"])
+				assert.EqualValues(t, 3, response["webhooks"])
+
+				assert.Equal(t, 1, dbCount(t, "catalogs", "deleted_at IS NOT NULL AND id", swissID))
+				assert.Equal(t, 1, dbCount(t, "software", "deleted_at IS NOT NULL AND id", swissSoftwareID))
+				assert.Equal(t, 1, dbCount(t, "publishers", "deleted_at IS NOT NULL AND id", swissPublisherID))
+
+				// The URLs and the code hosting stay, to restore them
+				assert.Equal(t, 2, dbCount(t, "software_urls", "software_id", swissSoftwareID))
+				assert.Equal(t, 2, dbCount(t, "publishers_code_hosting", "publisher_id", swissPublisherID))
+
+				assert.Equal(t, 0, dbCount(t, "webhooks", "entity_id", swissSoftwareID))
+				assert.Equal(t, 0, dbCount(t, "webhook_headers", "webhook_id", "e7f6dbda-c3f5-4b2f-b3d8-39a34026e60a"))
+				assert.Equal(t, 1, dbCount(t, "revisions", "type = 'delete' AND entity_id", swissSoftwareID))
+
+				// The ones of the other catalogs are untouched
+				assert.Equal(t, 1, dbCount(t, "software", "deleted_at IS NULL AND id", italiaSoftwareID))
+			},
+		},
+		{
+			description: "DELETE catalog with cascade=false still having software",
+			query:       "DELETE /v1/catalogs/italia?cascade=false",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't delete Catalog","detail":"Catalog still has associated publishers or software","status":409}`,
+		},
+		{
+			description: "DELETE materialized root catalog with cascade",
+			setupFunc: func(t *testing.T) {
+				t.Helper()
+
+				_, err := db.Exec("UPDATE catalogs SET alternative_id = '∅' WHERE id = "+placeholder(1), italiaID)
+				require.NoError(t, err)
+			},
+			query: "DELETE /v1/catalogs/%E2%88%85?cascade=true",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't delete Catalog","detail":"the root Catalog can't be deleted with cascade","status":422}`,
+		},
+		{
+			description:         "GET deletion of another catalog",
+			query:               "GET /v1/catalogs/swiss/deletions/" + swissID,
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Deletion","detail":"Deletion was not found","status":404}`,
+		},
+	}
+
+	runTestCases(t, tests)
+
+	t.Run("DELETE large catalog with cascade in the background", func(t *testing.T) {
+		loadFixtures(t)
+
+		syncMax := common.EnvironmentConfig.CatalogDeleteSyncMax
+		common.EnvironmentConfig.CatalogDeleteSyncMax = 1
+
+		t.Cleanup(func() { common.EnvironmentConfig.CatalogDeleteSyncMax = syncMax })
+
+		addCatalogLogs(t)
+
+		req, err := newTestRequest("DELETE", "/v1/catalogs/italia?cascade=true", nil)
+		require.NoError(t, err)
+		req.Header = map[string][]string{"Authorization": {goodToken}}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 202, res.StatusCode)
+
+		var deletion map[string]interface{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&deletion))
+		assert.Equal(t, "running", deletion["status"])
+
+		location := res.Header.Get("Location")
+		assert.Equal(t, "/v1/catalogs/"+italiaID+"/deletions/"+deletion["id"].(string), location)
+
+		handlers.WaitDeletions()
+
+		req, err = newTestRequest("GET", location, nil)
+		require.NoError(t, err)
+
+		res, err = app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&deletion))
+		assert.Equal(t, "done", deletion["status"])
+		assert.EqualValues(t, 1, deletion["software"])
+		assert.EqualValues(t, 1, deletion["publishers"])
+		assert.EqualValues(t, 5, deletion["logs"])
+
+		assert.Equal(t, 1, dbCount(t, "catalogs", "deleted_at IS NOT NULL AND id", italiaID))
+		assert.Equal(t, 0, dbCount(t, "logs", "deleted_at IS NULL AND entity_id", italiaSoftwareID))
+		assert.Equal(t, 0, dbCount(t, "logs", "deleted_at IS NULL AND entity_id", italiaID))
+	})
+
+	t.Run("POST restore catalog deleted with cascade", func(t *testing.T) {
+		loadFixtures(t)
+
+		for _, query := range []string{"DELETE /v1/catalogs/swiss?cascade=true", "POST /v1/catalogs/swiss/restore"} {
+			method, path, _ := strings.Cut(query, " ")
+
+			req, err := newTestRequest(method, path, nil)
+			require.NoError(t, err)
+			req.Header = map[string][]string{"Authorization": {goodToken}}
+
+			res, err := app.Test(req, -1)
+			require.NoError(t, err)
+			require.Equal(t, 200, res.StatusCode, query)
+		}
+
+		assert.Equal(t, 1, dbCount(t, "catalogs", "deleted_at IS NULL AND id", swissID))
+		assert.Equal(t, 1, dbCount(t, "software", "deleted_at IS NULL AND id", swissSoftwareID))
+		assert.Equal(t, 1, dbCount(t, "publishers", "deleted_at IS NULL AND id", swissPublisherID))
+		assert.Equal(t, 2, dbCount(t, "software_urls", "software_id", swissSoftwareID))
+		assert.Equal(t, 1, dbCount(t, "revisions", "type = 'update' AND entity_id", swissSoftwareID))
+		assert.Equal(t, 1, dbCount(t, "revisions", "type = 'update' AND entity_id", swissPublisherID))
+
+		// The webhooks are gone for good
+		assert.Equal(t, 0, dbCount(t, "webhooks", "entity_id", swissSoftwareID))
+		assert.Equal(t, 0, dbCount(t, "catalog_deletion_entities", "entity_id", swissSoftwareID))
+	})
+
+	t.Run("interrupted deletions fail on startup", func(t *testing.T) {
+		loadFixtures(t)
+
+		gormDB, err := database.NewDatabase(os.Getenv("DATABASE_DSN"))
+		require.NoError(t, err)
+
+		_, err = db.Exec(
+			fmt.Sprintf(
+				"INSERT INTO catalog_deletions (id, catalog_id, status, created_at, updated_at) VALUES (%s, %s, %s, %s, %s)",
+				placeholder(1), placeholder(2), placeholder(3), placeholder(4), placeholder(5),
+			),
+			"5e0c9d1f-2a3b-4c5d-8e6f-7a8b9c0d1e2f", swissID, "running", time.Now(), time.Now(),
+		)
+		require.NoError(t, err)
+
+		require.NoError(t, handlers.FailInterruptedDeletions(gormDB))
+
+		assert.Equal(t, "failed", dbValue(t, "catalog_deletions", "status", "id", "5e0c9d1f-2a3b-4c5d-8e6f-7a8b9c0d1e2f"))
+		assert.Equal(t, 1, dbCount(t, "catalogs", "deleted_at IS NULL AND id", swissID))
+	})
+}
+
+func TestCatalogTrash(t *testing.T) {
+	tests := []TestCase{
+		{
+			description:         "GET catalogs in the trash",
+			query:               "GET /v1/catalogs?deleted=true",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				catalogs := assertListResponse(t, response)
+				require.Len(t, catalogs, 1)
+
+				assert.Equal(t, trashedCatalogID, catalogs[0]["id"])
+				assert.Equal(t, "2021-01-01T00:00:00Z", catalogs[0]["deletedAt"])
+			},
+		},
+		{
+			description:         "GET catalog in the trash by alternativeId",
+			query:               "GET /v1/catalogs/trashed",
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Catalog","detail":"Catalog was not found","status":404}`,
+		},
+		{
+			description: "POST restore catalog",
+			query:       "POST /v1/catalogs/trashed/restore",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, trashedCatalogID, response["id"])
+				assert.NotContains(t, response, "deletedAt")
+				assert.Len(t, response["sources"], 1)
+
+				assert.Equal(t, 0, dbCount(t, "catalogs", "deleted_at IS NOT NULL AND id", trashedCatalogID))
+				assert.Equal(t, "update", dbValue(t, "revisions", "type", "entity_id", trashedCatalogID))
+			},
+		},
+		{
+			description: "POST restore catalog not in the trash",
+			query:       "POST /v1/catalogs/italia/restore",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't restore Catalog","detail":"Catalog was not found in the trash","status":404}`,
+		},
+	}
+
+	runTestCases(t, tests)
+
+	t.Run("POST restore catalog and then its publisher", func(t *testing.T) {
+		loadFixtures(t)
+
+		for _, path := range []string{"/v1/catalogs/trashed/restore", "/v1/publishers/" + trashedPublisherID + "/restore"} {
+			req, err := newTestRequest("POST", path, nil)
+			require.NoError(t, err)
+			req.Header = map[string][]string{"Authorization": {goodToken}}
+
+			res, err := app.Test(req, -1)
+			require.NoError(t, err)
+			require.Equal(t, 200, res.StatusCode, path)
+		}
+
+		assert.Equal(t, 1, dbCount(t, "publishers", "deleted_at IS NULL AND catalog_id", trashedCatalogID))
+	})
+}
+
+func TestCatalogItems(t *testing.T) {
+	tests := []TestCase{
+		// GET /catalogs/:id/software/:softwareId
+		{
+			description:         "GET software of the catalog",
+			query:               "GET /v1/catalogs/italia/software/" + italiaSoftwareID,
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, italiaSoftwareID, response["id"])
+				assert.Equal(t, italiaID, response["catalogId"])
+				assert.Equal(t, "https://1-a.example.org/code/repo", response["url"])
+				assert.Equal(t, []interface{}{"https://1-b.example.org/code/repo"}, response["aliases"])
+			},
+		},
+		{
+			description:         "GET software of the root catalog",
+			query:               "GET /v1/catalogs/%E2%88%85/software/" + rootSoftwareID,
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, rootSoftwareID, response["id"])
+				assert.NotContains(t, response, "catalogId")
+			},
+		},
+		{
+			description:         "GET software of another catalog",
+			query:               "GET /v1/catalogs/italia/software/" + swissSoftwareID,
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Software","detail":"Software was not found","status":404}`,
+		},
+		{
+			description:         "GET software of a non existing catalog",
+			query:               "GET /v1/catalogs/NO_SUCH_CATALOG/software/" + italiaSoftwareID,
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Software","detail":"Catalog was not found","status":404}`,
+		},
+
+		// DELETE /catalogs/:id/software/:softwareId
+		{
+			description:         "DELETE software without authentication",
+			query:               "DELETE /v1/catalogs/swiss/software/" + swissSoftwareID,
+			expectedCode:        401,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"token authentication failed","status":401}`,
+		},
+		{
+			description:         "DELETE software of the catalog",
+			query:               "DELETE /v1/catalogs/swiss/software/" + swissSoftwareID,
+			headers:             map[string][]string{"Authorization": {goodToken}},
+			expectedCode:        204,
+			expectedBody:        "",
+			expectedContentType: "",
+		},
+		{
+			description:         "DELETE software of another catalog",
+			query:               "DELETE /v1/catalogs/italia/software/" + swissSoftwareID,
+			headers:             map[string][]string{"Authorization": {goodToken}},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't delete Software","detail":"Software was not found","status":404}`,
+		},
+
+		// GET /catalogs/:id/publishers/:publisherId
+		{
+			description:         "GET publisher of the catalog",
+			query:               "GET /v1/catalogs/italia/publishers/" + italiaPublisherID,
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, italiaPublisherID, response["id"])
+				assert.Equal(t, italiaID, response["catalogId"])
+				assert.Len(t, response["codeHosting"], 2)
+			},
+		},
+		{
+			description:         "GET publisher of the root catalog by alternativeId",
+			query:               "GET /v1/catalogs/%E2%88%85/publishers/alternative-id-12345",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "alternative-id-12345", response["alternativeId"])
+			},
+		},
+		{
+			description:         "GET publisher of another catalog",
+			query:               "GET /v1/catalogs/swiss/publishers/" + italiaPublisherID,
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Publisher","detail":"Publisher was not found","status":404}`,
+		},
+
+		// DELETE /catalogs/:id/publishers/:publisherId
+		{
+			description:         "DELETE publisher of the catalog",
+			query:               "DELETE /v1/catalogs/italia/publishers/" + italiaPublisherID,
+			headers:             map[string][]string{"Authorization": {goodToken}},
+			expectedCode:        204,
+			expectedBody:        "",
+			expectedContentType: "",
+		},
+		{
+			description:         "DELETE publisher of another catalog",
+			query:               "DELETE /v1/catalogs/swiss/publishers/" + italiaPublisherID,
+			headers:             map[string][]string{"Authorization": {goodToken}},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't delete Publisher","detail":"Publisher was not found","status":404}`,
+		},
+
+		// GET/PATCH /catalogs/:id/software/:softwareId/analysis
+		{
+			description:         "GET analysis of software of the catalog",
+			query:               "GET /v1/catalogs/italia/software/" + italiaSoftwareID + "/analysis",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Empty(t, response)
+			},
+		},
+		{
+			description: "PATCH analysis of software of the catalog",
+			query:       "PATCH /v1/catalogs/italia/software/" + italiaSoftwareID + "/analysis",
+			body:        `{"badges": {"v": 1, "score": 90}}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/merge-patch+json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				badges := response["badges"].(map[string]interface{})
+
+				assert.Equal(t, float64(90), badges["score"])
+				assertRFC3339(t, badges["t"])
+			},
+		},
+		{
+			description: "PATCH analysis of software of another catalog",
+			query:       "PATCH /v1/catalogs/swiss/software/" + italiaSoftwareID + "/analysis",
+			body:        `{"badges": {"v": 1, "score": 90}}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/merge-patch+json"},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't update Software analysis","detail":"Software was not found","status":404}`,
+		},
+
+		// GET/POST /catalogs/:id/software/:softwareId/logs
+		{
+			description:         "GET logs of software of the catalog",
+			query:               "GET /v1/catalogs/italia/software/" + italiaSoftwareID + "/logs",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				assert.Equal(t, 3, len(data))
+			},
+		},
+		{
+			description:         "GET logs of software of another catalog",
+			query:               "GET /v1/catalogs/swiss/software/" + italiaSoftwareID + "/logs",
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Logs","detail":"Software was not found","status":404}`,
+		},
+		{
+			description: "POST log of software of the catalog",
+			query:       "POST /v1/catalogs/italia/software/" + italiaSoftwareID + "/logs",
+			body:        `{"message": "nested software log"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "nested software log", response["message"])
+				assert.Equal(t, "/software/"+italiaSoftwareID, response["entity"])
+			},
+		},
+
+		// GET/POST /catalogs/:id/publishers/:publisherId/logs
+		{
+			description:         "GET logs of publisher of the catalog",
+			query:               "GET /v1/catalogs/italia/publishers/" + italiaPublisherID + "/logs",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				require.Equal(t, 1, len(data))
+				assert.Equal(t, "/publishers/"+italiaPublisherID, data[0]["entity"])
+			},
+		},
+		{
+			description: "POST log of publisher of another catalog",
+			query:       "POST /v1/catalogs/swiss/publishers/" + italiaPublisherID + "/logs",
+			body:        `{"message": "nested publisher log"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Log","detail":"Publisher was not found","status":404}`,
+		},
+	}
+
+	runTestCases(t, tests)
+}
+
+func TestCatalogItemsDeleteDBChecks(t *testing.T) {
+	deleteItem := func(t *testing.T, path string) int {
+		t.Helper()
+
+		req, err := newTestRequest("DELETE", path, nil)
+		require.NoError(t, err)
+		req.Header = map[string][]string{"Authorization": {goodToken}}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+
+		return res.StatusCode
+	}
+
+	t.Run("DELETE software only in its catalog", func(t *testing.T) {
+		loadFixtures(t)
+
+		assert.Equal(t, 404, deleteItem(t, "/v1/catalogs/italia/software/"+swissSoftwareID))
+		assert.Equal(t, 1, dbCount(t, "software", "deleted_at IS NULL AND id", swissSoftwareID))
+
+		assert.Equal(t, 204, deleteItem(t, "/v1/catalogs/swiss/software/"+swissSoftwareID))
+		assert.Equal(t, 1, dbCount(t, "software", "deleted_at IS NOT NULL AND id", swissSoftwareID))
+		assert.Equal(t, 1, dbCount(t, "events", "type = 'delete' AND entity_id", swissSoftwareID))
+	})
+
+	t.Run("DELETE publisher only in its catalog", func(t *testing.T) {
+		loadFixtures(t)
+
+		assert.Equal(t, 404, deleteItem(t, "/v1/catalogs/swiss/publishers/"+italiaPublisherID))
+		assert.Equal(t, 1, dbCount(t, "publishers", "deleted_at IS NULL AND id", italiaPublisherID))
+
+		assert.Equal(t, 204, deleteItem(t, "/v1/catalogs/italia/publishers/"+italiaPublisherID))
+		assert.Equal(t, 1, dbCount(t, "publishers", "deleted_at IS NOT NULL AND id", italiaPublisherID))
+		assert.Equal(t, 1, dbCount(t, "events", "type = 'delete' AND entity_id", italiaPublisherID))
+	})
+}
+
+func TestInactiveCatalogListings(t *testing.T) {
+	deactivateItalia := func(t *testing.T) {
+		t.Helper()
+
+		_, err := db.Exec("UPDATE catalogs SET active = false WHERE id = "+placeholder(1), italiaID)
+		require.NoError(t, err)
+	}
+
+	catalogIDs := func(t *testing.T, response map[string]interface{}) []interface{} {
+		t.Helper()
+
+		ids := []interface{}{}
+		for _, item := range assertListResponse(t, response) {
+			ids = append(ids, item["catalogId"])
+		}
+
+		return ids
+	}
+
+	tests := []TestCase{
+		{
+			description:         "GET software of a catalog",
+			query:               "GET /v1/software?catalog=italia",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, []interface{}{italiaID}, catalogIDs(t, response))
+			},
+		},
+		{
+			description:         "GET software of the root catalog",
+			query:               "GET /v1/software?catalog=%E2%88%85&page[size]=100",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				ids := catalogIDs(t, response)
+
+				assert.NotEmpty(t, ids)
+				assert.NotContains(t, ids, italiaID)
+				assert.NotContains(t, ids, swissID)
+			},
+		},
+		{
+			description:         "GET software of a non existing catalog",
+			query:               "GET /v1/software?catalog=NO_SUCH_CATALOG",
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Software","detail":"Catalog was not found","status":422}`,
+		},
+		{
+			description:         "GET software without the ones of inactive catalogs",
+			setupFunc:           deactivateItalia,
+			query:               "GET /v1/software?page[size]=99",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				ids := catalogIDs(t, response)
+
+				assert.NotContains(t, ids, italiaID)
+				assert.Contains(t, ids, swissID)
+			},
+		},
+		{
+			description:         "GET all software, also the ones of inactive catalogs",
+			setupFunc:           deactivateItalia,
+			query:               "GET /v1/software?page[size]=99&all=true",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Contains(t, catalogIDs(t, response), italiaID)
+			},
+		},
+		{
+			description:         "GET software of an inactive catalog",
+			setupFunc:           deactivateItalia,
+			query:               "GET /v1/software?catalog=" + italiaID,
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Empty(t, catalogIDs(t, response))
+			},
+		},
+		{
+			description:         "GET publishers of a catalog",
+			query:               "GET /v1/publishers?catalog=swiss",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, []interface{}{swissID}, catalogIDs(t, response))
+			},
+		},
+		{
+			description:         "GET publishers without the ones of inactive catalogs",
+			setupFunc:           deactivateItalia,
+			query:               "GET /v1/publishers?page[size]=99",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				ids := catalogIDs(t, response)
+
+				assert.NotContains(t, ids, italiaID)
+				assert.Contains(t, ids, swissID)
+			},
+		},
+		{
+			description:         "GET software of a publisher of a catalog",
+			query:               "GET /v1/publishers/" + italiaPublisherID + "/software?catalog=swiss",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Empty(t, catalogIDs(t, response))
+			},
+		},
+		{
+			description:         "GET software of a publisher without the ones of inactive catalogs",
+			setupFunc:           deactivateItalia,
+			query:               "GET /v1/publishers/" + italiaPublisherID + "/software?page[size]=99",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Empty(t, catalogIDs(t, response))
+			},
+		},
+		{
+			description:         "GET all software of a publisher, also the ones of inactive catalogs",
+			setupFunc:           deactivateItalia,
+			query:               "GET /v1/publishers/" + italiaPublisherID + "/software?page[size]=99&all=true",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, []interface{}{italiaID}, catalogIDs(t, response))
+			},
+		},
+		{
+			description:         "GET software facets without the ones of inactive catalogs",
+			setupFunc:           deactivateItalia,
+			query:               "GET /v1/software/facets?facets=catalog",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				values := []interface{}{}
+				for _, facet := range response["catalog"].([]interface{}) {
+					values = append(values, facet.(map[string]interface{})["value"])
+				}
+
+				assert.NotContains(t, values, italiaID)
+				assert.Contains(t, values, swissID)
+			},
+		},
+	}
+
+	runTestCases(t, tests)
+}
+
+func TestCatalogMove(t *testing.T) {
+	const otherRootSoftwareID = "3eff1b39-8dd3-4871-9fec-32a3172510f1"
+
+	tests := []TestCase{
+		{
+			description:         "POST move software without authentication",
+			query:               "POST /v1/catalogs/italia/software/" + italiaSoftwareID + "/move",
+			body:                `{"catalogId": "swiss"}`,
+			headers:             map[string][]string{"Content-Type": {"application/json"}},
+			expectedCode:        401,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"token authentication failed","status":401}`,
+		},
+		{
+			description: "POST move software to another catalog",
+			query:       "POST /v1/catalogs/italia/software/" + italiaSoftwareID + "/move",
+			body:        `{"catalogId": "swiss"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, italiaSoftwareID, response["id"])
+				assert.Equal(t, swissID, response["catalogId"])
+				assert.Equal(t, "https://1-a.example.org/code/repo", response["url"])
+
+				assert.Equal(t, 2, dbCount(t, "software_urls", "catalog_id = '"+swissID+"' AND software_id", italiaSoftwareID))
+
+				// The publishers of the catalog it left don't own it anymore
+				assert.NotContains(t, response, "publisherId")
+				assert.Equal(t, 1, dbCount(t, "revisions", "data LIKE '%"+swissID+"%' AND entity_id", italiaSoftwareID))
+				assert.Equal(t, 1, dbCount(t, "events", "type = 'update' AND entity_id", italiaSoftwareID))
+			},
+		},
+		{
+			description: "POST move software to the root catalog",
+			query:       "POST /v1/catalogs/italia/software/" + italiaSoftwareID + "/move",
+			body:        `{"catalogId": "∅"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.NotContains(t, response, "catalogId")
+
+				assert.Equal(t, 2, dbCount(t, "software_urls", "catalog_id = '' AND software_id", italiaSoftwareID))
+			},
+		},
+		{
+			description: "POST move software with a URL the target catalog has",
+			setupFunc: func(t *testing.T) {
+				t.Helper()
+
+				_, err := db.Exec(
+					"UPDATE software_urls SET url = 'https://1-b.example.org/code/repo' WHERE id = "+placeholder(1),
+					"56ad5935-21fa-4c59-b8b4-7a0e476fa5f7",
+				)
+				require.NoError(t, err)
+			},
+			query: "POST /v1/catalogs/italia/software/" + italiaSoftwareID + "/move",
+			body:  `{"catalogId": "swiss"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't move Software","detail":"url already exists","status":409}`,
+		},
+		{
+			description: "POST move software of another catalog",
+			query:       "POST /v1/catalogs/italia/software/" + swissSoftwareID + "/move",
+			body:        `{"catalogId": "∅"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't move Software","detail":"Software was not found","status":404}`,
+		},
+		{
+			description: "POST move software to the same catalog",
+			query:       "POST /v1/catalogs/italia/software/" + italiaSoftwareID + "/move",
+			body:        `{"catalogId": "` + italiaID + `"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't move Software","detail":"can't move to the same Catalog","status":422}`,
+		},
+		{
+			description: "POST move software to a catalog in the trash",
+			query:       "POST /v1/catalogs/italia/software/" + italiaSoftwareID + "/move",
+			body:        `{"catalogId": "trashed"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't move Software","detail":"target Catalog was not found","status":422}`,
+		},
+		{
+			description: "POST move software from a catalog in the trash",
+			query:       "POST /v1/catalogs/trashed/software/" + italiaSoftwareID + "/move",
+			body:        `{"catalogId": "swiss"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't move Software","detail":"Catalog was not found","status":404}`,
+		},
+		{
+			description: "POST move software in bulk",
+			query:       "POST /v1/catalogs/%E2%88%85/software/move",
+			body:        `{"catalogId": "italia", "ids": ["` + rootSoftwareID + `", "` + otherRootSoftwareID + `"]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				software := assertListResponse(t, response)
+				require.Len(t, software, 2)
+
+				assert.Equal(t, rootSoftwareID, software[0]["id"])
+				assert.Equal(t, otherRootSoftwareID, software[1]["id"])
+
+				assert.Equal(t, italiaID, dbValue(t, "software", "catalog_id", "id", otherRootSoftwareID))
+			},
+		},
+		{
+			description: "POST move software in bulk with one not in the catalog",
+			query:       "POST /v1/catalogs/%E2%88%85/software/move",
+			body:        `{"catalogId": "italia", "ids": ["` + rootSoftwareID + `", "` + swissSoftwareID + `"]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't move Software","detail":"Software '` + swissSoftwareID + `' is not in the Catalog","status":422}`,
+			validateFunc: func(t *testing.T, _ map[string]interface{}) {
+				assert.Equal(t, 1, dbCount(t, "software", "catalog_id IS NULL AND id", rootSoftwareID))
+			},
+		},
+		{
+			description: "POST move software in bulk without ids",
+			query:       "POST /v1/catalogs/%E2%88%85/software/move",
+			body:        `{"catalogId": "italia"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't move Software","detail":"invalid format: ids is required","status":422,"validationErrors":[{"field":"ids","rule":"required","value":""}]}`,
+		},
+		{
+			description: "POST move publisher to another catalog",
+			query:       "POST /v1/catalogs/italia/publishers/" + italiaPublisherID + "/move",
+			body:        `{"catalogId": "swiss"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, italiaPublisherID, response["id"])
+				assert.Equal(t, swissID, response["catalogId"])
+				assert.Len(t, response["codeHosting"], 2)
+
+				assert.Equal(t, swissID, dbValue(t, "publishers", "catalog_id", "id", italiaPublisherID))
+				assert.Equal(t, 2, dbCount(t, "publishers_code_hosting", "catalog_id = '"+swissID+"' AND publisher_id", italiaPublisherID))
+				assert.Equal(t, 1, dbCount(t, "revisions", "data LIKE '%"+swissID+"%' AND entity_id", italiaPublisherID))
+				assert.Equal(t, 1, dbCount(t, "events", "type = 'update' AND entity_id", italiaPublisherID))
+
+				// The software in the catalog it left isn't owned by it anymore
+				assert.Equal(t, 1, dbCount(t, "software", "publisher_id IS NULL AND id", italiaSoftwareID))
+			},
+		},
+		{
+			description: "POST move publisher with a code hosting URL the target catalog has",
+			setupFunc: func(t *testing.T) {
+				t.Helper()
+
+				_, err := db.Exec(
+					"UPDATE publishers_code_hosting SET url = 'https://1-a.example.org/code/repo' WHERE url = "+placeholder(1),
+					"https://2-a.example.org/code/repo",
+				)
+				require.NoError(t, err)
+			},
+			query: "POST /v1/catalogs/italia/publishers/" + italiaPublisherID + "/move",
+			body:  `{"catalogId": "swiss"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't move Publisher","detail":"codeHosting.url already exists","status":409}`,
+		},
+		{
+			description: "POST move publisher with a description the target catalog has",
+			setupFunc: func(t *testing.T) {
+				t.Helper()
+
+				_, err := db.Exec(
+					"UPDATE publishers SET description = 'Publisher description 3' WHERE id = "+placeholder(1),
+					italiaPublisherID,
+				)
+				require.NoError(t, err)
+			},
+			query: "POST /v1/catalogs/italia/publishers/" + italiaPublisherID + "/move",
+			body:  `{"catalogId": "∅"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't move Publisher","detail":"description already exists","status":409}`,
+		},
+		{
+			description: "POST move publisher with an alternativeId qualified by the target catalog taken",
+			setupFunc: func(t *testing.T) {
+				t.Helper()
+
+				_, err := db.Exec("UPDATE catalogs SET publishers_namespace = 'it:' WHERE id = "+placeholder(1), italiaID)
+				require.NoError(t, err)
+
+				_, err = db.Exec("UPDATE publishers SET alternative_id = 'c_h501' WHERE id = "+placeholder(1), swissPublisherID)
+				require.NoError(t, err)
+
+				_, err = db.Exec("UPDATE publishers SET alternative_id = 'it:c_h501' WHERE id = "+placeholder(1), rootPublisherID)
+				require.NoError(t, err)
+			},
+			query: "POST /v1/catalogs/swiss/publishers/" + swissPublisherID + "/move",
+			body:  `{"catalogId": "italia"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't move Publisher","detail":"Publisher with id 'it:c_h501' already exists","status":409}`,
+		},
+		{
+			description: "POST move publisher of another catalog",
+			query:       "POST /v1/catalogs/swiss/publishers/" + italiaPublisherID + "/move",
+			body:        `{"catalogId": "∅"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't move Publisher","detail":"Publisher was not found","status":404}`,
+		},
+		{
+			description: "POST move publishers in bulk",
+			query:       "POST /v1/catalogs/swiss/publishers/move",
+			body:        `{"catalogId": "∅", "ids": ["` + swissPublisherID + `"]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				publishers := assertListResponse(t, response)
+				require.Len(t, publishers, 1)
+
+				assert.Equal(t, swissPublisherID, publishers[0]["id"])
+				assert.NotContains(t, publishers[0], "catalogId")
+			},
+		},
+		{
+			description: "POST move publishers in bulk with the same id twice",
+			query:       "POST /v1/catalogs/swiss/publishers/move",
+			body:        `{"catalogId": "∅", "ids": ["` + swissPublisherID + `", "` + swissPublisherID + `"]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't move Publishers","detail":"invalid format: ids is invalid","status":422,"validationErrors":[{"field":"ids","rule":"unique","value":""}]}`,
+		},
+	}
+
+	runTestCases(t, tests)
+}
+
+func TestCatalogPublishersNamespace(t *testing.T) {
+	// The publishers of italia and swiss have the same alternativeId, the one
+	// of italia qualified as it:c_h501
+	setup := func(t *testing.T) {
+		t.Helper()
+
+		_, err := db.Exec("UPDATE catalogs SET publishers_namespace = 'it:' WHERE id = "+placeholder(1), italiaID)
+		require.NoError(t, err)
+
+		_, err = db.Exec(
+			"UPDATE publishers SET alternative_id = 'c_h501' WHERE id IN ("+placeholder(1)+", "+placeholder(2)+")",
+			italiaPublisherID, swissPublisherID,
+		)
+		require.NoError(t, err)
+	}
+
+	tests := []TestCase{
+		{
+			description:         "GET publisher by qualified alternativeId",
+			setupFunc:           setup,
+			query:               "GET /v1/publishers/it:c_h501",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, italiaPublisherID, response["id"])
+				assert.Equal(t, "c_h501", response["alternativeId"])
+			},
+		},
+		{
+			description: "GET publisher by alternativeId qualified by the longest namespace",
+			setupFunc: func(t *testing.T) {
+				t.Helper()
+
+				setup(t)
+
+				_, err := db.Exec("UPDATE catalogs SET publishers_namespace = 'it:ch:' WHERE id = "+placeholder(1), swissID)
+				require.NoError(t, err)
+			},
+			query:               "GET /v1/publishers/it:ch:c_h501",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, swissPublisherID, response["id"])
+			},
+		},
+		{
+			description:         "GET publisher by alternativeId qualified by an unknown namespace",
+			setupFunc:           setup,
+			query:               "GET /v1/publishers/ch:c_h501",
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Publisher","detail":"Publisher was not found","status":404}`,
+		},
+		{
+			description: "PATCH catalog publisher by alternativeId",
+			setupFunc:   setup,
+			query:       "PATCH /v1/catalogs/" + swissID + "/publishers/c_h501",
+			body:        `{"email": "c_h501@swiss.example.org"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, swissPublisherID, response["id"])
+				assert.Equal(t, "c_h501@swiss.example.org", response["email"])
+			},
+		},
+		{
+			description: "PATCH catalog publisher by qualified alternativeId",
+			setupFunc:   setup,
+			query:       "PATCH /v1/catalogs/" + italiaID + "/publishers/it:c_h501",
+			body:        `{"email": "c_h501@italia.example.org"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, italiaPublisherID, response["id"])
+			},
+		},
+		{
+			description: "PATCH catalog publisher qualified by the namespace of another catalog",
+			setupFunc:   setup,
+			query:       "PATCH /v1/catalogs/" + swissID + "/publishers/it:c_h501",
+			body:        `{"email": "c_h501@swiss.example.org"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't update Publisher","detail":"Publisher was not found","status":404}`,
+		},
+		{
+			description: "POST catalog publisher with the description and alternativeId of another catalog's",
+			setupFunc:   setup,
+			query:       "POST /v1/catalogs/" + swissID + "/publishers",
+			body:        `{"codeHosting": [{"url": "https://c-h501.example.org"}], "description": "Publisher description 1", "alternativeId": "c_h502"}`, //nolint:lll
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, swissID, response["catalogId"])
+				assert.Equal(t, "Publisher description 1", response["description"])
+			},
+		},
+		{
+			description: "POST catalog publisher with the description of one of the catalog",
+			setupFunc:   setup,
+			query:       "POST /v1/catalogs/" + swissID + "/publishers",
+			body:        `{"codeHosting": [{"url": "https://c-h501.example.org"}], "description": "Publisher description 2"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Publisher","detail":"description already exists","status":409}`,
+		},
+		{
+			description: "POST catalog publisher with the alternativeId of one of the catalog",
+			setupFunc:   setup,
+			query:       "POST /v1/catalogs/" + swissID + "/publishers",
+			body:        `{"codeHosting": [{"url": "https://c-h501.example.org"}], "description": "Comune", "alternativeId": "c_h501"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Publisher","detail":"alternativeId already exists","status":409}`,
+		},
+		{
+			description: "POST publisher with an alternativeId qualifying another publisher's",
+			setupFunc:   setup,
+			query:       "POST /v1/publishers",
+			body:        `{"codeHosting": [{"url": "https://c-h501.example.org"}], "description": "Comune", "alternativeId": "it:c_h501"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Publisher","detail":"Publisher with id 'it:c_h501' already exists","status":409}`,
+		},
+		{
+			description: "POST catalog publisher with an alternativeId that qualified is another publisher's",
+			setupFunc: func(t *testing.T) {
+				setup(t)
+
+				_, err := db.Exec("UPDATE publishers SET alternative_id = 'it:c_h503' WHERE id = "+placeholder(1), rootPublisherID)
+				require.NoError(t, err)
+			},
+			query: "POST /v1/catalogs/" + italiaID + "/publishers",
+			body:  `{"codeHosting": [{"url": "https://c-h503.example.org"}], "description": "Comune", "alternativeId": "c_h503"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Publisher","detail":"Publisher with id 'it:c_h503' already exists","status":409}`,
+		},
+		{
+			description: "POST catalog with a publishersNamespace without separator",
+			query:       "POST /v1/catalogs",
+			body:        `{"name": "Another", "publishersNamespace": "it", "sources": [{"url": "https://another.example.org"}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Catalog","detail":"invalid format: publishersNamespace must end with / or :","status":422,"validationErrors":[{"field":"publishersNamespace","rule":"publishers_namespace","value":"it"}]}`,
+		},
+		{
+			description: "POST catalog with the publishersNamespace of another catalog",
+			setupFunc:   setup,
+			query:       "POST /v1/catalogs",
+			body:        `{"name": "Another", "publishersNamespace": "it:", "sources": [{"url": "https://another.example.org"}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Catalog","detail":"publishersNamespace already exists","status":409}`,
+		},
+	}
+
+	runTestCases(t, tests)
+}
+
+func TestCatalogSourceDrivers(t *testing.T) {
+	tests := []TestCase{
+		{
+			query:               "GET /v1/catalogs/drivers",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := response["data"].([]interface{})
+
+				names := make([]string, 0, len(data))
+				for _, driver := range data {
+					names = append(names, driver.(map[string]interface{})["name"].(string))
+				}
+
+				assert.Equal(t, []string{"bitbucket", "github", "gitlab", "json", "list", "publiccode-index"}, names)
+
+				json := data[3].(map[string]interface{})
+				assert.Equal(t, "https://{host}/{path to the document}", json["url"])
+				assert.Equal(t, []interface{}{map[string]interface{}{
+					"name":        "path",
+					"description": "JSONPath expression matching the repository URLs in the document",
+					"required":    true,
+					"pattern":     `^\$`,
+				}}, json["args"])
+			},
+		},
+		{
+			description: "POST catalog with an unknown source driver",
+			query:       "POST /v1/catalogs",
+			body:        `{"name": "Typo", "sources": [{"url": "https://github.com/example", "driver": "gihub"}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Catalog","detail":"invalid format: sources[0].driver is not one of the allowed values","status":422,"validationErrors":[{"field":"sources[0].driver","rule":"oneof","value":"gihub"}]}`,
+		},
+		{
+			description: "POST catalog with a source URL not matching its driver",
+			query:       "POST /v1/catalogs",
+			body:        `{"name": "Repository", "sources": [{"url": "https://github.com/example/repo", "driver": "github"}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Catalog","detail":"invalid format: sources[0].url is not a valid URL for the driver","status":422,"validationErrors":[{"field":"sources[0].url","rule":"driver_url","value":"https://github.com/example/repo"}]}`,
+		},
+		{
+			description: "POST catalog with a source missing the args of its driver",
+			query:       "POST /v1/catalogs",
+			body:        `{"name": "No path", "sources": [{"url": "https://example.org/repos.json", "driver": "json"}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Catalog","detail":"invalid format: sources[0].args does not meet its size limits (too short)","status":422,"validationErrors":[{"field":"sources[0].args","rule":"min","value":""}]}`,
+		},
+		{
+			description: "POST catalog with args for a source without driver",
+			query:       "POST /v1/catalogs",
+			body:        `{"name": "Args", "sources": [{"url": "https://example.org/repos.json", "args": ["$.items"]}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Catalog","detail":"invalid format: sources[0].driver is required","status":422,"validationErrors":[{"field":"sources[0].driver","rule":"required","value":""}]}`,
+		},
+		{
+			description: "PATCH catalog adding a source not matching its driver",
+			query:       "PATCH /v1/catalogs/" + italiaID,
+			body:        `{"sources": [{"url": "https://github.com/example/italia-catalog"}, {"url": "https://bitbucket.org/example/repo", "driver": "bitbucket"}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't update Catalog","detail":"invalid format: sources[1].url is not a valid URL for the driver","status":422,"validationErrors":[{"field":"sources[1].url","rule":"driver_url","value":"https://bitbucket.org/example/repo"}]}`,
+		},
+		{
+			description: "PATCH catalog keeping a source its driver doesn't validate",
+			query:       "PATCH /v1/catalogs/" + italiaID,
+			body:        `{"sources": [{"url": "https://github.com/example/italia-catalog"}, {"url": "https://bitbucket.org/example", "driver": "bitbucket"}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Len(t, response["sources"], 2)
+			},
+		},
+	}
+
+	runTestCases(t, tests)
 }
 
 func TestCatalogSourcesDBChecks(t *testing.T) {
 	t.Run("POST stores driver when provided", func(t *testing.T) {
 		loadFixtures(t)
 
-		body := `{"name":"With Driver","sources":[{"url":"https://code.example.org/repo","driver":"custom"}]}`
+		body := `{"name":"With Driver","sources":[{"url":"https://code.example.org/repo","driver":"gitlab"}]}`
 		req, err := newTestRequest("POST", "/v1/catalogs", strings.NewReader(body))
 		require.NoError(t, err)
 		req.Header = map[string][]string{
@@ -753,7 +2491,7 @@ func TestCatalogSourcesDBChecks(t *testing.T) {
 		require.NoError(t, json.NewDecoder(res.Body).Decode(&created))
 		catalogID := created["id"].(string)
 
-		assert.Equal(t, "custom", dbValue(t, "catalog_sources", "driver", "catalog_id", catalogID))
+		assert.Equal(t, "gitlab", dbValue(t, "catalog_sources", "driver", "catalog_id", catalogID))
 	})
 
 	t.Run("POST accepts source without driver", func(t *testing.T) {
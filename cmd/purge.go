@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/database"
+	"github.com/italia/developers-italia-api/internal/trash"
+	"github.com/spf13/cobra"
+)
+
+var errNegativeRetention = errors.New("--older-than can't be negative")
+
+func NewPurgeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "purge",
+		Short:        "Permanently delete the software, publishers and catalogs in the trash",
+		SilenceUsage: true,
+		RunE:         runPurge,
+	}
+
+	cmd.Flags().Duration("older-than", 30*24*time.Hour, "purge only what has been in the trash for longer than this")
+
+	cmd.Flags().Lookup("older-than").DefValue = "30 days"
+
+	return cmd
+}
+
+func runPurge(cmd *cobra.Command, _ []string) error {
+	olderThan, _ := cmd.Flags().GetDuration("older-than")
+
+	if olderThan < 0 {
+		return errNegativeRetention
+	}
+
+	if err := env.Parse(&common.EnvironmentConfig); err != nil {
+		return fmt.Errorf("can't parse environment: %w", err)
+	}
+
+	gormDB, err := database.NewDatabase(common.EnvironmentConfig.Database)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	purged, err := trash.Purge(gormDB, time.Now().Add(-olderThan))
+	if err != nil {
+		return fmt.Errorf("can't purge the trash: %w", err)
+	}
+
+	fmt.Fprintf(
+		os.Stdout,
+		"purged %d software, %d publishers and %d catalogs\n",
+		purged.Software, purged.Publishers, purged.Catalogs,
+	)
+
+	return nil
+}
@@ -6,4 +6,12 @@ const (
 	EventTypeCreate = "create"
 	EventTypeUpdate = "update"
 	EventTypeDelete = "delete"
+
+	RunStatusOpen    = "open"
+	RunStatusClosed  = "closed"
+	RunStatusAborted = "aborted"
+
+	DeletionStatusRunning = "running"
+	DeletionStatusDone    = "done"
+	DeletionStatusFailed  = "failed"
 )
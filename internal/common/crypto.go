@@ -0,0 +1,91 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+var errCiphertextTooShort = errors.New("ciphertext too short")
+
+// DeriveKey returns a 32 bytes key for the given purpose, derived from k.
+// It lets a single configured secret back several independent keys, so
+// data encrypted for one purpose can't be decrypted as another.
+func (k *Base64Key) DeriveKey(purpose string) []byte {
+	h := hmac.New(sha256.New, k[:])
+
+	// This can't fail
+	_, _ = h.Write([]byte(purpose))
+
+	return h.Sum(nil)
+}
+
+// WebhookHeadersKey returns the key used to encrypt the secret custom
+// headers of webhooks. It's derived from PASETO_KEY, so rotating that key
+// makes the stored secret headers unreadable.
+func WebhookHeadersKey() []byte {
+	return EnvironmentConfig.PasetoKey.DeriveKey("webhook-headers")
+}
+
+// Encrypt seals plaintext with AES-256-GCM and returns the nonce followed by
+// the ciphertext, base64-encoded.
+func Encrypt(key []byte, plaintext string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("can't generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value returned by Encrypt.
+func Decrypt(key []byte, ciphertext string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("can't base64-decode ciphertext: %w", err)
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return "", errCiphertextTooShort
+	}
+
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("can't decrypt: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("can't create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("can't create GCM: %w", err)
+	}
+
+	return aead, nil
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	key := (&Base64Key{}).DeriveKey("test")
+
+	encrypted, err := Encrypt(key, "Bearer s3cr3t")
+	require.NoError(t, err)
+
+	assert.NotContains(t, encrypted, "s3cr3t")
+
+	decrypted, err := Decrypt(key, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer s3cr3t", decrypted)
+}
+
+func TestEncryptUsesRandomNonce(t *testing.T) {
+	key := (&Base64Key{}).DeriveKey("test")
+
+	first, err := Encrypt(key, "value")
+	require.NoError(t, err)
+
+	second, err := Encrypt(key, "value")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first, second)
+}
+
+func TestDecryptWithDifferentPurposeFails(t *testing.T) {
+	var base Base64Key
+
+	encrypted, err := Encrypt(base.DeriveKey("one"), "value")
+	require.NoError(t, err)
+
+	_, err = Decrypt(base.DeriveKey("two"), encrypted)
+	assert.Error(t, err)
+
+	_, err = Decrypt(base.DeriveKey("one"), "dG9vc2hvcnQ=")
+	assert.Error(t, err)
+}
@@ -9,20 +9,24 @@ import (
 // pgConstraintToAPI maps PostgreSQL unique index names to API field names.
 // GORM generates these as idx_<table>_<column> for uniqueIndex fields.
 var pgConstraintToAPI = map[string]string{ //nolint:gochecknoglobals
-	"idx_publishers_description":      "description",
-	"idx_publishers_alternative_id":   "alternativeId",
-	"idx_publishers_code_hosting_url": "codeHosting.url",
-	"idx_software_urls_url":           "url",
+	"idx_publishers_description_catalog":         "description",
+	"idx_publishers_alternative_id_catalog":      "alternativeId",
+	"idx_catalogs_publishers_namespace":          "publishersNamespace",
+	"idx_publishers_code_hosting_url_catalog_id": "codeHosting.url",
+	"idx_software_urls_url_catalog_id":           "url",
 }
 
 // sqliteColToAPI maps SQLite "table.column" identifiers to API field names.
 // SQLite unique constraint errors always have the format:
-// "UNIQUE constraint failed: table.column".
+// "UNIQUE constraint failed: table.column", with all the columns of the
+// index, comma-separated, for the ones on more columns, or
+// "UNIQUE constraint failed: index 'name'" for the ones on expressions.
 var sqliteColToAPI = map[string]string{ //nolint:gochecknoglobals
-	"publishers.description":      "description",
-	"publishers.alternative_id":   "alternativeId",
-	"publishers_code_hosting.url": "codeHosting.url",
-	"software_urls.url":           "url",
+	"index 'idx_publishers_description_catalog'":                      "description",
+	"index 'idx_publishers_alternative_id_catalog'":                   "alternativeId",
+	"catalogs.publishers_namespace":                                   "publishersNamespace",
+	"publishers_code_hosting.url, publishers_code_hosting.catalog_id": "codeHosting.url",
+	"software_urls.url, software_urls.catalog_id":                     "url",
 }
 
 // DuplicateField reports whether err is a unique constraint violation.
@@ -17,13 +17,29 @@ type Environment struct {
 
 	// WebhookDebounceMS is the delay in milliseconds before a
 	// webhook is dispatched after the last write. Set to 0 to disable
-	// debouncing entirely. Note: debouncing is per replica.
+	// debouncing entirely. Note: debouncing is per replica, unless
+	// WebhookDebounceBackend is "database".
 	WebhookDebounceMS int `env:"WEBHOOK_DEBOUNCE_MS" envDefault:"1000"`
 
 	// WebhookDebounceMaxMS is the hard cap in milliseconds on how long a
 	// webhook can be deferred by repeated resets of the debounce timer.
 	// Set to 0 to disable the cap. Ignored when WebhookDebounceMS is 0.
 	WebhookDebounceMaxMS int `env:"WEBHOOK_DEBOUNCE_MAX_MS" envDefault:"10000"`
+
+	// WebhookDebounceBackend is where the events held back by debouncing
+	// are kept: "memory" (each replica debounces on its own) or "database"
+	// (the window and the cap apply across all the replicas).
+	WebhookDebounceBackend string `env:"WEBHOOK_DEBOUNCE_BACKEND" envDefault:"memory"`
+
+	// WebhookShutdownTimeoutMS is how long in milliseconds shutdown waits
+	// for the webhook deliveries in flight. The ones still running after
+	// that are stored and delivered again by the next instance.
+	WebhookShutdownTimeoutMS int `env:"WEBHOOK_SHUTDOWN_TIMEOUT_MS" envDefault:"10000"`
+
+	// CatalogDeleteSyncMax is the most software and publishers a cascading
+	// deletion of a catalog removes within the request. Larger catalogs are
+	// deleted in the background.
+	CatalogDeleteSyncMax int `env:"CATALOG_DELETE_SYNC_MAX" envDefault:"500"`
 }
 
 func (k *Base64Key) UnmarshalText(text []byte) error {
@@ -27,7 +27,7 @@ type CatalogPost struct {
 	Name                string        `json:"name" validate:"required,min=1,max=255"`
 	AlternativeID       *string       `json:"alternativeId" validate:"omitempty,min=1,max=255"`
 	Active              *bool         `json:"active"`
-	PublishersNamespace *string       `json:"publishersNamespace" validate:"omitempty,max=255"`
+	PublishersNamespace *string       `json:"publishersNamespace" validate:"omitempty,max=255,publishers_namespace"`
 	Scopes              []string      `json:"scopes" validate:"omitempty,max=20,dive,min=1,max=64"`
 	Sources             []SourceInput `json:"sources" validate:"omitempty,max=100,dive"`
 }
@@ -36,11 +36,19 @@ type CatalogPatch struct {
 	Name                *string        `json:"name" validate:"omitempty,min=1,max=255"`
 	AlternativeID       *string        `json:"alternativeId" validate:"omitempty,max=255"`
 	Active              *bool          `json:"active"`
-	PublishersNamespace *string        `json:"publishersNamespace" validate:"omitempty,max=255"`
+	PublishersNamespace *string        `json:"publishersNamespace" validate:"omitempty,max=255,publishers_namespace"`
 	Scopes              *[]string      `json:"scopes" validate:"omitempty,max=20,dive,min=1,max=64"`
 	Sources             *[]SourceInput `json:"sources" validate:"omitempty,gt=0,max=100,dive"`
 }
 
+type CatalogRunPost struct {
+	MaxDeactivated *int `json:"maxDeactivated" validate:"omitempty,min=0,max=100"`
+}
+
+type CatalogRunURLs struct {
+	URLs []string `json:"urls" validate:"required,gt=0,max=1000,dive,url"`
+}
+
 type PublisherPost struct {
 	CodeHosting   []CodeHosting `json:"codeHosting" validate:"required,gt=0,dive"`
 	Description   string        `json:"description" validate:"required"`
@@ -57,6 +65,23 @@ type PublisherPatch struct {
 	AlternativeID *string        `json:"alternativeId" validate:"omitempty,max=255"`
 }
 
+// MergePost is the duplicate to merge into a Publisher or Software.
+type MergePost struct {
+	ID string `json:"id" validate:"required,max=255"`
+}
+
+// CatalogMove is the Catalog to move a Publisher or Software to.
+type CatalogMove struct {
+	CatalogID string `json:"catalogId" validate:"required,max=255"`
+}
+
+// CatalogBulkMove is the Catalog to move the Publishers or Software with IDs
+// to.
+type CatalogBulkMove struct {
+	CatalogID string   `json:"catalogId" validate:"required,max=255"`
+	IDs       []string `json:"ids" validate:"required,gt=0,max=100,unique,dive,required,max=255"`
+}
+
 type CodeHosting struct {
 	URL   string `json:"url" validate:"required,http_url,code_hosting_url"`
 	Group *bool  `json:"group"`
@@ -67,7 +92,6 @@ type SoftwarePost struct {
 	Aliases       []string `json:"aliases" validate:"dive,url"`
 	PubliccodeYml string   `json:"publiccodeYml" validate:"required"`
 	Active        *bool    `json:"active"`
-	Vitality      *string  `json:"vitality"`
 }
 
 type SoftwarePatch struct {
@@ -75,7 +99,16 @@ type SoftwarePatch struct {
 	Aliases       *[]string `json:"aliases" validate:"omitempty,dive,url"`
 	PubliccodeYml *string   `json:"publiccodeYml"`
 	Active        *bool     `json:"active"`
-	Vitality      *string   `json:"vitality"`
+}
+
+type SoftwareVitalityPost struct {
+	Data []SoftwareVitalityPoint `json:"data" validate:"required,gt=0,max=1000,dive"`
+}
+
+type SoftwareVitalityPoint struct {
+	Date       string         `json:"date" validate:"required,datetime=2006-01-02"`
+	Score      *int           `json:"score" validate:"required,min=0,max=100"`
+	Components map[string]int `json:"components" validate:"omitempty,max=20,dive,keys,min=1,max=64,alphanum,endkeys,min=0,max=100"` //nolint:lll
 }
 
 type Log struct {
@@ -83,8 +116,23 @@ type Log struct {
 }
 
 type Webhook struct {
-	URL    string `json:"url" validate:"required,url"`
-	Secret string `json:"secret" validate:"omitempty,min=16,max=256"`
+	URL     string          `json:"url" validate:"required,url"`
+	Secret  string          `json:"secret" validate:"omitempty,min=16,max=256"`
+	Headers []WebhookHeader `json:"headers" validate:"omitempty,max=10,dive"`
+	Batch   *WebhookBatch   `json:"batch"`
+}
+
+// WebhookBatch configures the batched delivery of a webhook. A Window of 0
+// turns batching off, so it's required with MaxEvents.
+type WebhookBatch struct {
+	Window    int `json:"window" validate:"required_with=MaxEvents,omitempty,min=1000,max=86400000"`
+	MaxEvents int `json:"maxEvents" validate:"omitempty,min=2,max=1000"`
+}
+
+type WebhookHeader struct {
+	Name   string `json:"name" validate:"required,max=128,webhook_header_name"`
+	Value  string `json:"value" validate:"required,max=2048"`
+	Secret bool   `json:"secret"`
 }
 
 func NormalizeEmail(email *string) *string {
@@ -2,8 +2,10 @@ package common
 
 import (
 	"errors"
+	"net/http"
 	"net/url"
 	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -18,6 +20,20 @@ import (
 //nolint:gochecknoglobals // shared validator instance for the host check
 var hostValidator = validator.New()
 
+// reservedWebhookHeaders are the headers a webhook can't override, because
+// they are set by the API on every delivery or managed by the HTTP client.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var reservedWebhookHeaders = []string{
+	"Connection",
+	"Content-Length",
+	"Content-Type",
+	"Host",
+	"Transfer-Encoding",
+	"User-Agent",
+	"X-Webhook-Signature",
+}
+
 const (
 	tagPosition      = 2
 	maxProvidedValue = 255
@@ -37,6 +53,8 @@ func ValidateStruct(validateStruct any) []ValidationError {
 	})
 
 	_ = validate.RegisterValidation("code_hosting_url", validateCodeHostingURL)
+	_ = validate.RegisterValidation("webhook_header_name", validateWebhookHeaderName)
+	_ = validate.RegisterValidation("publishers_namespace", validatePublishersNamespace)
 
 	var validationErrors []ValidationError
 
@@ -102,12 +120,42 @@ func validateCodeHostingURL(fl validator.FieldLevel) bool {
 	return hostValidator.Var(parsed.Hostname(), "fqdn") == nil
 }
 
+// validateWebhookHeaderName accepts a valid HTTP header name that is not
+// one the API sets itself on webhook deliveries, or that the HTTP client
+// manages.
+func validateWebhookHeaderName(fl validator.FieldLevel) bool {
+	name := fl.Field().String()
+
+	if name == "" || strings.IndexFunc(name, func(r rune) bool { return !isHeaderTokenChar(r) }) != -1 {
+		return false
+	}
+
+	return !slices.Contains(reservedWebhookHeaders, http.CanonicalHeaderKey(name))
+}
+
+// validatePublishersNamespace accepts a namespace ending with a separator,
+// `/` or `:`, so the one of a catalog can't be the start of the one of
+// another, fe. `ab` of `a` followed by `b`.
+func validatePublishersNamespace(fl validator.FieldLevel) bool {
+	return strings.HasSuffix(fl.Field().String(), "/") || strings.HasSuffix(fl.Field().String(), ":")
+}
+
+// isHeaderTokenChar reports whether r is allowed in an HTTP header name
+// (RFC 9110 "token").
+func isHeaderTokenChar(r rune) bool {
+	if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+		return true
+	}
+
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
+
 func GenerateErrorDetails(validationErrors []ValidationError) string {
 	var errors []string
 
 	for _, validationError := range validationErrors {
 		switch validationError.Rule {
-		case "required":
+		case "required", "required_with":
 			errors = append(errors, validationError.Field+" is required")
 		case "email":
 			errors = append(errors, validationError.Field+" is not a valid email")
@@ -119,6 +167,18 @@ func GenerateErrorDetails(validationErrors []ValidationError) string {
 			errors = append(errors, validationError.Field+" does not meet its size limits (too few items)")
 		case "code_hosting_url":
 			errors = append(errors, validationError.Field+" is not a valid public http(s) URL")
+		case "webhook_header_name":
+			errors = append(errors, validationError.Field+" is not a valid or allowed header name")
+		case "publishers_namespace":
+			errors = append(errors, validationError.Field+" must end with / or :")
+		case "oneof":
+			errors = append(errors, validationError.Field+" is not one of the allowed values")
+		case "datetime":
+			errors = append(errors, validationError.Field+" is not a valid date")
+		case "publiccode":
+			errors = append(errors, validationError.Field+": "+validationError.Value)
+		case "driver_url":
+			errors = append(errors, validationError.Field+" is not a valid URL for the driver")
 		default:
 			errors = append(errors, validationError.Field+" is invalid")
 		}
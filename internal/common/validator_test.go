@@ -55,3 +55,37 @@ func TestValidateCodeHostingURL(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateWebhookHeaderName(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		valid  bool
+	}{
+		{"authorization", "Authorization", true},
+		{"api key", "X-Api-Key", true},
+		{"lowercase", "x-gateway-token", true},
+
+		{"space", "X Api Key", false},
+		{"colon", "X-Api-Key:", false},
+		{"newline", "X-Api-Key\r\nHost", false},
+		{"content type", "Content-Type", false},
+		{"user agent lowercase", "user-agent", false},
+		{"signature", "X-Webhook-Signature", false},
+		{"host", "Host", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := WebhookHeader{Name: tt.header, Value: "value"}
+
+			errs := ValidateStruct(payload)
+
+			if tt.valid {
+				assert.Empty(t, errs, "expected %q to validate", tt.header)
+			} else {
+				assert.NotEmpty(t, errs, "expected %q to fail", tt.header)
+			}
+		})
+	}
+}
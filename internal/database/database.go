@@ -2,17 +2,25 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/italia/developers-italia-api/internal/ownership"
+	"github.com/italia/developers-italia-api/internal/publiccode"
+	"github.com/italia/developers-italia-api/internal/search"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+var errDuplicatePublishers = errors.New("publishers with the same description or alternativeId in a catalog")
+
 func NewDatabase(connection string) (*gorm.DB, error) {
 	var (
 		database *gorm.DB
@@ -38,10 +46,71 @@ func NewDatabase(connection string) (*gorm.DB, error) {
 		return nil, fmt.Errorf("can't open database: %w", err)
 	}
 
+	// Software created before the links to the publishers have none yet
+	linkSoftware := !database.Migrator().HasColumn(&models.Software{}, "publisher_id")
+
+	// Software created before the vitality data points has a free-form
+	// vitality string instead. The column is kept for now, to roll back to
+	// the previous release, and will be dropped in a later one.
+	convertVitality := database.Migrator().HasColumn(&models.Software{}, "vitality") &&
+		!database.Migrator().HasTable(&models.SoftwareVitality{})
+
+	// Software URLs were unique across all the catalogs before having the
+	// one of their software
+	scopeURLs := database.Migrator().HasTable(&models.SoftwareURL{}) &&
+		!database.Migrator().HasColumn(&models.SoftwareURL{}, "catalog_id")
+
+	// Code hosting URLs were unique across all the catalogs before having
+	// the one of their publisher
+	scopeCodeHosting := database.Migrator().HasTable(&models.CodeHosting{}) &&
+		!database.Migrator().HasColumn(&models.CodeHosting{}, "catalog_id")
+
+	// Software created before the fields of its publiccode.yml were
+	// extracted has none yet
+	extractPubliccode := !database.Migrator().HasTable(&models.SoftwareDescription{})
+
+	if err := checkPublisherDuplicates(database); err != nil {
+		return nil, fmt.Errorf("can't index publishers: %w", err)
+	}
+
 	if err := migrateModels(database); err != nil {
 		return nil, fmt.Errorf("database migration error: %w", err)
 	}
 
+	if linkSoftware {
+		if err := ownership.LinkAll(database); err != nil {
+			return nil, fmt.Errorf("can't link software to publishers: %w", err)
+		}
+	}
+
+	if extractPubliccode {
+		if err := publiccode.ExtractAll(database); err != nil {
+			return nil, fmt.Errorf("can't extract the publiccode.yml of software: %w", err)
+		}
+	}
+
+	if convertVitality {
+		if err := migrateVitality(database); err != nil {
+			return nil, fmt.Errorf("can't convert software vitality: %w", err)
+		}
+	}
+
+	if err := migratePublisherIndexes(database); err != nil {
+		return nil, fmt.Errorf("can't index publishers: %w", err)
+	}
+
+	if scopeURLs {
+		if err := migrateURLCatalogs(database); err != nil {
+			return nil, fmt.Errorf("can't scope software URLs to catalogs: %w", err)
+		}
+	}
+
+	if scopeCodeHosting {
+		if err := migrateCodeHostingCatalogs(database); err != nil {
+			return nil, fmt.Errorf("can't scope code hosting to catalogs: %w", err)
+		}
+	}
+
 	// Workaround until #72 (proper migrations): GIN index on analysis for
 	// per-namespace queries. SQLite doesn't support GIN, PostgreSQL only.
 	if !strings.HasPrefix(connection, "file:") {
@@ -56,6 +125,10 @@ func NewDatabase(connection string) (*gorm.DB, error) {
 		}
 	}
 
+	if err := search.Setup(database); err != nil {
+		return nil, fmt.Errorf("can't set up full-text search: %w", err)
+	}
+
 	return database, nil
 }
 
@@ -63,12 +136,24 @@ func migrateModels(database *gorm.DB) error {
 	for _, model := range []any{
 		&models.Catalog{},
 		&models.CatalogSource{},
+		&models.CatalogRun{},
+		&models.CatalogRunURL{},
+		&models.CatalogDeletion{},
+		&models.CatalogDeletionEntity{},
 		&models.Publisher{},
 		&models.Event{},
+		&models.PendingEvent{},
+		&models.PendingDelivery{},
+		&models.Revision{},
+		&models.Redirect{},
 		&models.CodeHosting{},
 		&models.Software{},
 		&models.SoftwareURL{},
+		&models.SoftwareDescription{},
+		&models.SoftwareTerm{},
+		&models.SoftwareVitality{},
 		&models.Webhook{},
+		&models.WebhookHeader{},
 	} {
 		if err := database.AutoMigrate(model); err != nil {
 			return fmt.Errorf("can't migrate %T: %w", model, err)
@@ -88,3 +173,191 @@ func migrateModels(database *gorm.DB) error {
 
 	return nil
 }
+
+// checkPublisherDuplicates fails, naming them, if publishers in the same
+// catalog have the same description or alternativeId, before creating the
+// indexes keeping them unique in their catalog. They were unique across all
+// the catalogs before, so it happens only if the database was changed by
+// hand: the duplicates have to be merged (POST /v1/publishers/{id}/merge),
+// or their description or alternativeId changed, to upgrade.
+func checkPublisherDuplicates(database *gorm.DB) error {
+	if !database.Migrator().HasTable(&models.Publisher{}) {
+		return nil
+	}
+
+	catalog := "''"
+	if database.Migrator().HasColumn(&models.Publisher{}, "catalog_id") {
+		catalog = "COALESCE(catalog_id, '')"
+	}
+
+	var found []string
+
+	for _, column := range []string{"description", "alternative_id"} {
+		if database.Migrator().HasIndex(&models.Publisher{}, "idx_publishers_"+column+"_catalog") {
+			continue
+		}
+
+		var duplicates []struct {
+			Value     string
+			CatalogID string
+		}
+
+		// The publishers in the trash keep their values taken
+		if err := database.Unscoped().Model(&models.Publisher{}).
+			Select(column + " AS value, " + catalog + " AS catalog_id").
+			Where(column + " IS NOT NULL").
+			Group(column + ", " + catalog).
+			Having("COUNT(*) > 1").
+			Scan(&duplicates).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		for _, duplicate := range duplicates {
+			var ids []string
+
+			if err := database.Unscoped().Model(&models.Publisher{}).
+				Where(column+" = ? AND "+catalog+" = ?", duplicate.Value, duplicate.CatalogID).
+				Order("id").
+				Pluck("id", &ids).Error; err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			catalogName := "the root catalog"
+			if duplicate.CatalogID != "" {
+				catalogName = "catalog " + duplicate.CatalogID
+			}
+
+			found = append(found, fmt.Sprintf(
+				"%s %q in %s: %s", column, duplicate.Value, catalogName, strings.Join(ids, ", "),
+			))
+		}
+	}
+
+	if len(found) > 0 {
+		return fmt.Errorf(
+			"%w: %s (merge them, or change their description or alternativeId)",
+			errDuplicatePublishers, strings.Join(found, "; "),
+		)
+	}
+
+	return nil
+}
+
+// migratePublisherIndexes drops the indexes keeping the description and the
+// alternativeId of the publishers unique across all the catalogs, now unique
+// in their catalog.
+func migratePublisherIndexes(database *gorm.DB) error {
+	for _, index := range []string{"idx_publishers_description", "idx_publishers_alternative_id"} {
+		if !database.Migrator().HasIndex(&models.Publisher{}, index) {
+			continue
+		}
+
+		if err := database.Migrator().DropIndex(&models.Publisher{}, index); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
+
+// migrateURLCatalogs copies the catalog of the software to their URLs and
+// drops the index keeping the URLs unique across all the catalogs.
+func migrateURLCatalogs(database *gorm.DB) error {
+	return database.Transaction(func(tran *gorm.DB) error { //nolint:wrapcheck
+		if err := tran.Model(&models.SoftwareURL{}).
+			Where("software_id IN (?)", tran.Unscoped().Model(&models.Software{}).
+				Select("id").
+				Where("catalog_id IS NOT NULL")).
+			UpdateColumn("catalog_id", tran.Unscoped().Model(&models.Software{}).
+				Select("catalog_id").
+				Where("software.id = software_urls.software_id")).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if tran.Migrator().HasIndex(&models.SoftwareURL{}, "idx_software_urls_url") {
+			return tran.Migrator().DropIndex(&models.SoftwareURL{}, "idx_software_urls_url") //nolint:wrapcheck
+		}
+
+		return nil
+	})
+}
+
+// migrateCodeHostingCatalogs copies the catalog of the publishers to their
+// code hosting and drops the index keeping the URLs unique across all the
+// catalogs.
+func migrateCodeHostingCatalogs(database *gorm.DB) error {
+	return database.Transaction(func(tran *gorm.DB) error { //nolint:wrapcheck
+		if err := tran.Model(&models.CodeHosting{}).
+			Where("publisher_id IN (?)", tran.Unscoped().Model(&models.Publisher{}).
+				Select("id").
+				Where("catalog_id IS NOT NULL")).
+			UpdateColumn("catalog_id", tran.Unscoped().Model(&models.Publisher{}).
+				Select("catalog_id").
+				Where("publishers.id = publishers_code_hosting.publisher_id")).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if tran.Migrator().HasIndex(&models.CodeHosting{}, "idx_publishers_code_hosting_url") {
+			return tran.Migrator().DropIndex(&models.CodeHosting{}, "idx_publishers_code_hosting_url") //nolint:wrapcheck
+		}
+
+		return nil
+	})
+}
+
+// migrateVitality converts the free-form vitality of the software to data
+// points, leaving it as it is. It's read as the comma-separated daily scores written
+// by publiccode-crawler, the last one of the day the software was last
+// updated, skipping the values that aren't scores.
+func migrateVitality(database *gorm.DB) error {
+	var software []struct {
+		ID        string
+		Vitality  string
+		UpdatedAt time.Time
+	}
+
+	if err := database.Table("software").
+		Select("id, vitality, updated_at").
+		Where("vitality IS NOT NULL").
+		Scan(&software).Error; err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return database.Transaction(func(tran *gorm.DB) error { //nolint:wrapcheck
+		for _, row := range software {
+			scores := strings.Split(row.Vitality, ",")
+			lastDay := row.UpdatedAt.UTC().Truncate(24 * time.Hour)
+
+			points := make([]models.SoftwareVitality, 0, len(scores))
+
+			for idx, raw := range scores {
+				score, err := strconv.Atoi(strings.TrimSpace(raw))
+				if err != nil || score < 0 || score > 100 {
+					continue
+				}
+
+				points = append(points, models.SoftwareVitality{
+					SoftwareID: row.ID,
+					Date:       lastDay.AddDate(0, 0, idx-len(scores)+1),
+					Score:      score,
+				})
+			}
+
+			if len(points) == 0 {
+				continue
+			}
+
+			if err := tran.CreateInBatches(&points, 100).Error; err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			if err := tran.Table("software").
+				Where("id = ?", row.ID).
+				UpdateColumn("vitality_score", points[len(points)-1].Score).Error; err != nil {
+				return err //nolint:wrapcheck
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,203 @@
+// Package diff compares two JSON documents, fe. two revisions of a
+// resource, and lists the changes between them by JSON Pointer.
+//
+// Fields holding a YAML document, like publiccodeYml, can be compared by
+// their keys instead of as a single string.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+)
+
+// Change is a difference between two documents at Path, a JSON Pointer
+// (RFC 6901). From is the value before the change, To the value after it.
+type Change struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From any    `json:"from,omitempty"`
+	To   any    `json:"to,omitempty"`
+}
+
+// JSON returns the changes from the JSON document from to the JSON
+// document to. An empty document is compared as null.
+//
+// yamlFields are the top level fields holding a YAML document: when both
+// sides parse as a YAML mapping they're compared key by key, otherwise as
+// plain strings.
+func JSON(from []byte, to []byte, yamlFields ...string) ([]Change, error) {
+	fromDoc, err := decode(from)
+	if err != nil {
+		return nil, err
+	}
+
+	toDoc, err := decode(to)
+	if err != nil {
+		return nil, err
+	}
+
+	fromMap, fromIsMap := fromDoc.(map[string]any)
+	toMap, toIsMap := toDoc.(map[string]any)
+
+	if fromIsMap && toIsMap {
+		for _, field := range yamlFields {
+			expandYAML(fromMap, toMap, field)
+		}
+	}
+
+	changes := []Change{}
+	compare(&changes, "", fromDoc, toDoc)
+
+	return changes, nil
+}
+
+func decode(doc []byte) (any, error) {
+	if len(doc) == 0 {
+		return nil, nil
+	}
+
+	var value any
+	if err := json.Unmarshal(doc, &value); err != nil {
+		return nil, fmt.Errorf("can't decode document: %w", err)
+	}
+
+	return value, nil
+}
+
+// expandYAML replaces field with its parsed YAML document in both from and
+// to, only if both are YAML mappings so the values aren't compared across
+// types.
+func expandYAML(from map[string]any, to map[string]any, field string) {
+	fromYAML, fromOk := parseYAML(from[field])
+	toYAML, toOk := parseYAML(to[field])
+
+	if fromOk && toOk {
+		from[field] = fromYAML
+		to[field] = toYAML
+	}
+}
+
+func parseYAML(value any) (map[string]any, bool) {
+	str, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+
+	var doc any
+	if err := yaml.Unmarshal([]byte(str), &doc); err != nil {
+		return nil, false
+	}
+
+	mapping, ok := normalize(doc).(map[string]any)
+
+	return mapping, ok
+}
+
+// normalize converts a decoded YAML value to the types of a decoded JSON
+// one, so the changes can be marshalled: string keys and RFC 3339 times.
+func normalize(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, item := range value {
+			value[key] = normalize(item)
+		}
+
+		return value
+	case map[any]any:
+		mapping := make(map[string]any, len(value))
+		for key, item := range value {
+			mapping[fmt.Sprint(key)] = normalize(item)
+		}
+
+		return mapping
+	case []any:
+		for i, item := range value {
+			value[i] = normalize(item)
+		}
+
+		return value
+	case time.Time:
+		return value.Format(time.RFC3339)
+	default:
+		return value
+	}
+}
+
+func compare(changes *[]Change, path string, from any, to any) {
+	switch {
+	case from == nil && to == nil:
+		return
+	case from == nil:
+		*changes = append(*changes, Change{Op: OpAdd, Path: path, To: to})
+
+		return
+	case to == nil:
+		*changes = append(*changes, Change{Op: OpRemove, Path: path, From: from})
+
+		return
+	}
+
+	fromMap, fromIsMap := from.(map[string]any)
+	toMap, toIsMap := to.(map[string]any)
+
+	if fromIsMap && toIsMap {
+		keys := slices.Collect(maps.Keys(fromMap))
+		for key := range toMap {
+			if _, exists := fromMap[key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			compare(changes, path+"/"+escape(key), fromMap[key], toMap[key])
+		}
+
+		return
+	}
+
+	fromSlice, fromIsSlice := from.([]any)
+	toSlice, toIsSlice := to.([]any)
+
+	if fromIsSlice && toIsSlice {
+		for i := range max(len(fromSlice), len(toSlice)) {
+			var fromItem, toItem any
+
+			if i < len(fromSlice) {
+				fromItem = fromSlice[i]
+			}
+
+			if i < len(toSlice) {
+				toItem = toSlice[i]
+			}
+
+			compare(changes, path+"/"+strconv.Itoa(i), fromItem, toItem)
+		}
+
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, Change{Op: OpReplace, Path: path, From: from, To: to})
+	}
+}
+
+// escape escapes a key as a JSON Pointer reference token.
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
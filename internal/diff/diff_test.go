@@ -0,0 +1,70 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONNoChanges(t *testing.T) {
+	changes, err := JSON([]byte(`{"a": 1, "b": [true]}`), []byte(`{"b": [true], "a": 1}`))
+
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestJSONChanges(t *testing.T) {
+	changes, err := JSON(
+		[]byte(`{"active": true, "aliases": ["a", "b"], "vitality": null, "a/b": 1}`),
+		[]byte(`{"active": false, "aliases": ["a"], "vitality": "90", "catalogId": "x", "a/b": 1}`),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Op: OpReplace, Path: "/active", From: true, To: false},
+		{Op: OpRemove, Path: "/aliases/1", From: "b"},
+		{Op: OpAdd, Path: "/catalogId", To: "x"},
+		{Op: OpAdd, Path: "/vitality", To: "90"},
+	}, changes)
+}
+
+func TestJSONEmptyDocument(t *testing.T) {
+	changes, err := JSON(nil, []byte(`{"a": 1}`))
+
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{Op: OpAdd, Path: "", To: map[string]any{"a": 1.0}}}, changes)
+}
+
+func TestJSONYAMLFields(t *testing.T) {
+	changes, err := JSON(
+		[]byte(`{"publiccodeYml": "name: Medusa\nreleaseDate: 2022-01-01\nlegal:\n  license: MIT\n"}`),
+		[]byte(`{"publiccodeYml": "name: Medusa\nreleaseDate: 2023-01-01\nlegal: {license: AGPL-3.0-or-later}"}`),
+		"publiccodeYml",
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Op: OpReplace, Path: "/publiccodeYml/legal/license", From: "MIT", To: "AGPL-3.0-or-later"},
+		{Op: OpReplace, Path: "/publiccodeYml/releaseDate", From: "2022-01-01T00:00:00Z", To: "2023-01-01T00:00:00Z"},
+	}, changes)
+}
+
+func TestJSONInvalidYAMLFields(t *testing.T) {
+	changes, err := JSON(
+		[]byte(`{"publiccodeYml": "-"}`),
+		[]byte(`{"publiccodeYml": "name: Medusa"}`),
+		"publiccodeYml",
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Op: OpReplace, Path: "/publiccodeYml", From: "-", To: "name: Medusa"},
+	}, changes)
+}
+
+func TestJSONInvalidDocument(t *testing.T) {
+	_, err := JSON([]byte(`{`), []byte(`{}`))
+
+	assert.Error(t, err)
+}
@@ -3,7 +3,9 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/url"
+	"slices"
 	"sort"
 	"time"
 
@@ -12,6 +14,10 @@ import (
 	"github.com/italia/developers-italia-api/internal/common"
 	"github.com/italia/developers-italia-api/internal/handlers/general"
 	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/italia/developers-italia-api/internal/ownership"
+	"github.com/italia/developers-italia-api/internal/publiccode"
+	"github.com/italia/developers-italia-api/internal/sources"
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
 	"gorm.io/gorm"
 )
 
@@ -19,19 +25,36 @@ import (
 // catalog (resources with catalog_id IS NULL).
 const rootCatalogID = "∅"
 
+var errCatalogTrashed = errors.New("Catalog is in the trash")
+
 type CatalogInterface interface { //nolint:interfacebloat
 	GetCatalogs(ctx *fiber.Ctx) error
 	GetCatalog(ctx *fiber.Ctx) error
 	PostCatalog(ctx *fiber.Ctx) error
 	PatchCatalog(ctx *fiber.Ctx) error
 	DeleteCatalog(ctx *fiber.Ctx) error
+	RestoreCatalog(ctx *fiber.Ctx) error
+	GetCatalogDeletion(ctx *fiber.Ctx) error
+	GetCatalogDrivers(ctx *fiber.Ctx) error
 
 	GetCatalogPublishers(ctx *fiber.Ctx) error
 	PostCatalogPublisher(ctx *fiber.Ctx) error
+	GetCatalogPublisher(ctx *fiber.Ctx) error
 	PatchCatalogPublisher(ctx *fiber.Ctx) error
+	DeleteCatalogPublisher(ctx *fiber.Ctx) error
 	GetCatalogSoftware(ctx *fiber.Ctx) error
+	GetCatalogSoftwareFacets(ctx *fiber.Ctx) error
+	GetCatalogSingleSoftware(ctx *fiber.Ctx) error
 	PostCatalogSoftware(ctx *fiber.Ctx) error
+	PutCatalogSoftware(ctx *fiber.Ctx) error
 	PatchCatalogSoftware(ctx *fiber.Ctx) error
+	DeleteCatalogSoftware(ctx *fiber.Ctx) error
+	GetCatalogSoftwareAnalysis(ctx *fiber.Ctx) error
+	PatchCatalogSoftwareAnalysis(ctx *fiber.Ctx) error
+	MoveCatalogPublisher(ctx *fiber.Ctx) error
+	MoveCatalogPublishersBulk(ctx *fiber.Ctx) error
+	MoveCatalogSoftware(ctx *fiber.Ctx) error
+	MoveCatalogSoftwareBulk(ctx *fiber.Ctx) error
 
 	GetCatalogAnalysis(ctx *fiber.Ctx) error
 	PatchCatalogAnalysis(ctx *fiber.Ctx) error
@@ -69,6 +92,45 @@ func catalogScope(catalog *models.Catalog) func(*gorm.DB) *gorm.DB {
 	}
 }
 
+// activeCatalogScope returns a GORM scope that filters by the catalog being
+// active, the root one too if materialized. Catalogs in the trash count as
+// active, so their resources are listed in the trash with them.
+func activeCatalogScope(gormdb *gorm.DB) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		active := gormdb.Unscoped().Model(&models.Catalog{}).Select("id").Scopes(models.Active)
+		inactiveRoot := gormdb.Model(&models.Catalog{}).
+			Select("id").
+			Where("alternative_id = ? AND active = ?", rootCatalogID, false)
+
+		return db.Where("catalog_id IN (?) OR (catalog_id IS NULL AND NOT EXISTS (?))", active, inactiveRoot)
+	}
+}
+
+// catalogListFilters filters stmt, listing software or publishers of all the
+// catalogs, by the catalog in the `catalog` query parameter, its id or
+// alternativeId. The ones of inactive catalogs are left out unless `all` is
+// set, like inactive resources.
+func catalogListFilters(ctx *fiber.Ctx, gormdb *gorm.DB, stmt *gorm.DB, errMsg string) (*gorm.DB, error) {
+	if id := ctx.Query("catalog"); id != "" {
+		catalog, err := resolveCatalog(gormdb, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, common.Error(fiber.StatusUnprocessableEntity, errMsg, "Catalog was not found")
+			}
+
+			return nil, common.InternalServerError(errMsg)
+		}
+
+		stmt = stmt.Scopes(catalogScope(catalog))
+	}
+
+	if all := ctx.QueryBool("all", false); !all {
+		stmt = stmt.Scopes(activeCatalogScope(gormdb))
+	}
+
+	return stmt, nil
+}
+
 // GetCatalogs gets the list of all catalogs.
 func (c *Catalog) GetCatalogs(ctx *fiber.Ctx) error {
 	var catalogs []models.Catalog
@@ -82,6 +144,10 @@ func (c *Catalog) GetCatalogs(ctx *fiber.Ctx) error {
 		stmt = stmt.Scopes(models.Active)
 	}
 
+	if ctx.QueryBool("deleted", false) {
+		stmt = stmt.Scopes(models.Deleted)
+	}
+
 	paginator, err := general.NewPaginator(ctx)
 	if err != nil {
 		return common.Error(fiber.StatusUnprocessableEntity, "can't get Catalogs", err.Error())
@@ -107,10 +173,33 @@ func (c *Catalog) GetCatalogs(ctx *fiber.Ctx) error {
 	return ctx.JSON(fiber.Map{"data": &catalogs, "links": general.NewPaginationLinks(ctx.Queries(), cursor)})
 }
 
+// GetCatalogDrivers gets the drivers the sources of catalogs can use, with
+// the URL and the arguments they take.
+func (c *Catalog) GetCatalogDrivers(ctx *fiber.Ctx) error {
+	drivers := sources.Drivers()
+
+	schemas := make([]sources.Schema, 0, len(drivers))
+	for _, driver := range drivers {
+		schemas = append(schemas, driver.Schema())
+	}
+
+	return ctx.JSON(fiber.Map{"data": &schemas})
+}
+
 // GetCatalog gets the catalog with the given id.
 func (c *Catalog) GetCatalog(ctx *fiber.Ctx) error {
 	id, _ := url.PathUnescape(ctx.Params("id"))
 
+	if ctx.Query("asOf") != "" {
+		// Deleted catalogs are found in their revisions by id only
+		catalog := models.Catalog{ID: id}
+		if resolved, err := resolveCatalog(c.db, id); err == nil && resolved != nil {
+			catalog.ID = resolved.ID
+		}
+
+		return getAsOf(ctx, c.db, catalog, "can't get Catalog", "Catalog was not found")
+	}
+
 	catalog, err := resolveCatalog(c.db, id, "Sources")
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -153,6 +242,10 @@ func (c *Catalog) PostCatalog(ctx *fiber.Ctx) error {
 		return common.Error(fiber.StatusUnprocessableEntity, errMsg, "sources is required")
 	}
 
+	if err := validateSources(request.Sources, nil, errMsg); err != nil {
+		return err
+	}
+
 	sources := buildSources(request.Sources)
 
 	catalog := &models.Catalog{
@@ -165,7 +258,13 @@ func (c *Catalog) PostCatalog(ctx *fiber.Ctx) error {
 		Sources:             sources,
 	}
 
-	if err := c.db.Create(catalog).Error; err != nil {
+	if err := c.db.Transaction(func(tran *gorm.DB) error {
+		if err := tran.Create(catalog).Error; err != nil {
+			return err
+		}
+
+		return saveRevision(tran, common.EventTypeCreate, catalog)
+	}); err != nil {
 		if field := common.DuplicateField(err); field != nil {
 			detail := alreadyExists
 			if *field != "" {
@@ -241,6 +340,10 @@ func (c *Catalog) PatchCatalog(ctx *fiber.Ctx) error { //nolint:cyclop,funlen
 		return common.Error(fiber.StatusUnprocessableEntity, errMsg, "sources must not be empty")
 	}
 
+	if err := validateSources(sourcesInput, catalog.Sources, errMsg); err != nil {
+		return err
+	}
+
 	if err := c.db.Transaction(func(tran *gorm.DB) error {
 		sources, err := syncSources(tran, catalog, sourcesInput)
 		if err != nil {
@@ -255,7 +358,12 @@ func (c *Catalog) PatchCatalog(ctx *fiber.Ctx) error { //nolint:cyclop,funlen
 
 		updatedCatalog.Sources = sources
 
-		return nil
+		// Sort the sources to always have a consistent output
+		sort.Slice(updatedCatalog.Sources, func(a int, b int) bool {
+			return updatedCatalog.Sources[a].URL < updatedCatalog.Sources[b].URL
+		})
+
+		return saveRevision(tran, common.EventTypeUpdate, updatedCatalog)
 	}); err != nil {
 		if field := common.DuplicateField(err); field != nil {
 			detail := alreadyExists
@@ -272,8 +380,9 @@ func (c *Catalog) PatchCatalog(ctx *fiber.Ctx) error { //nolint:cyclop,funlen
 	return ctx.JSON(&updatedCatalog)
 }
 
-// DeleteCatalog deletes the catalog with the given id.
-// Returns 409 if the catalog still has associated publishers or software.
+// DeleteCatalog moves the catalog with the given id to the trash.
+// Returns 409 if the catalog still has associated publishers or software,
+// unless `cascade` is true (see deleteCatalogCascade).
 // On the root (∅) the count of attached resources is taken from rows with
 // catalog_id IS NULL, since root resources are never tied to the row's UUID.
 func (c *Catalog) DeleteCatalog(ctx *fiber.Ctx) error { //nolint:cyclop
@@ -296,6 +405,10 @@ func (c *Catalog) DeleteCatalog(ctx *fiber.Ctx) error { //nolint:cyclop
 
 	catalog := *resolved
 
+	if ctx.QueryBool("cascade", false) {
+		return c.deleteCatalogCascade(ctx, catalog)
+	}
+
 	var conflictErr error
 
 	if err := c.db.Transaction(func(tran *gorm.DB) error {
@@ -317,11 +430,12 @@ func (c *Catalog) DeleteCatalog(ctx *fiber.Ctx) error { //nolint:cyclop
 			return nil
 		}
 
-		if err := tran.Where("catalog_id = ?", catalog.ID).Delete(&models.CatalogSource{}).Error; err != nil {
+		// The sources and the runs are kept, to restore it
+		if err := tran.Delete(&catalog).Error; err != nil {
 			return err
 		}
 
-		return tran.Where("id = ?", catalog.ID).Delete(&models.Catalog{}).Error
+		return saveRevision(tran, common.EventTypeDelete, catalog)
 	}); err != nil {
 		return common.Error(fiber.StatusInternalServerError, errMsg, "db error")
 	}
@@ -333,6 +447,46 @@ func (c *Catalog) DeleteCatalog(ctx *fiber.Ctx) error { //nolint:cyclop
 	return ctx.SendStatus(fiber.StatusNoContent)
 }
 
+// RestoreCatalog restores the catalog with the given id from the trash,
+// with its sources and runs, and the software and publishers moved there
+// by deleting it with cascade.
+func (c *Catalog) RestoreCatalog(ctx *fiber.Ctx) error {
+	const errMsg = "can't restore Catalog"
+
+	catalog := models.Catalog{}
+	id, _ := url.PathUnescape(ctx.Params("id"))
+
+	if err := c.db.Transaction(func(tran *gorm.DB) error {
+		if err := tran.Scopes(models.Deleted).
+			First(&catalog, "id = ? OR alternative_id = ?", id, id).Error; err != nil {
+			return err
+		}
+
+		if err := tran.Unscoped().Model(&models.Catalog{ID: catalog.ID}).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		if err := restoreCatalogEntities(tran, catalog); err != nil {
+			return err
+		}
+
+		catalog = models.Catalog{ID: catalog.ID}
+		if err := tran.Preload("Sources").First(&catalog).Error; err != nil {
+			return err
+		}
+
+		return saveRevision(tran, common.EventTypeUpdate, catalog)
+	}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Catalog was not found in the trash")
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(&catalog)
+}
+
 // GetCatalogPublishers lists publishers belonging to the given catalog.
 func (c *Catalog) GetCatalogPublishers(ctx *fiber.Ctx) error {
 	id := ctx.Params("id")
@@ -421,20 +575,29 @@ func (c *Catalog) PostCatalogPublisher(ctx *fiber.Ctx) error { //nolint:cyclop
 	for _, codeHost := range request.CodeHosting {
 		publisher.CodeHosting = append(publisher.CodeHosting,
 			models.CodeHosting{
-				ID:    utils.UUIDv4(),
-				URL:   common.NormalizeURL(codeHost.URL),
-				Group: codeHost.Group,
+				ID:        utils.UUIDv4(),
+				URL:       common.NormalizeURL(codeHost.URL),
+				Group:     codeHost.Group,
+				CatalogID: urlCatalogID(catalogID),
 			})
 	}
 
 	if err := c.db.Transaction(func(tran *gorm.DB) error {
 		if request.AlternativeID != nil {
-			if err := checkAlternativeIDConflict(tran, *request.AlternativeID); err != nil {
+			if err := checkAlternativeIDConflict(tran, catalogID, *request.AlternativeID); err != nil {
 				return err
 			}
 		}
 
-		return tran.Create(&publisher).Error
+		if err := tran.Create(&publisher).Error; err != nil {
+			return err
+		}
+
+		if err := ownership.Relink(tran, *publisher); err != nil {
+			return err
+		}
+
+		return saveRevision(tran, common.EventTypeCreate, publisher)
 	}); err != nil {
 		var idConflict idConflictError
 
@@ -457,39 +620,44 @@ func (c *Catalog) PostCatalogPublisher(ctx *fiber.Ctx) error { //nolint:cyclop
 	return ctx.JSON(publisher)
 }
 
-// PatchCatalogPublisher updates a publisher that belongs to the given catalog.
-func (c *Catalog) PatchCatalogPublisher(ctx *fiber.Ctx) error { //nolint:cyclop,funlen,gocognit
-	const errMsg = "can't update Publisher"
+// GetCatalogPublisher gets the publisher with publisherId, which can also be
+// its alternativeId, that belongs to the given catalog.
+func (c *Catalog) GetCatalogPublisher(ctx *fiber.Ctx) error {
+	publisher, err := catalogPublisher(ctx, c.db, "can't get Publisher")
+	if err != nil {
+		return err
+	}
 
-	catalog, err := resolveCatalog(c.db, ctx.Params("id"))
+	return ctx.JSON(publisher)
+}
+
+// DeleteCatalogPublisher moves the publisher with publisherId that belongs to
+// the given catalog to the trash.
+func (c *Catalog) DeleteCatalogPublisher(ctx *fiber.Ctx) error {
+	const errMsg = "can't delete Publisher"
+
+	publisher, err := catalogPublisher(ctx, c.db, errMsg)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return common.Error(fiber.StatusNotFound, errMsg, "Catalog was not found")
-		}
+		return err
+	}
 
-		return common.Error(fiber.StatusInternalServerError, errMsg, fiber.ErrInternalServerError.Message)
+	if err := deletePublisher(c.db, *publisher); err != nil {
+		return common.Error(fiber.StatusInternalServerError, errMsg, "db error")
 	}
 
-	publisher := models.Publisher{}
-	publisherID := ctx.Params("publisherId")
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
 
-	if err := c.db.Preload("CodeHosting").
-		First(&publisher, "id = ? or alternative_id = ?", publisherID, publisherID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return common.Error(fiber.StatusNotFound, errMsg, "Publisher was not found")
-		}
+// PatchCatalogPublisher updates a publisher that belongs to the given catalog.
+func (c *Catalog) PatchCatalogPublisher(ctx *fiber.Ctx) error { //nolint:cyclop,funlen,gocognit
+	const errMsg = "can't update Publisher"
 
-		return common.Error(fiber.StatusInternalServerError, errMsg, fiber.ErrInternalServerError.Message)
+	found, err := catalogPublisher(ctx, c.db, errMsg)
+	if err != nil {
+		return err
 	}
 
-	// Verify the publisher belongs to the resolved catalog.
-	if isRoot(catalog) {
-		if publisher.CatalogID != nil {
-			return common.Error(fiber.StatusNotFound, errMsg, "Publisher was not found")
-		}
-	} else if publisher.CatalogID == nil || *publisher.CatalogID != catalog.ID {
-		return common.Error(fiber.StatusNotFound, errMsg, "Publisher was not found")
-	}
+	publisher := *found
 
 	contentType := ctx.Get(fiber.HeaderContentType)
 	if contentType != common.ContentTypeJSONPatch {
@@ -516,7 +684,7 @@ func (c *Catalog) PatchCatalogPublisher(ctx *fiber.Ctx) error { //nolint:cyclop,
 	if err := c.db.Transaction(func(tran *gorm.DB) error { //nolint:dupl
 		if updatedPublisher.AlternativeID != nil &&
 			(publisher.AlternativeID == nil || *updatedPublisher.AlternativeID != *publisher.AlternativeID) {
-			if err := checkAlternativeIDConflict(tran, *updatedPublisher.AlternativeID); err != nil {
+			if err := checkAlternativeIDConflict(tran, publisher.CatalogID, *updatedPublisher.AlternativeID); err != nil {
 				return err
 			}
 		}
@@ -538,7 +706,15 @@ func (c *Catalog) PatchCatalogPublisher(ctx *fiber.Ctx) error { //nolint:cyclop,
 
 		publisher.CodeHosting = codeHosting
 
-		return nil
+		sort.Slice(publisher.CodeHosting, func(a int, b int) bool {
+			return publisher.CodeHosting[a].URL < publisher.CodeHosting[b].URL
+		})
+
+		if err := ownership.Relink(tran, publisher); err != nil {
+			return err
+		}
+
+		return saveRevision(tran, common.EventTypeUpdate, publisher)
 	}); err != nil {
 		var idConflict idConflictError
 
@@ -555,13 +731,9 @@ func (c *Catalog) PatchCatalogPublisher(ctx *fiber.Ctx) error { //nolint:cyclop,
 			return common.Error(fiber.StatusConflict, errMsg, detail)
 		}
 
-		return common.Error(fiber.StatusInternalServerError, errMsg, err.Error())
+		return common.InternalServerError(errMsg)
 	}
 
-	sort.Slice(publisher.CodeHosting, func(a int, b int) bool {
-		return publisher.CodeHosting[a].URL < publisher.CodeHosting[b].URL
-	})
-
 	return ctx.JSON(&publisher)
 }
 
@@ -585,65 +757,58 @@ func (c *Catalog) PostCatalogSoftware(ctx *fiber.Ctx) error {
 		return err //nolint:wrapcheck
 	}
 
+	publicCode, err := parsePubliccode(ctx, softwareReq.PubliccodeYml, errMsg)
+	if err != nil {
+		return err
+	}
+
 	var catalogID *string
 	if !isRoot(catalog) {
 		catalogID = &catalog.ID
 	}
 
-	aliases := []models.SoftwareURL{}
-	for _, u := range softwareReq.Aliases {
-		aliases = append(aliases, models.SoftwareURL{ID: utils.UUIDv4(), URL: common.NormalizeURL(u)})
-	}
+	software := newSoftware(softwareReq, catalogID)
 
-	url := models.SoftwareURL{ID: utils.UUIDv4(), URL: common.NormalizeURL(softwareReq.URL)}
-	software := &models.Software{
-		ID:            utils.UUIDv4(),
-		URL:           url,
-		SoftwareURLID: url.ID,
-		CatalogID:     catalogID,
-		Aliases:       aliases,
-		PubliccodeYml: softwareReq.PubliccodeYml,
-		Active:        softwareReq.Active,
-		Vitality:      softwareReq.Vitality,
-	}
+	publiccode.SetFields(&software, publicCode)
 
-	if err := c.db.Create(software).Error; err != nil {
-		return common.Error(fiber.StatusInternalServerError, errMsg, err.Error())
+	if err := c.db.Transaction(func(tran *gorm.DB) error {
+		return createSoftware(tran, &software)
+	}); err != nil {
+		return softwareSaveError(err, errMsg)
 	}
 
-	return ctx.JSON(software)
+	return ctx.JSON(&software)
 }
 
-// PatchCatalogSoftware updates software that belongs to the given catalog.
-func (c *Catalog) PatchCatalogSoftware(ctx *fiber.Ctx) error { //nolint:funlen,cyclop
-	const errMsg = "can't update Software"
-
+// PutCatalogSoftware creates software in the given catalog with the URL in
+// the `url` query parameter, or updates the one of the catalog having it as
+// URL or alias.
+func (c *Catalog) PutCatalogSoftware(ctx *fiber.Ctx) error {
 	catalog, err := resolveCatalog(c.db, ctx.Params("id"))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return common.Error(fiber.StatusNotFound, errMsg, "Catalog was not found")
+			return common.Error(fiber.StatusNotFound, "can't create or update Software", "Catalog was not found")
 		}
 
-		return common.Error(fiber.StatusInternalServerError, errMsg, fiber.ErrInternalServerError.Message)
+		return common.InternalServerError("can't create or update Software")
 	}
 
-	software := models.Software{}
+	return upsertSoftware(ctx, c.db, catalog, false)
+}
 
-	if err := loadSoftware(c.db, &software, ctx.Params("softwareId")); err != nil {
-		if errors.Is(err, errLoadNotFound) {
-			return common.Error(fiber.StatusNotFound, errMsg, "Software was not found")
-		}
+// PatchCatalogSoftware updates software that belongs to the given catalog.
+func (c *Catalog) PatchCatalogSoftware(ctx *fiber.Ctx) error { //nolint:funlen,cyclop
+	const errMsg = "can't update Software"
 
-		return common.Error(fiber.StatusInternalServerError, errMsg, fiber.ErrInternalServerError.Message)
+	found, err := catalogSoftware(ctx, c.db, errMsg)
+	if err != nil {
+		return err
 	}
 
-	// Verify the software belongs to the resolved catalog.
-	if isRoot(catalog) {
-		if software.CatalogID != nil {
-			return common.Error(fiber.StatusNotFound, errMsg, "Software was not found")
-		}
-	} else if software.CatalogID == nil || *software.CatalogID != catalog.ID {
-		return common.Error(fiber.StatusNotFound, errMsg, "Software was not found")
+	software := *found
+
+	if err := loadSoftware(c.db, &software, software.ID); err != nil {
+		return common.Error(fiber.StatusInternalServerError, errMsg, fiber.ErrInternalServerError.Message)
 	}
 
 	contentType := ctx.Get(fiber.HeaderContentType)
@@ -658,7 +823,17 @@ func (c *Catalog) PatchCatalogSoftware(ctx *fiber.Ctx) error { //nolint:funlen,c
 		return common.Error(patchErr.Code, errMsg, patchErr.Error())
 	}
 
-	updatedSoftware.CatalogID = software.CatalogID
+	publiccodeChanged := updatedSoftware.PubliccodeYml != software.PubliccodeYml
+	if publiccodeChanged {
+		publicCode, err := parsePubliccode(ctx, updatedSoftware.PubliccodeYml, errMsg)
+		if err != nil {
+			return err
+		}
+
+		publiccode.SetFields(&updatedSoftware, publicCode)
+	}
+
+	keepReadOnlyFields(software, &updatedSoftware)
 
 	updatedSoftware.URL.URL = common.NormalizeURL(updatedSoftware.URL.URL)
 
@@ -668,34 +843,7 @@ func (c *Catalog) PatchCatalogSoftware(ctx *fiber.Ctx) error { //nolint:funlen,c
 	}
 
 	if err := c.db.Transaction(func(tran *gorm.DB) error {
-		//nolint:gocritic // it's fine, we want to append to another slice
-		currentURLs := append(software.Aliases, software.URL)
-
-		updatedURL, aliases, err := syncAliases(
-			tran,
-			software.ID,
-			currentURLs,
-			updatedSoftware.URL.URL,
-			expectedAliases,
-		)
-		if err != nil {
-			return err
-		}
-
-		updatedSoftware.SoftwareURLID = updatedURL.ID
-		updatedSoftware.URL = *updatedURL
-
-		// Set Aliases to a zero value, so it's not touched by gorm's Update(),
-		// because we handle the alias manually.
-		updatedSoftware.Aliases = []models.SoftwareURL{}
-
-		if err := tran.Updates(&updatedSoftware).Error; err != nil {
-			return err
-		}
-
-		updatedSoftware.Aliases = aliases
-
-		return nil
+		return updateSoftware(tran, software, &updatedSoftware, expectedAliases, publiccodeChanged)
 	}); err != nil {
 		if field := common.DuplicateField(err); field != nil {
 			detail := alreadyExists
@@ -710,13 +858,48 @@ func (c *Catalog) PatchCatalogSoftware(ctx *fiber.Ctx) error { //nolint:funlen,c
 		return err
 	}
 
-	sort.Slice(updatedSoftware.Aliases, func(a int, b int) bool {
-		return updatedSoftware.Aliases[a].URL < updatedSoftware.Aliases[b].URL
-	})
-
 	return ctx.JSON(&updatedSoftware)
 }
 
+// GetCatalogSingleSoftware gets the software with softwareId that belongs to
+// the given catalog.
+func (c *Catalog) GetCatalogSingleSoftware(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Software"
+
+	software, err := catalogSoftware(ctx, c.db, errMsg)
+	if err != nil {
+		return err
+	}
+
+	if err := loadSoftware(c.db, software, software.ID); err != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(software)
+}
+
+// DeleteCatalogSoftware moves the software with softwareId that belongs to
+// the given catalog to the trash.
+func (c *Catalog) DeleteCatalogSoftware(ctx *fiber.Ctx) error {
+	const errMsg = "can't delete Software"
+
+	software, err := catalogSoftware(ctx, c.db, errMsg)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := deleteSoftware(c.db, *software)
+	if err != nil {
+		return common.Error(fiber.StatusInternalServerError, errMsg, "db error")
+	}
+
+	if !deleted {
+		return common.Error(fiber.StatusNotFound, errMsg, "Software was not found")
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
 // GetCatalogSoftware lists software belonging to the given catalog.
 //
 //nolint:cyclop // keeping request handling inline is clearer here
@@ -736,32 +919,17 @@ func (c *Catalog) GetCatalogSoftware(ctx *fiber.Ctx) error {
 
 	stmt := c.db.Preload("URL").Preload("Aliases").Scopes(catalogScope(catalog))
 
-	stmt, err = general.Clauses(ctx, stmt, "")
+	stmt, err = softwareListFilters(ctx, c.db, stmt, "can't get Software")
 	if err != nil {
-		return common.Error(fiber.StatusUnprocessableEntity, "can't get Software", err.Error())
-	}
-
-	if urlFilter := common.NormalizeURL(ctx.Query("url", "")); urlFilter != "" {
-		var softwareURL models.SoftwareURL
-
-		if e := c.db.First(&softwareURL, "url = ?", urlFilter).Error; e != nil {
-			if errors.Is(e, gorm.ErrRecordNotFound) {
-				return ctx.JSON(fiber.Map{"data": []any{}, "links": general.PaginationLinks{}})
-			}
-
-			return common.Error(
-				fiber.StatusInternalServerError, "can't get Software", fiber.ErrInternalServerError.Message,
-			)
-		}
-
-		stmt = stmt.Where("id = ?", softwareURL.SoftwareID)
+		return err
 	}
 
-	if all := ctx.QueryBool("all", false); !all {
-		stmt = stmt.Scopes(models.Active)
+	rules, err := softwareSortRules(ctx)
+	if err != nil {
+		return common.Error(fiber.StatusUnprocessableEntity, "can't get Software", err.Error())
 	}
 
-	paginator, err := general.NewPaginator(ctx)
+	paginator, err := general.NewPaginatorWithConfig(ctx, &paginator.Config{Rules: rules})
 	if err != nil {
 		return common.Error(fiber.StatusUnprocessableEntity, "can't get Software", err.Error())
 	}
@@ -786,6 +954,33 @@ func (c *Catalog) GetCatalogSoftware(ctx *fiber.Ctx) error {
 	return ctx.JSON(fiber.Map{"data": &software, "links": general.NewPaginationLinks(ctx.Queries(), cursor)})
 }
 
+// GetCatalogSoftwareFacets counts the software belonging to the given catalog
+// by the requested facets, with the filters of GetCatalogSoftware.
+func (c *Catalog) GetCatalogSoftwareFacets(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Software facets"
+
+	catalog, err := resolveCatalog(c.db, ctx.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Catalog was not found")
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	stmt, err := softwareListFilters(ctx, c.db, c.db.Model(&models.Software{}).Scopes(catalogScope(catalog)), errMsg)
+	if err != nil {
+		return err
+	}
+
+	counts, err := countFacets(ctx, c.db, stmt, errMsg)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(counts)
+}
+
 // buildSources converts SourceInput slice to CatalogSource models.
 func buildSources(inputs []common.SourceInput) []models.CatalogSource {
 	sources := make([]models.CatalogSource, 0, len(inputs))
@@ -802,6 +997,48 @@ func buildSources(inputs []common.SourceInput) []models.CatalogSource {
 	return sources
 }
 
+// validateSources returns the error response for the problems of the
+// sources in inputs with their driver, see sources.Validate. The ones left
+// as they are in existing aren't validated again, the driver and the args
+// missing in inputs being the existing ones like in syncSources.
+func validateSources(inputs []common.SourceInput, existing []models.CatalogSource, errMsg string) error {
+	existingByURL := make(map[string]models.CatalogSource, len(existing))
+	for _, src := range existing {
+		existingByURL[src.URL] = src
+	}
+
+	var problems []common.ValidationError
+
+	for i, inp := range inputs {
+		driver, args := inp.Driver, inp.Args
+
+		if src, ok := existingByURL[common.NormalizeURL(inp.URL)]; ok {
+			if driver == nil {
+				driver = src.Driver
+			}
+
+			if args == nil {
+				args = src.Args
+			}
+
+			if equalPtr(driver, src.Driver) && slices.Equal(args, src.Args) {
+				continue
+			}
+		}
+
+		for _, problem := range sources.Validate(driver, inp.URL, args) {
+			problem.Field = fmt.Sprintf("sources[%d].%s", i, problem.Field)
+			problems = append(problems, problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return common.ErrorWithValidationErrors(fiber.StatusUnprocessableEntity, errMsg, problems)
+	}
+
+	return nil
+}
+
 // syncSources brings the catalog_sources table in line with the desired state.
 // Sources are matched by URL; removed if absent, added if new.
 func syncSources( //nolint:cyclop,funlen
@@ -917,6 +1154,82 @@ func resolveCatalog(gormdb *gorm.DB, rawID string, preloads ...string) (*models.
 	return nil, dbErr
 }
 
+// catalogSoftware finds the software with softwareId in the catalog in the
+// path, returning the error response if either of them is not found.
+func catalogSoftware(ctx *fiber.Ctx, gormdb *gorm.DB, errMsg string) (*models.Software, error) {
+	catalog, err := resolveCatalog(gormdb, ctx.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.Error(fiber.StatusNotFound, errMsg, "Catalog was not found")
+		}
+
+		return nil, common.InternalServerError(errMsg)
+	}
+
+	software := models.Software{}
+
+	// Only the software of the resolved catalog
+	if err := gormdb.Scopes(catalogScope(catalog)).
+		First(&software, "id = ?", ctx.Params("softwareId")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.Error(fiber.StatusNotFound, errMsg, "Software was not found")
+		}
+
+		return nil, common.InternalServerError(errMsg)
+	}
+
+	return &software, nil
+}
+
+// catalogPublisher finds the publisher with publisherId, which can also be
+// its alternativeId, in the catalog in the path, returning the error response
+// if either of them is not found.
+func catalogPublisher(ctx *fiber.Ctx, gormdb *gorm.DB, errMsg string) (*models.Publisher, error) {
+	catalog, err := resolveCatalog(gormdb, ctx.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.Error(fiber.StatusNotFound, errMsg, "Catalog was not found")
+		}
+
+		return nil, common.InternalServerError(errMsg)
+	}
+
+	publisher := models.Publisher{}
+
+	// Only the publishers of the resolved catalog
+	if err := gormdb.Preload("CodeHosting").
+		Scopes(catalogScope(catalog), publisherScope(gormdb, ctx.Params("publisherId"))).
+		First(&publisher).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.Error(fiber.StatusNotFound, errMsg, "Publisher was not found")
+		}
+
+		return nil, common.InternalServerError(errMsg)
+	}
+
+	return &publisher, nil
+}
+
+// checkCatalogRestored returns errCatalogTrashed if the catalog with
+// catalogID is in the trash, so the resources in it can't be restored.
+func checkCatalogRestored(tran *gorm.DB, catalogID *string) error {
+	if catalogID == nil {
+		return nil
+	}
+
+	var count int64
+
+	if err := tran.Model(&models.Catalog{}).Where("id = ?", *catalogID).Count(&count).Error; err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if count == 0 {
+		return errCatalogTrashed
+	}
+
+	return nil
+}
+
 // GetCatalogAnalysis returns the analysis data for the catalog with the given id.
 func (c *Catalog) GetCatalogAnalysis(ctx *fiber.Ctx) error {
 	const errMsg = "can't get Catalog analysis"
@@ -979,3 +1292,32 @@ func (c *Catalog) PatchCatalogAnalysis(ctx *fiber.Ctx) error {
 
 	return ctx.JSON(merged)
 }
+
+// GetCatalogSoftwareAnalysis returns the analysis data for the software with
+// softwareId that belongs to the given catalog.
+func (c *Catalog) GetCatalogSoftwareAnalysis(ctx *fiber.Ctx) error {
+	software, err := catalogSoftware(ctx, c.db, "can't get Software analysis")
+	if err != nil {
+		return err
+	}
+
+	if software.Analysis == nil {
+		return ctx.JSON(common.AnalysisData{})
+	}
+
+	return ctx.JSON(software.Analysis)
+}
+
+// PatchCatalogSoftwareAnalysis merges the incoming analysis namespaces into
+// the stored analysis of the software with softwareId that belongs to the
+// given catalog.
+func (c *Catalog) PatchCatalogSoftwareAnalysis(ctx *fiber.Ctx) error {
+	const errMsg = "can't update Software analysis"
+
+	software, err := catalogSoftware(ctx, c.db, errMsg)
+	if err != nil {
+		return err
+	}
+
+	return patchSoftwareAnalysis(ctx, c.db, *software, errMsg)
+}
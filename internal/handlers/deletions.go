@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/italia/developers-italia-api/internal/ownership"
+	"github.com/italia/developers-italia-api/internal/trash"
+	"gorm.io/gorm"
+)
+
+const deletionBatchSize = 100
+
+// deletions tracks the cascading deletions of catalogs running in the
+// background, so shutdown can wait for them.
+//
+//nolint:gochecknoglobals // shared by all the Catalog handlers
+var deletions sync.WaitGroup
+
+// WaitDeletions waits for the cascading deletions of catalogs running in the
+// background. Call it on graceful shutdown, as each runs in a transaction
+// that would be rolled back.
+func WaitDeletions() {
+	deletions.Wait()
+}
+
+// FailInterruptedDeletions marks as failed the cascading deletions still
+// running, interrupted by a process killed before WaitDeletions returned.
+// Nothing was removed, as their transaction was rolled back. Call it on
+// startup.
+func FailInterruptedDeletions(gormdb *gorm.DB) error {
+	if err := gormdb.Model(&models.CatalogDeletion{}).
+		Where("status = ?", common.DeletionStatusRunning).
+		Update("status", common.DeletionStatusFailed).Error; err != nil {
+		return fmt.Errorf("can't fail interrupted Deletions: %w", err)
+	}
+
+	return nil
+}
+
+// GetCatalogDeletion gets a cascading deletion of the given catalog, also
+// once the catalog is in the trash.
+func (c *Catalog) GetCatalogDeletion(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Deletion"
+
+	catalogID, _ := url.PathUnescape(ctx.Params("id"))
+	deletion := models.CatalogDeletion{}
+
+	if err := c.db.
+		Where("catalog_id IN (?)", c.db.Unscoped().Model(&models.Catalog{}).
+			Select("id").
+			Where("id = ? OR alternative_id = ?", catalogID, catalogID)).
+		First(&deletion, "id = ?", ctx.Params("deletionId")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Deletion was not found")
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(&deletion)
+}
+
+// deleteCatalogCascade moves the catalog to the trash with its software and
+// publishers, see models.CatalogDeletion. Catalogs with more of them than
+// CatalogDeleteSyncMax are deleted in the background, responding 202 with
+// the deletion to poll.
+func (c *Catalog) deleteCatalogCascade(ctx *fiber.Ctx, catalog models.Catalog) error {
+	const errMsg = "can't delete Catalog"
+
+	// The root catalog would take all the software and publishers without one
+	if isRoot(&catalog) {
+		return common.Error(fiber.StatusUnprocessableEntity, errMsg, "the root Catalog can't be deleted with cascade")
+	}
+
+	var softwareCount, publisherCount int64
+
+	if err := c.db.Model(&models.Software{}).Scopes(catalogScope(&catalog)).Count(&softwareCount).Error; err != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	if err := c.db.Model(&models.Publisher{}).Scopes(catalogScope(&catalog)).Count(&publisherCount).Error; err != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	deletion := models.CatalogDeletion{
+		ID:        utils.UUIDv4(),
+		CatalogID: catalog.ID,
+		Status:    common.DeletionStatusRunning,
+	}
+
+	if err := c.db.Create(&deletion).Error; err != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	if softwareCount+publisherCount <= int64(common.EnvironmentConfig.CatalogDeleteSyncMax) {
+		runDeletion(c.db, &deletion, catalog)
+
+		if deletion.Status != common.DeletionStatusDone {
+			return common.InternalServerError(errMsg)
+		}
+
+		return ctx.JSON(&deletion)
+	}
+
+	background := deletion
+
+	deletions.Add(1)
+
+	go func() {
+		defer deletions.Done()
+
+		runDeletion(c.db, &background, catalog)
+	}()
+
+	ctx.Location("/v1/catalogs/" + catalog.ID + "/deletions/" + deletion.ID)
+
+	return ctx.Status(fiber.StatusAccepted).JSON(&deletion)
+}
+
+// runDeletion runs the cascading deletion of catalog in a transaction, and
+// stores its status and counts.
+func runDeletion(gormdb *gorm.DB, deletion *models.CatalogDeletion, catalog models.Catalog) {
+	err := gormdb.Transaction(func(tran *gorm.DB) error {
+		if err := trashCatalogSoftware(tran, deletion, catalog); err != nil {
+			return err
+		}
+
+		if err := trashCatalogPublishers(tran, deletion, catalog); err != nil {
+			return err
+		}
+
+		logs := tran.Unscoped().Where("entity_type = ? AND entity_id = ?", models.Catalog{}.TableName(), catalog.ID).
+			Delete(&models.Log{})
+		if logs.Error != nil {
+			return logs.Error //nolint:wrapcheck
+		}
+
+		deletion.Logs += int(logs.RowsAffected)
+
+		// The sources and the runs are kept, to restore it
+		if err := tran.Delete(&catalog).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return saveRevision(tran, common.EventTypeDelete, catalog)
+	})
+
+	if err != nil {
+		log.Printf("can't delete Catalog %s with cascade: %s", catalog.ID, err)
+
+		// Nothing was removed, the transaction was rolled back
+		*deletion = models.CatalogDeletion{
+			ID:        deletion.ID,
+			CatalogID: deletion.CatalogID,
+			Status:    common.DeletionStatusFailed,
+			CreatedAt: deletion.CreatedAt,
+		}
+	} else {
+		deletion.Status = common.DeletionStatusDone
+	}
+
+	if err := gormdb.Save(deletion).Error; err != nil {
+		log.Printf("can't save Deletion %s: %s", deletion.ID, err)
+	}
+}
+
+// trashCatalogSoftware moves the software of catalog to the trash, recording
+// it in deletion, and deletes their logs and webhooks.
+func trashCatalogSoftware(tran *gorm.DB, deletion *models.CatalogDeletion, catalog models.Catalog) error {
+	var software []models.Software
+
+	result := tran.Scopes(catalogScope(&catalog)).
+		FindInBatches(&software, deletionBatchSize, func(_ *gorm.DB, _ int) error {
+			ids := make([]string, 0, len(software))
+			for _, swr := range software {
+				ids = append(ids, swr.ID)
+			}
+
+			if err := recordEntities(tran, deletion, models.Software{}.TableName(), ids); err != nil {
+				return err
+			}
+
+			if err := deleteEntityRows(tran, deletion, models.Software{}.TableName(), ids); err != nil {
+				return err
+			}
+
+			var urls int64
+
+			if err := tran.Model(&models.SoftwareURL{}).Where("software_id IN ?", ids).Count(&urls).Error; err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			deletion.SoftwareURLs += int(urls)
+
+			for _, swr := range software {
+				if err := tran.Delete(&swr).Error; err != nil {
+					return err //nolint:wrapcheck
+				}
+
+				if err := saveRevision(tran, common.EventTypeDelete, swr); err != nil {
+					return err
+				}
+			}
+
+			deletion.Software += len(software)
+
+			return nil
+		})
+
+	return result.Error //nolint:wrapcheck
+}
+
+// trashCatalogPublishers moves the publishers of catalog to the trash,
+// recording them in deletion, and deletes their logs and webhooks. The software they owned might be owned by
+// another publisher now.
+func trashCatalogPublishers(tran *gorm.DB, deletion *models.CatalogDeletion, catalog models.Catalog) error {
+	var publishers []models.Publisher
+
+	result := tran.Scopes(catalogScope(&catalog)).
+		FindInBatches(&publishers, deletionBatchSize, func(_ *gorm.DB, _ int) error {
+			ids := make([]string, 0, len(publishers))
+			for _, publisher := range publishers {
+				ids = append(ids, publisher.ID)
+			}
+
+			if err := recordEntities(tran, deletion, models.Publisher{}.TableName(), ids); err != nil {
+				return err
+			}
+
+			if err := deleteEntityRows(tran, deletion, models.Publisher{}.TableName(), ids); err != nil {
+				return err
+			}
+
+			var codeHosting int64
+
+			if err := tran.Model(&models.CodeHosting{}).
+				Where("publisher_id IN ?", ids).
+				Count(&codeHosting).Error; err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			deletion.CodeHosting += int(codeHosting)
+
+			for _, publisher := range publishers {
+				if err := tran.Delete(&publisher).Error; err != nil {
+					return err //nolint:wrapcheck
+				}
+
+				if err := ownership.Relink(tran, models.Publisher{ID: publisher.ID}); err != nil {
+					return err //nolint:wrapcheck
+				}
+
+				if err := saveRevision(tran, common.EventTypeDelete, publisher); err != nil {
+					return err
+				}
+			}
+
+			deletion.Publishers += len(publishers)
+
+			return nil
+		})
+
+	return result.Error //nolint:wrapcheck
+}
+
+// recordEntities records the entities of entityType with ids as moved to the
+// trash by deletion.
+func recordEntities(tran *gorm.DB, deletion *models.CatalogDeletion, entityType string, ids []string) error {
+	entities := make([]models.CatalogDeletionEntity, 0, len(ids))
+	for _, id := range ids {
+		entities = append(entities, models.CatalogDeletionEntity{
+			CatalogDeletionID: deletion.ID,
+			EntityType:        entityType,
+			EntityID:          id,
+		})
+	}
+
+	return tran.Create(&entities).Error //nolint:wrapcheck
+}
+
+// restoreCatalogEntities restores from the trash the software and the
+// publishers moved there by the deletions of catalog, unless purged or
+// already restored. Their logs and webhooks were deleted for good.
+func restoreCatalogEntities(tran *gorm.DB, catalog models.Catalog) error {
+	deletionIDs := tran.Model(&models.CatalogDeletion{}).Select("id").Where("catalog_id = ?", catalog.ID)
+	entityIDs := func(entityType string) *gorm.DB {
+		return tran.Model(&models.CatalogDeletionEntity{}).
+			Select("entity_id").
+			Where("catalog_deletion_id IN (?) AND entity_type = ?", deletionIDs, entityType)
+	}
+
+	var publisherIDs, softwareIDs []string
+
+	if err := tran.Model(&models.Publisher{}).
+		Scopes(models.Deleted).
+		Where("id IN (?)", entityIDs(models.Publisher{}.TableName())).
+		Pluck("id", &publisherIDs).Error; err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	// The publishers first, to link them the software they own
+	for _, id := range publisherIDs {
+		if err := tran.Unscoped().Model(&models.Publisher{ID: id}).Update("deleted_at", nil).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		publisher := models.Publisher{ID: id}
+		if err := tran.Preload("CodeHosting").First(&publisher).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := ownership.Relink(tran, publisher); err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := saveRevision(tran, common.EventTypeUpdate, publisher); err != nil {
+			return err
+		}
+	}
+
+	if err := tran.Model(&models.Software{}).
+		Scopes(models.Deleted).
+		Where("id IN (?)", entityIDs(models.Software{}.TableName())).
+		Pluck("id", &softwareIDs).Error; err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	for _, id := range softwareIDs {
+		if err := tran.Unscoped().Model(&models.Software{ID: id}).Update("deleted_at", nil).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		software := models.Software{}
+		if err := loadSoftware(tran, &software, id); err != nil {
+			return err
+		}
+
+		if err := ownership.Link(tran, &software); err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := saveRevision(tran, common.EventTypeUpdate, software); err != nil {
+			return err
+		}
+	}
+
+	return tran.Where("catalog_deletion_id IN (?)", deletionIDs).
+		Delete(&models.CatalogDeletionEntity{}).Error //nolint:wrapcheck
+}
+
+// deleteEntityRows deletes the logs and the webhooks of the entities of
+// entityType with ids, counting them in deletion.
+func deleteEntityRows(tran *gorm.DB, deletion *models.CatalogDeletion, entityType string, ids []string) error {
+	logs, webhooks, err := trash.DeleteEntityRows(tran, entityType, ids)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	deletion.Logs += int(logs)
+	deletion.Webhooks += int(webhooks)
+
+	return nil
+}
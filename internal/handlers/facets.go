@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/handlers/general"
+	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/italia/developers-italia-api/internal/publiccode"
+	"gorm.io/gorm"
+)
+
+// facet is a field software are counted by: a column of software, or the
+// items of a publiccode.yml list stored as SoftwareTerms.
+type facet struct {
+	// SQL expression of the value, for column facets
+	expr string
+
+	// Whether software without a value aren't counted, for column facets
+	skipEmpty bool
+
+	// Path of the SoftwareTerms, for list facets
+	term string
+}
+
+// FacetCount is the number of software with a value of a facet.
+type FacetCount struct {
+	Value *string `json:"value" gorm:"column:facet_value"`
+	Count int64   `json:"count" gorm:"column:facet_count"`
+}
+
+//nolint:gochecknoglobals // read-only
+var softwareFacets = map[string]facet{
+	"category":          {term: publiccode.TermCategories},
+	"platform":          {term: publiccode.TermPlatforms},
+	"country":           {term: publiccode.TermCountries},
+	"scope":             {term: publiccode.TermScope},
+	"license":           {expr: "license", skipEmpty: true},
+	"developmentStatus": {expr: "development_status", skipEmpty: true},
+	"softwareType":      {expr: "software_type", skipEmpty: true},
+	"maintenanceType":   {expr: "maintenance_type", skipEmpty: true},
+	"catalog":           {expr: "catalog_id"},
+	"active":            {expr: "CASE WHEN active THEN 'true' ELSE 'false' END"},
+}
+
+// softwareListFilters applies to stmt the filters of the software lists in
+// the query parameters.
+func softwareListFilters(ctx *fiber.Ctx, db *gorm.DB, stmt *gorm.DB, errMsg string) (*gorm.DB, error) {
+	stmt, err := general.Clauses(ctx, stmt.Scopes(publiccodeFilters(ctx)), "")
+	if err != nil {
+		return nil, common.Error(fiber.StatusUnprocessableEntity, errMsg, err.Error())
+	}
+
+	// Return just software with a certain URL if the 'url' query filter
+	// is used, one for each catalog listing it.
+	if url := common.NormalizeURL(ctx.Query("url", "")); url != "" {
+		stmt = stmt.Where("id IN (?)", db.Model(&models.SoftwareURL{}).Select("software_id").Where("url = ?", url))
+	}
+
+	// The publisher can be referred to by its alternativeId too
+	if publisher := ctx.Query("publisher"); publisher != "" {
+		stmt = stmt.Where(
+			"publisher_id IN (?)",
+			db.Model(&models.Publisher{}).Select("id").Scopes(publisherScope(db, publisher)),
+		)
+	}
+
+	if all := ctx.QueryBool("all", false); !all {
+		stmt = stmt.Scopes(models.Active)
+	}
+
+	// The software in the trash are listed only on their own
+	if ctx.QueryBool("deleted", false) {
+		stmt = stmt.Scopes(models.Deleted)
+	}
+
+	return stmt, nil
+}
+
+// countFacets counts the software matched by stmt by the facets in the
+// `facets` query parameter, all of them if it's not set.
+func countFacets(ctx *fiber.Ctx, db *gorm.DB, stmt *gorm.DB, errMsg string) (map[string][]FacetCount, error) {
+	names := slices.Sorted(maps.Keys(softwareFacets))
+
+	if raw := ctx.Query("facets"); raw != "" {
+		names = strings.Split(raw, ",")
+
+		for _, name := range names {
+			if _, ok := softwareFacets[name]; !ok {
+				return nil, common.Error(
+					fiber.StatusUnprocessableEntity,
+					errMsg,
+					"unknown facet "+name+", must be one of "+strings.Join(slices.Sorted(maps.Keys(softwareFacets)), ", "),
+				)
+			}
+		}
+	}
+
+	counts := make(map[string][]FacetCount, len(names))
+
+	for _, name := range names {
+		var (
+			facet  = softwareFacets[name]
+			values = []FacetCount{}
+			query  *gorm.DB
+		)
+
+		// Every facet is counted on a new statement from stmt
+		software := stmt.Session(&gorm.Session{})
+
+		if facet.term != "" {
+			query = db.Model(&models.SoftwareTerm{}).
+				Select("value AS facet_value, COUNT(DISTINCT software_id) AS facet_count").
+				Where("path = ? AND software_id IN (?)", facet.term, software.Select("id")).
+				Group("value")
+		} else {
+			query = software.
+				Select(facet.expr + " AS facet_value, COUNT(*) AS facet_count").
+				Group(facet.expr)
+
+			if facet.skipEmpty {
+				query = query.Where(facet.expr + " IS NOT NULL AND " + facet.expr + " <> ''")
+			}
+		}
+
+		if err := query.Order("facet_count DESC, facet_value").Scan(&values).Error; err != nil {
+			return nil, common.InternalServerError(errMsg)
+		}
+
+		counts[name] = values
+	}
+
+	return counts, nil
+}
@@ -75,6 +75,11 @@ func NewPaginatorWithConfig(ctx *fiber.Ctx, config *paginator.Config) (*paginato
 		mergedConf.Keys = config.Keys
 	}
 
+	// Rules take precedence over Keys
+	if len(config.Rules) != 0 {
+		mergedConf.Rules = config.Rules
+	}
+
 	if config.Limit != 0 {
 		mergedConf.Limit = config.Limit
 	}
@@ -12,7 +12,7 @@ import (
 	"gorm.io/gorm"
 )
 
-type LogInterface interface {
+type LogInterface interface { //nolint:interfacebloat
 	GetLogs(ctx *fiber.Ctx) error
 	GetLog(ctx *fiber.Ctx) error
 	PostLog(ctx *fiber.Ctx) error
@@ -22,7 +22,15 @@ type LogInterface interface {
 	GetSoftwareLogs(ctx *fiber.Ctx) error
 	PostSoftwareLog(ctx *fiber.Ctx) error
 
+	GetPublisherLogs(ctx *fiber.Ctx) error
+	PostPublisherLog(ctx *fiber.Ctx) error
+
+	GetCatalogLogs(ctx *fiber.Ctx) error
 	PostCatalogLog(ctx *fiber.Ctx) error
+	GetCatalogSoftwareLogs(ctx *fiber.Ctx) error
+	PostCatalogSoftwareLog(ctx *fiber.Ctx) error
+	GetCatalogPublisherLogs(ctx *fiber.Ctx) error
+	PostCatalogPublisherLog(ctx *fiber.Ctx) error
 }
 
 type Log struct {
@@ -158,8 +166,6 @@ func (p *Log) DeleteLog(ctx *fiber.Ctx) error {
 
 // GetSoftwareLogs gets the logs associated to a Software with the given ID and returns any error encountered.
 func (p *Log) GetSoftwareLogs(ctx *fiber.Ctx) error {
-	var logs []models.Log
-
 	software := models.Software{}
 
 	if err := p.db.First(&software, "id = ?", ctx.Params("id")).Error; err != nil {
@@ -174,26 +180,40 @@ func (p *Log) GetSoftwareLogs(ctx *fiber.Ctx) error {
 		)
 	}
 
-	stmt := p.db.
-		Where(map[string]any{"entity_type": models.Software{}.TableName()}).
-		Where("entity_id = ?", software.ID)
+	return listLogs(ctx, p.db, models.Software{}.TableName(), &software.ID, "can't get Software")
+}
 
-	// Logs are returned in descending order, last first
-	paginator, err := general.NewPaginatorWithConfig(ctx, &paginator.Config{Order: paginator.DESC})
+// PostCatalogLog creates a new log associated to a Catalog with the given ID and returns any error encountered.
+func (p *Log) PostCatalogLog(ctx *fiber.Ctx) error {
+	catalog, err := resolveCatalog(p.db, ctx.Params("id"))
 	if err != nil {
-		return common.Error(fiber.StatusUnprocessableEntity, "can't get Logs", err.Error())
-	}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, "can't create Log", "Catalog was not found")
+		}
 
-	result, cursor, err := paginator.Paginate(stmt, &logs)
-	if err != nil {
 		return common.Error(
-			fiber.StatusUnprocessableEntity,
-			"can't get Software",
-			"wrong cursor format in page[after] or page[before]",
+			fiber.StatusInternalServerError,
+			"can't get Catalog",
+			fiber.ErrInternalServerError.Message,
 		)
 	}
 
-	if result.Error != nil {
+	var entityID *string
+	if !isRoot(catalog) {
+		entityID = &catalog.ID
+	}
+
+	return createLog(ctx, p.db, models.Catalog{}.TableName(), entityID)
+}
+
+// PostSoftwareLog creates a new log associated to a Software with the given ID and returns any error encountered.
+func (p *Log) PostSoftwareLog(ctx *fiber.Ctx) error {
+	software := models.Software{}
+	if err := p.db.First(&software, "id = ?", ctx.Params("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, "can't create Log", "Software was not found")
+		}
+
 		return common.Error(
 			fiber.StatusInternalServerError,
 			"can't get Software",
@@ -201,86 +221,163 @@ func (p *Log) GetSoftwareLogs(ctx *fiber.Ctx) error {
 		)
 	}
 
-	return ctx.JSON(fiber.Map{"data": &logs, "links": general.NewPaginationLinks(ctx.Queries(), cursor)})
+	return createLog(ctx, p.db, models.Software{}.TableName(), &software.ID)
 }
 
-// PostCatalogLog creates a new log associated to a Catalog with the given ID and returns any error encountered.
-func (p *Log) PostCatalogLog(ctx *fiber.Ctx) error {
-	const errMsg = "can't create Log"
+// GetPublisherLogs gets the logs associated to a Publisher with the given ID,
+// which can also be its alternativeId.
+func (p *Log) GetPublisherLogs(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Logs"
 
-	logReq := new(common.Log)
+	publisher := models.Publisher{}
 
-	catalog, err := resolveCatalog(p.db, ctx.Params("id"))
-	if err != nil {
+	if err := p.db.Scopes(publisherScope(p.db, ctx.Params("id"))).First(&publisher).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return common.Error(fiber.StatusNotFound, "can't create Log", "Catalog was not found")
+			return common.Error(fiber.StatusNotFound, errMsg, "Publisher was not found")
 		}
 
-		return common.Error(
-			fiber.StatusInternalServerError,
-			"can't get Catalog",
-			fiber.ErrInternalServerError.Message,
-		)
+		return common.InternalServerError(errMsg)
 	}
 
-	if err := common.ValidateRequestEntity(ctx, logReq, errMsg); err != nil {
-		return err //nolint:wrapcheck
+	return listLogs(ctx, p.db, models.Publisher{}.TableName(), &publisher.ID, errMsg)
+}
+
+// PostPublisherLog creates a new log associated to a Publisher with the given
+// ID, which can also be its alternativeId.
+func (p *Log) PostPublisherLog(ctx *fiber.Ctx) error {
+	const errMsg = "can't create Log"
+
+	publisher := models.Publisher{}
+
+	if err := p.db.Scopes(publisherScope(p.db, ctx.Params("id"))).First(&publisher).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Publisher was not found")
+		}
+
+		return common.InternalServerError(errMsg)
 	}
 
-	table := models.Catalog{}.TableName()
+	return createLog(ctx, p.db, models.Publisher{}.TableName(), &publisher.ID)
+}
+
+// GetCatalogLogs gets the logs associated to a Catalog with the given ID, not
+// the ones of its software and publishers.
+func (p *Log) GetCatalogLogs(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Logs"
+
+	catalog, err := resolveCatalog(p.db, ctx.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Catalog was not found")
+		}
+
+		return common.InternalServerError(errMsg)
+	}
 
 	var entityID *string
 	if !isRoot(catalog) {
 		entityID = &catalog.ID
 	}
 
-	log := models.Log{
-		ID:         utils.UUIDv4(),
-		Message:    logReq.Message,
-		EntityID:   entityID,
-		EntityType: &table,
+	return listLogs(ctx, p.db, models.Catalog{}.TableName(), entityID, errMsg)
+}
+
+// GetCatalogSoftwareLogs gets the logs associated to the software with
+// softwareId that belongs to the given catalog.
+func (p *Log) GetCatalogSoftwareLogs(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Logs"
+
+	software, err := catalogSoftware(ctx, p.db, errMsg)
+	if err != nil {
+		return err
 	}
 
-	if err := p.db.Create(&log).Error; err != nil {
-		return common.Error(fiber.StatusInternalServerError, errMsg, "db error")
+	return listLogs(ctx, p.db, models.Software{}.TableName(), &software.ID, errMsg)
+}
+
+// PostCatalogSoftwareLog creates a new log associated to the software with
+// softwareId that belongs to the given catalog.
+func (p *Log) PostCatalogSoftwareLog(ctx *fiber.Ctx) error {
+	software, err := catalogSoftware(ctx, p.db, "can't create Log")
+	if err != nil {
+		return err
 	}
 
-	return ctx.JSON(&log)
+	return createLog(ctx, p.db, models.Software{}.TableName(), &software.ID)
 }
 
-// PostSoftwareLog creates a new log associated to a Software with the given ID and returns any error encountered.
-func (p *Log) PostSoftwareLog(ctx *fiber.Ctx) error {
-	const errMsg = "can't create Log"
+// GetCatalogPublisherLogs gets the logs associated to the publisher with
+// publisherId that belongs to the given catalog.
+func (p *Log) GetCatalogPublisherLogs(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Logs"
 
-	logReq := new(common.Log)
+	publisher, err := catalogPublisher(ctx, p.db, errMsg)
+	if err != nil {
+		return err
+	}
 
-	software := models.Software{}
-	if err := p.db.First(&software, "id = ?", ctx.Params("id")).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return common.Error(fiber.StatusNotFound, "can't create Log", "Software was not found")
-		}
+	return listLogs(ctx, p.db, models.Publisher{}.TableName(), &publisher.ID, errMsg)
+}
 
+// PostCatalogPublisherLog creates a new log associated to the publisher with
+// publisherId that belongs to the given catalog.
+func (p *Log) PostCatalogPublisherLog(ctx *fiber.Ctx) error {
+	publisher, err := catalogPublisher(ctx, p.db, "can't create Log")
+	if err != nil {
+		return err
+	}
+
+	return createLog(ctx, p.db, models.Publisher{}.TableName(), &publisher.ID)
+}
+
+// listLogs responds with the page of the logs associated to the entity of
+// entityType with entityID, or to none if nil, last first.
+func listLogs(ctx *fiber.Ctx, gormdb *gorm.DB, entityType string, entityID *string, errMsg string) error {
+	var logs []models.Log
+
+	stmt := gormdb.Where(map[string]any{"entity_type": entityType, "entity_id": entityID})
+
+	// Logs are returned in descending order, last first
+	paginator, err := general.NewPaginatorWithConfig(ctx, &paginator.Config{Order: paginator.DESC})
+	if err != nil {
+		return common.Error(fiber.StatusUnprocessableEntity, "can't get Logs", err.Error())
+	}
+
+	result, cursor, err := paginator.Paginate(stmt, &logs)
+	if err != nil {
 		return common.Error(
-			fiber.StatusInternalServerError,
-			"can't get Software",
-			fiber.ErrInternalServerError.Message,
+			fiber.StatusUnprocessableEntity,
+			errMsg,
+			"wrong cursor format in page[after] or page[before]",
 		)
 	}
 
+	if result.Error != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(fiber.Map{"data": &logs, "links": general.NewPaginationLinks(ctx.Queries(), cursor)})
+}
+
+// createLog creates the log in the request body associated to the entity of
+// entityType with entityID, or to none if nil.
+func createLog(ctx *fiber.Ctx, gormdb *gorm.DB, entityType string, entityID *string) error {
+	const errMsg = "can't create Log"
+
+	logReq := new(common.Log)
+
 	if err := common.ValidateRequestEntity(ctx, logReq, errMsg); err != nil {
 		return err //nolint:wrapcheck
 	}
 
-	table := models.Software{}.TableName()
-
 	log := models.Log{
 		ID:         utils.UUIDv4(),
 		Message:    logReq.Message,
-		EntityID:   &software.ID,
-		EntityType: &table,
+		EntityID:   entityID,
+		EntityType: &entityType,
 	}
 
-	if err := p.db.Create(&log).Error; err != nil {
+	if err := gormdb.Create(&log).Error; err != nil {
 		return common.Error(fiber.StatusInternalServerError, errMsg, "db error")
 	}
 
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/italia/developers-italia-api/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	errMergeSelf         = errors.New("can't merge into itself")
+	errDuplicateNotFound = errors.New("duplicate was not found")
+)
+
+// moveWebhooks moves the webhooks of the entity with fromID to the one with
+// toID. The ones with the same URL as a webhook of the latter are deleted.
+func moveWebhooks(tran *gorm.DB, entityType string, fromID string, toID string) error {
+	var duplicates []string
+
+	if err := tran.Model(&models.Webhook{}).
+		Where("entity_type = ? AND entity_id = ?", entityType, fromID).
+		Where("url IN (?)", tran.Model(&models.Webhook{}).
+			Select("url").
+			Where("entity_type = ? AND entity_id = ?", entityType, toID)).
+		Pluck("id", &duplicates).Error; err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if len(duplicates) > 0 {
+		if err := tran.Where("webhook_id IN ?", duplicates).Delete(&models.WebhookHeader{}).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := tran.Where("id IN ?", duplicates).Delete(&models.Webhook{}).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	err := tran.Model(&models.Webhook{}).
+		Where("entity_type = ? AND entity_id = ?", entityType, fromID).
+		Update("entity_id", toID).Error
+
+	return err //nolint:wrapcheck
+}
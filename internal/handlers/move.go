@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/italia/developers-italia-api/internal/ownership"
+	"gorm.io/gorm"
+)
+
+var (
+	errMoveSameCatalog    = errors.New("can't move to the same Catalog")
+	errMoveTargetNotFound = errors.New("target Catalog was not found")
+)
+
+// notInCatalogError is returned when the entity to move with the given ID is
+// not in the Catalog it's moved from.
+type notInCatalogError string
+
+func (e notInCatalogError) Error() string {
+	return fmt.Sprintf("'%s' is not in the Catalog", string(e))
+}
+
+// MoveCatalogSoftware moves the software with softwareId from the given
+// catalog to the one in the request, keeping its ID, logs and webhooks.
+func (c *Catalog) MoveCatalogSoftware(ctx *fiber.Ctx) error {
+	const errMsg = "can't move Software"
+
+	request := new(common.CatalogMove)
+
+	if err := common.ValidateRequestEntity(ctx, request, errMsg); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	from, to, err := resolveMove(ctx, c.db, request.CatalogID, errMsg)
+	if err != nil {
+		return err
+	}
+
+	var software []models.Software
+
+	if err := c.db.Transaction(func(tran *gorm.DB) error {
+		software, err = moveSoftware(tran, from, to, []string{ctx.Params("softwareId")})
+
+		return err
+	}); err != nil {
+		var notInCatalog notInCatalogError
+
+		if errors.As(err, &notInCatalog) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Software was not found")
+		}
+
+		return softwareSaveError(err, errMsg)
+	}
+
+	return ctx.JSON(&software[0])
+}
+
+// MoveCatalogSoftwareBulk moves the software with the IDs in the request
+// from the given catalog to the one in the request, all of them or none.
+func (c *Catalog) MoveCatalogSoftwareBulk(ctx *fiber.Ctx) error {
+	const errMsg = "can't move Software"
+
+	request := new(common.CatalogBulkMove)
+
+	if err := common.ValidateRequestEntity(ctx, request, errMsg); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	from, to, err := resolveMove(ctx, c.db, request.CatalogID, errMsg)
+	if err != nil {
+		return err
+	}
+
+	var software []models.Software
+
+	if err := c.db.Transaction(func(tran *gorm.DB) error {
+		software, err = moveSoftware(tran, from, to, request.IDs)
+
+		return err
+	}); err != nil {
+		var notInCatalog notInCatalogError
+
+		if errors.As(err, &notInCatalog) {
+			return common.Error(fiber.StatusUnprocessableEntity, errMsg, "Software "+notInCatalog.Error())
+		}
+
+		return softwareSaveError(err, errMsg)
+	}
+
+	return ctx.JSON(fiber.Map{"data": &software})
+}
+
+// MoveCatalogPublisher moves the publisher with publisherId from the given
+// catalog to the one in the request, keeping its ID, logs and webhooks.
+func (c *Catalog) MoveCatalogPublisher(ctx *fiber.Ctx) error {
+	const errMsg = "can't move Publisher"
+
+	request := new(common.CatalogMove)
+
+	if err := common.ValidateRequestEntity(ctx, request, errMsg); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	from, to, err := resolveMove(ctx, c.db, request.CatalogID, errMsg)
+	if err != nil {
+		return err
+	}
+
+	var publishers []models.Publisher
+
+	if err := c.db.Transaction(func(tran *gorm.DB) error {
+		publishers, err = movePublishers(tran, from, to, []string{ctx.Params("publisherId")})
+
+		return err
+	}); err != nil {
+		var notInCatalog notInCatalogError
+
+		if errors.As(err, &notInCatalog) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Publisher was not found")
+		}
+
+		return publisherMoveError(err, errMsg)
+	}
+
+	return ctx.JSON(&publishers[0])
+}
+
+// MoveCatalogPublishersBulk moves the publishers with the IDs in the request
+// from the given catalog to the one in the request, all of them or none.
+func (c *Catalog) MoveCatalogPublishersBulk(ctx *fiber.Ctx) error {
+	const errMsg = "can't move Publishers"
+
+	request := new(common.CatalogBulkMove)
+
+	if err := common.ValidateRequestEntity(ctx, request, errMsg); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	from, to, err := resolveMove(ctx, c.db, request.CatalogID, errMsg)
+	if err != nil {
+		return err
+	}
+
+	var publishers []models.Publisher
+
+	if err := c.db.Transaction(func(tran *gorm.DB) error {
+		publishers, err = movePublishers(tran, from, to, request.IDs)
+
+		return err
+	}); err != nil {
+		var notInCatalog notInCatalogError
+
+		if errors.As(err, &notInCatalog) {
+			return common.Error(fiber.StatusUnprocessableEntity, errMsg, "Publisher "+notInCatalog.Error())
+		}
+
+		return publisherMoveError(err, errMsg)
+	}
+
+	return ctx.JSON(fiber.Map{"data": &publishers})
+}
+
+// resolveMove resolves the catalog in the path and the one with targetID
+// to move to. Both must exist and not be in the trash. It returns the error
+// response if any.
+func resolveMove(
+	ctx *fiber.Ctx, gormdb *gorm.DB, targetID string, errMsg string,
+) (*models.Catalog, *models.Catalog, error) {
+	from, err := resolveCatalog(gormdb, ctx.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, common.Error(fiber.StatusNotFound, errMsg, "Catalog was not found")
+		}
+
+		return nil, nil, common.InternalServerError(errMsg)
+	}
+
+	to, err := resolveCatalog(gormdb, targetID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, common.Error(fiber.StatusUnprocessableEntity, errMsg, errMoveTargetNotFound.Error())
+		}
+
+		return nil, nil, common.InternalServerError(errMsg)
+	}
+
+	if isRoot(from) == isRoot(to) && (isRoot(from) || from.ID == to.ID) {
+		return nil, nil, common.Error(fiber.StatusUnprocessableEntity, errMsg, errMoveSameCatalog.Error())
+	}
+
+	return from, to, nil
+}
+
+// moveSoftware moves the software with ids, and their URLs, from the catalog
+// from to the one to, in the order of ids.
+func moveSoftware(tran *gorm.DB, from *models.Catalog, to *models.Catalog, ids []string) ([]models.Software, error) {
+	var catalogID *string
+	if !isRoot(to) {
+		catalogID = &to.ID
+	}
+
+	moved := make([]models.Software, 0, len(ids))
+
+	for _, id := range ids {
+		software := models.Software{}
+
+		if err := tran.Scopes(catalogScope(from)).First(&software, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, notInCatalogError(id)
+			}
+
+			return nil, err //nolint:wrapcheck
+		}
+
+		// The URLs of the software must not be in the target catalog already
+		if err := tran.Model(&models.SoftwareURL{}).
+			Where("software_id = ?", software.ID).
+			Update("catalog_id", urlCatalogID(catalogID)).Error; err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		if err := tran.Model(&software).Update("catalog_id", catalogID).Error; err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		if err := loadSoftware(tran, &software, software.ID); err != nil {
+			return nil, err
+		}
+
+		// Only the publishers of the target catalog can own it
+		if err := ownership.Link(tran, &software); err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		sort.Slice(software.Aliases, func(a int, b int) bool {
+			return software.Aliases[a].URL < software.Aliases[b].URL
+		})
+
+		if err := saveRevision(tran, common.EventTypeUpdate, software); err != nil {
+			return nil, err
+		}
+
+		moved = append(moved, software)
+	}
+
+	return moved, nil
+}
+
+// movePublishers moves the publishers with ids, and their code hosting, from
+// the catalog from to the one to, in the order of ids. Their software stays
+// where it is, and is linked again to the publishers of its catalog.
+func movePublishers(tran *gorm.DB, from *models.Catalog, to *models.Catalog, ids []string) ([]models.Publisher, error) {
+	var catalogID *string
+	if !isRoot(to) {
+		catalogID = &to.ID
+	}
+
+	moved := make([]models.Publisher, 0, len(ids))
+
+	for _, id := range ids {
+		publisher := models.Publisher{}
+
+		if err := tran.Preload("CodeHosting").
+			Scopes(catalogScope(from), publisherScope(tran, id)).
+			First(&publisher).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, notInCatalogError(id)
+			}
+
+			return nil, err //nolint:wrapcheck
+		}
+
+		// The alternativeId qualified by the namespace of the target catalog
+		// must not be taken
+		if publisher.AlternativeID != nil {
+			if err := checkAlternativeIDConflict(tran, catalogID, *publisher.AlternativeID); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := tran.Model(&publisher).Update("catalog_id", catalogID).Error; err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		// The code hosting URLs must not be in the target catalog already
+		if err := tran.Model(&models.CodeHosting{}).
+			Where("publisher_id = ?", publisher.ID).
+			Update("catalog_id", urlCatalogID(catalogID)).Error; err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		publisher.CatalogID = catalogID
+
+		if err := ownership.Relink(tran, publisher); err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		if err := saveRevision(tran, common.EventTypeUpdate, publisher); err != nil {
+			return nil, err
+		}
+
+		moved = append(moved, publisher)
+	}
+
+	return moved, nil
+}
+
+// publisherMoveError returns the error response for err, from moving
+// publishers: 409 if the target catalog has one with the same description
+// or alternativeId.
+func publisherMoveError(err error, errMsg string) error {
+	var idConflict idConflictError
+
+	if errors.As(err, &idConflict) {
+		return common.Error(fiber.StatusConflict, errMsg, idConflict.Error())
+	}
+
+	if field := common.DuplicateField(err); field != nil {
+		detail := alreadyExists
+		if *field != "" {
+			detail = *field + " " + alreadyExists
+		}
+
+		return common.Error(fiber.StatusConflict, errMsg, detail)
+	}
+
+	return common.InternalServerError(errMsg)
+}
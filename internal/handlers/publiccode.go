@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/italia/developers-italia-api/internal/publiccode"
+	"gorm.io/gorm"
+)
+
+// parsePubliccode parses a publiccode.yml and validates it according to the
+// `validation` query parameter:
+//
+//   - "strict" (default): an invalid file is rejected with 422, and its
+//     warnings are returned as Warning headers
+//   - "warn": the file is accepted anyway, all its problems are returned as
+//     Warning headers. For crawlers that have to store legacy files
+//   - "off": the file isn't validated at all
+//
+// It returns the parsed file, nil if it's not even a YAML mapping.
+func parsePubliccode(ctx *fiber.Ctx, publiccodeYml string, errMsg string) (*publiccode.PublicCode, error) {
+	mode := ctx.Query("validation", "strict")
+
+	if mode != "strict" && mode != "warn" && mode != "off" {
+		return nil, common.Error(
+			fiber.StatusUnprocessableEntity, errMsg, "validation must be one of strict, warn or off",
+		)
+	}
+
+	publicCode, errs, warnings := publiccode.Parse([]byte(publiccodeYml))
+
+	if mode == "off" {
+		return publicCode, nil
+	}
+
+	prefixPubliccodeFields(errs)
+	prefixPubliccodeFields(warnings)
+
+	if mode == "warn" {
+		warnings = append(errs, warnings...)
+	} else if len(errs) > 0 {
+		return nil, common.ErrorWithValidationErrors(fiber.StatusUnprocessableEntity, errMsg, errs)
+	}
+
+	for _, warning := range warnings {
+		ctx.Response().Header.Add(
+			fiber.HeaderWarning,
+			`299 - "`+strings.ReplaceAll(common.GenerateErrorDetails([]common.ValidationError{warning}), `"`, `'`)+`"`,
+		)
+	}
+
+	return publicCode, nil
+}
+
+// prefixPubliccodeFields makes the fields of the publiccode.yml validation
+// errors relative to the request body.
+func prefixPubliccodeFields(validationErrors []common.ValidationError) {
+	for i := range validationErrors {
+		validationErrors[i].Field = strings.TrimSuffix("publiccodeYml."+validationErrors[i].Field, ".")
+	}
+}
+
+// publiccodeFilters is a scope filtering software by the fields extracted
+// from their publiccode.yml, with the query parameters:
+//
+//   - name, license, developmentStatus, softwareType, maintenanceType
+//   - category, platform, country and scope, matching an item of the
+//     corresponding list
+func publiccodeFilters(ctx *fiber.Ctx) func(*gorm.DB) *gorm.DB {
+	return func(stmt *gorm.DB) *gorm.DB {
+		for param, column := range map[string]string{
+			"name":              "name",
+			"license":           "license",
+			"developmentStatus": "development_status",
+			"softwareType":      "software_type",
+			"maintenanceType":   "maintenance_type",
+		} {
+			if value := ctx.Query(param); value != "" {
+				stmt = stmt.Where(column+" = ?", value)
+			}
+		}
+
+		for param, path := range map[string]string{
+			"category": publiccode.TermCategories,
+			"platform": publiccode.TermPlatforms,
+			"country":  publiccode.TermCountries,
+			"scope":    publiccode.TermScope,
+		} {
+			if value := ctx.Query(param); value != "" {
+				stmt = stmt.Where(
+					"id IN (?)",
+					stmt.Session(&gorm.Session{NewDB: true}).
+						Model(&models.SoftwareTerm{}).
+						Select("software_id").
+						Where("path = ? AND value = ?", path, value),
+				)
+			}
+		}
+
+		return stmt
+	}
+}
@@ -5,12 +5,17 @@ import (
 	"fmt"
 	"slices"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/utils"
 	"github.com/italia/developers-italia-api/internal/common"
 	"github.com/italia/developers-italia-api/internal/handlers/general"
 	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/italia/developers-italia-api/internal/ownership"
+	"github.com/italia/developers-italia-api/internal/search"
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
 	"gorm.io/gorm"
 )
 
@@ -20,6 +25,9 @@ type PublisherInterface interface {
 	PostPublisher(ctx *fiber.Ctx) error
 	PatchPublisher(ctx *fiber.Ctx) error
 	DeletePublisher(ctx *fiber.Ctx) error
+	RestorePublisher(ctx *fiber.Ctx) error
+	MergePublisher(ctx *fiber.Ctx) error
+	GetPublisherSoftware(ctx *fiber.Ctx) error
 }
 
 const alreadyExists = "already exists"
@@ -51,7 +59,25 @@ func (p *Publisher) GetPublishers(ctx *fiber.Ctx) error {
 		stmt = stmt.Scopes(models.Active)
 	}
 
-	paginator, err := general.NewPaginator(ctx)
+	if ctx.QueryBool("deleted", false) {
+		stmt = stmt.Scopes(models.Deleted)
+	}
+
+	stmt, err = catalogListFilters(ctx, p.db, stmt, "can't get Publishers")
+	if err != nil {
+		return err
+	}
+
+	pageConfig := &paginator.Config{}
+
+	// Full-text search, the results are ordered by relevance
+	query := ctx.Query("q")
+	if query != "" {
+		stmt = stmt.Table("(?) AS publishers", search.Publishers(p.db, query))
+		pageConfig.Rules = search.PaginationRules("publishers")
+	}
+
+	paginator, err := general.NewPaginatorWithConfig(ctx, pageConfig)
 	if err != nil {
 		return common.Error(fiber.StatusUnprocessableEntity, "can't get Publishers", err.Error())
 	}
@@ -73,6 +99,10 @@ func (p *Publisher) GetPublishers(ctx *fiber.Ctx) error {
 		)
 	}
 
+	for i := range publishers {
+		publishers[i].Snippet = search.Snippet(publishers[i].Snippet, query)
+	}
+
 	return ctx.JSON(fiber.Map{"data": &publishers, "links": general.NewPaginationLinks(ctx.Queries(), cursor)})
 }
 
@@ -81,8 +111,27 @@ func (p *Publisher) GetPublisher(ctx *fiber.Ctx) error {
 	publisher := models.Publisher{}
 	id := ctx.Params("id")
 
-	if err := p.db.Preload("CodeHosting").First(&publisher, "id = ? or alternative_id = ?", id, id).Error; err != nil {
+	if ctx.Query("asOf") != "" {
+		// Deleted publishers are found in their revisions by id only
+		if err := p.db.Select("id").Scopes(publisherScope(p.db, id)).First(&publisher).Error; err != nil {
+			publisher.ID = id
+		}
+
+		return getAsOf(ctx, p.db, publisher, "can't get Publisher", "Publisher was not found")
+	}
+
+	if err := p.db.Preload("CodeHosting").Scopes(publisherScope(p.db, id)).First(&publisher).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Publishers merged into another one redirect to it
+			redirected, err := redirectMoved(ctx, p.db, publisher.TableName(), id, "/v1/publishers/")
+			if err != nil {
+				return common.InternalServerError("can't get Publisher")
+			}
+
+			if redirected {
+				return nil
+			}
+
 			return common.Error(fiber.StatusNotFound, "can't get Publisher", "Publisher was not found")
 		}
 
@@ -127,12 +176,20 @@ func (p *Publisher) PostPublisher(ctx *fiber.Ctx) error {
 
 	if err := p.db.Transaction(func(tran *gorm.DB) error {
 		if request.AlternativeID != nil {
-			if err := checkAlternativeIDConflict(tran, *request.AlternativeID); err != nil {
+			if err := checkAlternativeIDConflict(tran, nil, *request.AlternativeID); err != nil {
 				return err
 			}
 		}
 
-		return tran.Create(&publisher).Error
+		if err := tran.Create(&publisher).Error; err != nil {
+			return err
+		}
+
+		if err := ownership.Relink(tran, *publisher); err != nil {
+			return err
+		}
+
+		return saveRevision(tran, common.EventTypeCreate, publisher)
 	}); err != nil {
 		var idConflict idConflictError
 
@@ -164,8 +221,7 @@ func (p *Publisher) PatchPublisher(ctx *fiber.Ctx) error { //nolint:cyclop,funle
 	id := ctx.Params("id")
 
 	// Preload will load all the associated CodeHosting. We'll manually handle that later.
-	if err := p.db.Preload("CodeHosting").First(&publisher, "id = ? or alternative_id = ?", id, id).
-		Error; err != nil {
+	if err := p.db.Preload("CodeHosting").Scopes(publisherScope(p.db, id)).First(&publisher).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return common.Error(fiber.StatusNotFound, errMsg, "Publisher was not found")
 		}
@@ -199,7 +255,7 @@ func (p *Publisher) PatchPublisher(ctx *fiber.Ctx) error { //nolint:cyclop,funle
 	if err := p.db.Transaction(func(tran *gorm.DB) error { //nolint:dupl
 		if updatedPublisher.AlternativeID != nil &&
 			(publisher.AlternativeID == nil || *updatedPublisher.AlternativeID != *publisher.AlternativeID) {
-			if err := checkAlternativeIDConflict(tran, *updatedPublisher.AlternativeID); err != nil {
+			if err := checkAlternativeIDConflict(tran, publisher.CatalogID, *updatedPublisher.AlternativeID); err != nil {
 				return err
 			}
 		}
@@ -224,7 +280,16 @@ func (p *Publisher) PatchPublisher(ctx *fiber.Ctx) error { //nolint:cyclop,funle
 
 		publisher.CodeHosting = codeHosting
 
-		return nil
+		// Sort codeHosting to always have a consistent output.
+		sort.Slice(publisher.CodeHosting, func(a int, b int) bool {
+			return publisher.CodeHosting[a].URL < publisher.CodeHosting[b].URL
+		})
+
+		if err := ownership.Relink(tran, publisher); err != nil {
+			return err
+		}
+
+		return saveRevision(tran, common.EventTypeUpdate, publisher)
 	}); err != nil {
 		var idConflict idConflictError
 
@@ -241,23 +306,18 @@ func (p *Publisher) PatchPublisher(ctx *fiber.Ctx) error { //nolint:cyclop,funle
 			return common.Error(fiber.StatusConflict, errMsg, detail)
 		}
 
-		return common.Error(fiber.StatusInternalServerError, errMsg, err.Error())
+		return common.InternalServerError(errMsg)
 	}
 
-	// Sort codeHosting to always have a consistent output.
-	sort.Slice(publisher.CodeHosting, func(a int, b int) bool {
-		return publisher.CodeHosting[a].URL < publisher.CodeHosting[b].URL
-	})
-
 	return ctx.JSON(&publisher)
 }
 
-// DeletePublisher deletes the publisher with the given ID.
+// DeletePublisher moves the publisher with the given ID to the trash.
 func (p *Publisher) DeletePublisher(ctx *fiber.Ctx) error {
 	id := ctx.Params("id")
 
 	publisher := models.Publisher{}
-	if err := p.db.First(&publisher, "id = ? or alternative_id = ?", id, id).Error; err != nil {
+	if err := p.db.Scopes(publisherScope(p.db, id)).First(&publisher).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return common.Error(fiber.StatusNotFound, "can't delete Publisher", "Publisher was not found")
 		}
@@ -265,15 +325,252 @@ func (p *Publisher) DeletePublisher(ctx *fiber.Ctx) error {
 		return common.Error(fiber.StatusInternalServerError, "can't delete Publisher", "db error")
 	}
 
-	result := p.db.Select("CodeHosting").Delete(&publisher)
-
-	if result.Error != nil {
+	if err := deletePublisher(p.db, publisher); err != nil {
 		return common.Error(fiber.StatusInternalServerError, "can't delete Publisher", "db error")
 	}
 
 	return ctx.SendStatus(fiber.StatusNoContent)
 }
 
+// deletePublisher moves publisher to the trash.
+func deletePublisher(gormdb *gorm.DB, publisher models.Publisher) error {
+	return gormdb.Transaction(func(tran *gorm.DB) error { //nolint:wrapcheck
+		if err := tran.Delete(&publisher).Error; err != nil {
+			return err
+		}
+
+		// The software it owned might be owned by another publisher now,
+		// the code hosting of publishers in the trash doesn't count
+		if err := ownership.Relink(tran, models.Publisher{ID: publisher.ID}); err != nil {
+			return err
+		}
+
+		return saveRevision(tran, common.EventTypeDelete, publisher)
+	})
+}
+
+// RestorePublisher restores the publisher with the given ID from the trash,
+// together with the ownership of the software its code hosting owns.
+func (p *Publisher) RestorePublisher(ctx *fiber.Ctx) error {
+	const errMsg = "can't restore Publisher"
+
+	publisher := models.Publisher{}
+	id := ctx.Params("id")
+
+	if err := p.db.Transaction(func(tran *gorm.DB) error {
+		if err := tran.Scopes(models.Deleted, publisherScope(tran, id)).First(&publisher).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := checkCatalogRestored(tran, publisher.CatalogID); err != nil {
+			return err
+		}
+
+		if err := tran.Unscoped().
+			Model(&models.Publisher{ID: publisher.ID}).
+			Update("deleted_at", nil).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		publisher = models.Publisher{ID: publisher.ID}
+		if err := tran.Preload("CodeHosting").First(&publisher).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := ownership.Relink(tran, publisher); err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return saveRevision(tran, common.EventTypeUpdate, publisher)
+	}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Publisher was not found in the trash")
+		}
+
+		if errors.Is(err, errCatalogTrashed) {
+			return common.Error(fiber.StatusConflict, errMsg, err.Error())
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(&publisher)
+}
+
+// MergePublisher merges the publisher with the id or alternativeId in the
+// body, a duplicate, into the publisher with the given ID. The code hosting,
+// logs and webhooks of the duplicate are moved to it, with the software they
+// own, then the duplicate is deleted and its IDs redirect to it.
+func (p *Publisher) MergePublisher(ctx *fiber.Ctx) error { //nolint:cyclop,funlen
+	const errMsg = "can't merge Publisher"
+
+	request := new(common.MergePost)
+
+	if err := common.ValidateRequestEntity(ctx, request, errMsg); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	publisher := models.Publisher{}
+	id := ctx.Params("id")
+
+	if err := p.db.Transaction(func(tran *gorm.DB) error {
+		if err := tran.Scopes(publisherScope(tran, id)).First(&publisher).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		duplicate := models.Publisher{}
+		if err := tran.Scopes(publisherScope(tran, request.ID)).First(&duplicate).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errDuplicateNotFound
+			}
+
+			return err //nolint:wrapcheck
+		}
+
+		if duplicate.ID == publisher.ID {
+			return errMergeSelf
+		}
+
+		// The code hosting URLs must not be in the catalog of the publisher
+		// already
+		if err := tran.Model(&models.CodeHosting{}).
+			Where("publisher_id = ?", duplicate.ID).
+			Updates(map[string]any{
+				"publisher_id": publisher.ID,
+				"catalog_id":   urlCatalogID(publisher.CatalogID),
+			}).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := tran.Unscoped().Model(&models.Log{}).
+			Where("entity_type = ? AND entity_id = ?", duplicate.TableName(), duplicate.ID).
+			UpdateColumn("entity_id", publisher.ID).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := moveWebhooks(tran, duplicate.TableName(), duplicate.ID, publisher.ID); err != nil {
+			return err
+		}
+
+		if err := tran.Unscoped().Delete(&duplicate).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := saveRevision(tran, common.EventTypeDelete, duplicate); err != nil {
+			return err
+		}
+
+		redirects := []string{duplicate.ID}
+		if duplicate.AlternativeID != nil {
+			redirects = append(redirects, *duplicate.AlternativeID)
+		}
+
+		if err := saveRedirects(tran, duplicate.TableName(), redirects, publisher.ID); err != nil {
+			return err
+		}
+
+		if err := tran.Model(&publisher).Update("updated_at", time.Now()).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		publisher = models.Publisher{ID: publisher.ID}
+		if err := tran.Preload("CodeHosting").First(&publisher).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		// The software of the duplicate is owned by the publisher now, if
+		// in its catalog
+		if err := ownership.Relink(tran, models.Publisher{ID: duplicate.ID}); err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := ownership.Relink(tran, publisher); err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return saveRevision(tran, common.EventTypeUpdate, publisher)
+	}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Publisher was not found")
+		}
+
+		if errors.Is(err, errDuplicateNotFound) || errors.Is(err, errMergeSelf) {
+			return common.Error(fiber.StatusUnprocessableEntity, errMsg, err.Error())
+		}
+
+		if field := common.DuplicateField(err); field != nil {
+			detail := alreadyExists
+			if *field != "" {
+				detail = *field + " " + alreadyExists
+			}
+
+			return common.Error(fiber.StatusConflict, errMsg, detail)
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(&publisher)
+}
+
+// GetPublisherSoftware lists the software owned by the publisher with the
+// given ID, with the filters of GetAllSoftware.
+func (p *Publisher) GetPublisherSoftware(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Software"
+
+	publisher := models.Publisher{}
+	id := ctx.Params("id")
+
+	if err := p.db.Scopes(publisherScope(p.db, id)).First(&publisher).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Publisher was not found")
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	var software []models.Software
+
+	stmt := p.db.Preload("Aliases").Where("publisher_id = ?", publisher.ID)
+
+	stmt, err := softwareListFilters(ctx, p.db, stmt, errMsg)
+	if err != nil {
+		return err
+	}
+
+	stmt, err = catalogListFilters(ctx, p.db, stmt, errMsg)
+	if err != nil {
+		return err
+	}
+
+	rules, err := softwareSortRules(ctx)
+	if err != nil {
+		return common.Error(fiber.StatusUnprocessableEntity, errMsg, err.Error())
+	}
+
+	paginator, err := general.NewPaginatorWithConfig(ctx, &paginator.Config{Rules: rules})
+	if err != nil {
+		return common.Error(fiber.StatusUnprocessableEntity, errMsg, err.Error())
+	}
+
+	result, cursor, err := paginator.Paginate(stmt, &software)
+	if err != nil {
+		return common.Error(
+			fiber.StatusUnprocessableEntity,
+			errMsg,
+			"wrong cursor format in page[after] or page[before]",
+		)
+	}
+
+	if result.Error != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	splitCanonicalURLs(software)
+
+	return ctx.JSON(fiber.Map{"data": &software, "links": general.NewPaginationLinks(ctx.Queries(), cursor)})
+}
+
 // idConflictError is returned when alternativeId conflicts with an existing publisher's primary key.
 type idConflictError string
 
@@ -281,10 +578,24 @@ func (e idConflictError) Error() string {
 	return fmt.Sprintf("Publisher with id '%s' already exists", string(e))
 }
 
-// checkAlternativeIDConflict returns idConflictError if any publisher exists whose primary key
-// equals the given alternativeID value, which would cause ambiguous lookups.
-func checkAlternativeIDConflict(db *gorm.DB, alternativeID string) error {
-	result := db.Limit(1).Find(&models.Publisher{ID: alternativeID})
+// checkAlternativeIDConflict returns idConflictError if alternativeID, of a publisher of
+// the catalog with catalogID (nil for the root one), would cause ambiguous lookups: if
+// it's the primary key of any publisher or the alternativeId of one qualified by its
+// catalog's namespace, or if qualified by the namespace of the catalog it's the
+// alternativeId of any publisher.
+func checkAlternativeIDConflict(db *gorm.DB, catalogID *string, alternativeID string) error {
+	cond := db.Where("id = ?", alternativeID)
+
+	qualified, err := qualifiedAlternativeID(db, alternativeID)
+	if err != nil {
+		return err
+	}
+
+	if qualified != nil {
+		cond = cond.Or(qualified)
+	}
+
+	result := db.Where(cond).Limit(1).Find(&models.Publisher{})
 	if result.Error != nil {
 		return result.Error
 	}
@@ -293,9 +604,86 @@ func checkAlternativeIDConflict(db *gorm.DB, alternativeID string) error {
 		return idConflictError(alternativeID)
 	}
 
+	if catalogID == nil {
+		return nil
+	}
+
+	catalog := models.Catalog{}
+	if err := db.Select("publishers_namespace").First(&catalog, "id = ?", *catalogID).Error; err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if catalog.PublishersNamespace == nil || *catalog.PublishersNamespace == "" {
+		return nil
+	}
+
+	id := *catalog.PublishersNamespace + alternativeID
+
+	result = db.Limit(1).Find(&models.Publisher{}, "alternative_id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected != 0 {
+		return idConflictError(id)
+	}
+
 	return nil
 }
 
+// publisherScope finds the publisher with id as primary key or alternativeId,
+// the one of the root catalog first as alternativeIds are unique only in their
+// catalog. id can also be an alternativeId qualified by the publishersNamespace
+// of its catalog as prefix, fe. `urn:x-italian-pa:c_h501`.
+func publisherScope(db *gorm.DB, id string) func(*gorm.DB) *gorm.DB {
+	return func(stmt *gorm.DB) *gorm.DB {
+		cond := db.Where("id = ? OR alternative_id = ?", id, id)
+
+		qualified, err := qualifiedAlternativeID(db, id)
+		if err != nil {
+			_ = stmt.AddError(err)
+
+			return stmt
+		}
+
+		if qualified != nil {
+			cond = cond.Or(qualified)
+		}
+
+		return stmt.Where(cond).Order("catalog_id IS NOT NULL")
+	}
+}
+
+// qualifiedAlternativeID returns the condition matching the publishers having
+// id as alternativeId qualified by the publishersNamespace of their catalog,
+// the longest one id starts with, nil if id doesn't start with any.
+func qualifiedAlternativeID(db *gorm.DB, id string) (*gorm.DB, error) {
+	catalog := models.Catalog{}
+
+	// Compared as a substring, LIKE would take the _ and % in namespaces as
+	// wildcards
+	result := db.Select("id", "publishers_namespace").
+		Where("publishers_namespace <> ''").
+		Where("SUBSTR(CAST(? AS TEXT), 1, LENGTH(publishers_namespace)) = publishers_namespace", id).
+		Order("LENGTH(publishers_namespace) DESC").
+		Limit(1).
+		Find(&catalog)
+	if result.Error != nil {
+		return nil, result.Error //nolint:wrapcheck
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	code := strings.TrimPrefix(id, *catalog.PublishersNamespace)
+	if code == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	return db.Where("catalog_id = ? AND alternative_id = ?", catalog.ID, code), nil
+}
+
 // syncCodeHosting synchs the CodeHosting for a `publisher` in the database to reflect the
 // passed slice of `codeHosting` URLs.
 //
@@ -325,7 +713,9 @@ func syncCodeHosting( //nolint:cyclop // mostly error handling ifs
 	for _, url := range codeHosting {
 		_, exists := urlMap[url]
 		if !exists {
-			ch := models.CodeHosting{ID: utils.UUIDv4(), URL: url, PublisherID: publisher.ID}
+			ch := models.CodeHosting{
+				ID: utils.UUIDv4(), URL: url, PublisherID: publisher.ID, CatalogID: urlCatalogID(publisher.CatalogID),
+			}
 
 			toAdd = append(toAdd, ch)
 			urlMap[url] = ch
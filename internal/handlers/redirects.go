@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/italia/developers-italia-api/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// saveRedirects redirects fromIDs, and whatever redirected to them, IDs or
+// URLs, to toID.
+func saveRedirects(tran *gorm.DB, entityType string, fromIDs []string, toID string) error {
+	if err := tran.Model(&models.Redirect{}).
+		Where("to_id IN ?", fromIDs).
+		Update("to_id", toID).Error; err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	redirects := make([]models.Redirect, 0, len(fromIDs))
+	for _, fromID := range fromIDs {
+		redirects = append(redirects, models.Redirect{EntityType: entityType, FromID: fromID, ToID: toID})
+	}
+
+	err := tran.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "entity_type"}, {Name: "from_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"to_id"}),
+	}).Create(&redirects).Error
+
+	return err //nolint:wrapcheck
+}
+
+// deleteURLRedirects deletes the redirects of urls, because some software
+// has them again.
+func deleteURLRedirects(tran *gorm.DB, urls []string) error {
+	err := tran.
+		Where("entity_type = ? AND from_id IN ?", models.SoftwareURL{}.TableName(), urls).
+		Delete(&models.Redirect{}).Error
+
+	return err //nolint:wrapcheck
+}
+
+// redirectMoved responds with a redirect to path followed by the ID the
+// entity with id, or the URL, moved to, if it did. It returns false if it
+// didn't.
+func redirectMoved(ctx *fiber.Ctx, gormdb *gorm.DB, entityType string, id string, path string) (bool, error) {
+	redirect := models.Redirect{}
+
+	if err := gormdb.First(&redirect, "entity_type = ? AND from_id = ?", entityType, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+
+		return false, err //nolint:wrapcheck
+	}
+
+	return true, ctx.Redirect(path+redirect.ToID, fiber.StatusMovedPermanently) //nolint:wrapcheck
+}
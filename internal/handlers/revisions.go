@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/diff"
+	"github.com/italia/developers-italia-api/internal/handlers/general"
+	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+	"gorm.io/gorm"
+)
+
+type Revision[T models.Model] struct {
+	db *gorm.DB
+}
+
+func NewRevision[T models.Model](db *gorm.DB) *Revision[T] {
+	return &Revision[T]{db: db}
+}
+
+// GetRevisions gets the revisions of the resource (fe. a specific Software
+// or Publisher) with the given ID, even if it was deleted, and returns any
+// error encountered.
+func (p *Revision[T]) GetRevisions(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Revisions"
+
+	var revisions []models.Revision
+
+	var resource T
+
+	entityID := ctx.Params("id")
+
+	latest, err := latestRevision(p.db, resource.TableName(), entityID)
+	if err != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	// Resources last changed before revisions were introduced have none.
+	if latest == 0 {
+		if err := p.db.First(&resource, "id = ?", entityID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return common.Error(fiber.StatusNotFound, errMsg, "resource was not found")
+			}
+
+			return common.InternalServerError(errMsg)
+		}
+	}
+
+	stmt := p.db.Where("entity_type = ? AND entity_id = ?", resource.TableName(), entityID)
+
+	// Revisions are returned in descending order, last first
+	paginator, err := general.NewPaginatorWithConfig(ctx, &paginator.Config{Order: paginator.DESC})
+	if err != nil {
+		return common.Error(fiber.StatusUnprocessableEntity, errMsg, err.Error())
+	}
+
+	result, cursor, err := paginator.Paginate(stmt, &revisions)
+	if err != nil {
+		return common.Error(
+			fiber.StatusUnprocessableEntity,
+			errMsg,
+			"wrong cursor format in page[after] or page[before]",
+		)
+	}
+
+	if result.Error != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(fiber.Map{"data": &revisions, "links": general.NewPaginationLinks(ctx.Queries(), cursor)})
+}
+
+// GetRevisionsDiff gets the changes between two revisions of the resource
+// with the given ID, the `from` and `to` query parameters. `to` defaults to
+// the last revision and `from` to the one before `to`, where 0 means before
+// the resource was created.
+//
+// The publiccodeYml of Software is compared key by key.
+func (p *Revision[T]) GetRevisionsDiff(ctx *fiber.Ctx) error { //nolint:cyclop // mostly error handling ifs
+	const errMsg = "can't get Revisions diff"
+
+	var resource T
+
+	entityID := ctx.Params("id")
+
+	latest, err := latestRevision(p.db, resource.TableName(), entityID)
+	if err != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	if latest == 0 {
+		return common.Error(fiber.StatusNotFound, errMsg, "resource was not found")
+	}
+
+	toVersion := latest
+	if raw := ctx.Query("to"); raw != "" {
+		if toVersion, err = strconv.Atoi(raw); err != nil || toVersion < 1 {
+			return common.Error(fiber.StatusUnprocessableEntity, errMsg, "to must be a positive integer")
+		}
+	}
+
+	fromVersion := toVersion - 1
+	if raw := ctx.Query("from"); raw != "" {
+		if fromVersion, err = strconv.Atoi(raw); err != nil || fromVersion < 0 {
+			return common.Error(fiber.StatusUnprocessableEntity, errMsg, "from must be a non-negative integer")
+		}
+	}
+
+	if toVersion > latest || fromVersion > latest {
+		return common.Error(fiber.StatusNotFound, errMsg, "Revision was not found")
+	}
+
+	var revisions []models.Revision
+
+	if err := p.db.
+		Where("entity_type = ? AND entity_id = ?", resource.TableName(), entityID).
+		Where("version IN ?", []int{fromVersion, toVersion}).
+		Find(&revisions).Error; err != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	data := map[int][]byte{}
+	for _, revision := range revisions {
+		data[revision.Version] = []byte(revision.Data)
+	}
+
+	changes, err := diff.JSON(data[fromVersion], data[toVersion], "publiccodeYml")
+	if err != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(fiber.Map{"from": fromVersion, "to": toVersion, "changes": changes})
+}
+
+// getAsOf responds with the entity as it was at the time in the `asOf`
+// query parameter, from its last revision before then.
+func getAsOf(ctx *fiber.Ctx, gormdb *gorm.DB, entity models.Model, errMsg string, notFound string) error {
+	asOf, err := time.Parse(time.RFC3339, ctx.Query("asOf"))
+	if err != nil {
+		return common.Error(fiber.StatusUnprocessableEntity, errMsg, "asOf must be a RFC 3339 timestamp")
+	}
+
+	revision := models.Revision{}
+
+	if err := gormdb.
+		Where("entity_type = ? AND entity_id = ?", entity.TableName(), entity.UUID()).
+		Where("created_at <= ?", asOf).
+		Order("version DESC").
+		First(&revision).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, notFound)
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	if revision.Type == common.EventTypeDelete {
+		return common.Error(fiber.StatusNotFound, errMsg, notFound)
+	}
+
+	return ctx.JSON(json.RawMessage(revision.Data))
+}
+
+// maxRevisionAttempts bounds the retries of a saveRevision racing with
+// another request on the same entity.
+const maxRevisionAttempts = 5
+
+var errRevisionContention = errors.New("too many concurrent revisions")
+
+// saveRevision appends a revision of entity, as returned by the API, for a
+// change of revisionType (fe. common.EventTypeUpdate).
+//
+// Another request might save a revision of the same entity with the version
+// read here first: the revision is saved again with the next version, in a
+// savepoint so the transaction stays usable.
+func saveRevision(tran *gorm.DB, revisionType string, entity models.Model) error {
+	var data []byte
+
+	if revisionType != common.EventTypeDelete {
+		var err error
+
+		if data, err = json.Marshal(entity); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	for range maxRevisionAttempts {
+		err := tran.Transaction(func(savepoint *gorm.DB) error {
+			version, err := latestRevision(savepoint, entity.TableName(), entity.UUID())
+			if err != nil {
+				return err
+			}
+
+			return savepoint.Create(&models.Revision{ //nolint:wrapcheck
+				EntityType: entity.TableName(),
+				EntityID:   entity.UUID(),
+				Version:    version + 1,
+				Type:       revisionType,
+				Data:       string(data),
+			}).Error
+		})
+		if common.DuplicateField(err) == nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return errRevisionContention
+}
+
+// latestRevision returns the version of the last revision of an entity, 0
+// if it has none.
+func latestRevision(gormdb *gorm.DB, entityType string, entityID string) (int, error) {
+	var version int
+
+	err := gormdb.Model(&models.Revision{}).
+		Select("COALESCE(MAX(version), 0)").
+		Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Scan(&version).Error
+
+	return version, err //nolint:wrapcheck
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/handlers/general"
+	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultMaxDeactivated is the percentage of the active software of a
+// catalog a run can deactivate, if not set when opening it.
+const defaultMaxDeactivated = 10
+
+var errRunNotOpen = errors.New("Run is not open")
+
+type CatalogRunInterface interface {
+	GetCatalogRuns(ctx *fiber.Ctx) error
+	GetCatalogRun(ctx *fiber.Ctx) error
+	PostCatalogRun(ctx *fiber.Ctx) error
+	PostCatalogRunURLs(ctx *fiber.Ctx) error
+	PostCatalogRunClose(ctx *fiber.Ctx) error
+}
+
+type CatalogRun struct {
+	db *gorm.DB
+}
+
+func NewCatalogRun(db *gorm.DB) *CatalogRun {
+	return &CatalogRun{db: db}
+}
+
+// GetCatalogRuns lists the runs of the given catalog, last first.
+func (c *CatalogRun) GetCatalogRuns(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Runs"
+
+	catalog, err := resolveCatalog(c.db, ctx.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Catalog was not found")
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	var runs []models.CatalogRun
+
+	stmt := c.db.Scopes(catalogScope(catalog))
+
+	paginator, err := general.NewPaginatorWithConfig(ctx, &paginator.Config{Order: paginator.DESC})
+	if err != nil {
+		return common.Error(fiber.StatusUnprocessableEntity, errMsg, err.Error())
+	}
+
+	result, cursor, err := paginator.Paginate(stmt, &runs)
+	if err != nil {
+		return common.Error(
+			fiber.StatusUnprocessableEntity,
+			errMsg,
+			"wrong cursor format in page[after] or page[before]",
+		)
+	}
+
+	if result.Error != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(fiber.Map{"data": &runs, "links": general.NewPaginationLinks(ctx.Queries(), cursor)})
+}
+
+// GetCatalogRun gets a run of the given catalog.
+func (c *CatalogRun) GetCatalogRun(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Run"
+
+	_, run, err := c.loadRun(ctx, errMsg)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(run)
+}
+
+// PostCatalogRun opens a run of the given catalog.
+func (c *CatalogRun) PostCatalogRun(ctx *fiber.Ctx) error {
+	const errMsg = "can't create Run"
+
+	catalog, err := resolveCatalog(c.db, ctx.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Catalog was not found")
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	request := new(common.CatalogRunPost)
+
+	if err := common.ValidateRequestEntity(ctx, request, errMsg); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	run := models.CatalogRun{
+		ID:             utils.UUIDv4(),
+		Status:         common.RunStatusOpen,
+		MaxDeactivated: defaultMaxDeactivated,
+	}
+
+	if !isRoot(catalog) {
+		run.CatalogID = &catalog.ID
+	}
+
+	if request.MaxDeactivated != nil {
+		run.MaxDeactivated = *request.MaxDeactivated
+	}
+
+	if err := c.db.Create(&run).Error; err != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(&run)
+}
+
+// PostCatalogRunURLs adds a chunk of the URLs of the software seen by an
+// open run. The URLs are normalized, and the ones already seen are ignored.
+func (c *CatalogRun) PostCatalogRunURLs(ctx *fiber.Ctx) error {
+	const errMsg = "can't add Run URLs"
+
+	_, run, err := c.loadRun(ctx, errMsg)
+	if err != nil {
+		return err
+	}
+
+	request := new(common.CatalogRunURLs)
+
+	if err := common.ValidateRequestEntity(ctx, request, errMsg); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	urls := make([]string, 0, len(request.URLs))
+	for _, url := range request.URLs {
+		urls = append(urls, common.NormalizeURL(url))
+	}
+
+	slices.Sort(urls)
+
+	runURLs := make([]models.CatalogRunURL, 0, len(urls))
+	for _, url := range slices.Compact(urls) {
+		runURLs = append(runURLs, models.CatalogRunURL{CatalogRunID: run.ID, URL: url})
+	}
+
+	if err := c.db.Transaction(func(tran *gorm.DB) error {
+		result := tran.Clauses(clause.OnConflict{DoNothing: true}).Create(&runURLs)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// Updating the run also waits for a concurrent close to complete
+		result = tran.Model(run).
+			Where("status = ?", common.RunStatusOpen).
+			Update("seen", gorm.Expr("seen + ?", result.RowsAffected))
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errRunNotOpen
+		}
+
+		return tran.First(run, "id = ?", run.ID).Error
+	}); err != nil {
+		if errors.Is(err, errRunNotOpen) {
+			return common.Error(fiber.StatusConflict, errMsg, err.Error())
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(run)
+}
+
+// PostCatalogRunClose closes an open run, deactivating the active software
+// of the catalog with none of its URLs seen by the run. Software created
+// after the run was opened counts as seen.
+//
+// If the software to deactivate is more than the maxDeactivated percent of
+// the active software, the run is aborted instead and nothing changes.
+// With `dryRun=true` it responds with the run as it would be after closing
+// it, without changing anything.
+func (c *CatalogRun) PostCatalogRunClose(ctx *fiber.Ctx) error { //nolint:cyclop,funlen
+	const errMsg = "can't close Run"
+
+	catalog, run, err := c.loadRun(ctx, errMsg)
+	if err != nil {
+		return err
+	}
+
+	dryRun := ctx.QueryBool("dryRun", false)
+
+	var active int64
+
+	if err := c.db.Transaction(func(tran *gorm.DB) error {
+		if err := tran.Model(&models.Software{}).
+			Scopes(catalogScope(catalog), models.Active).
+			Count(&active).Error; err != nil {
+			return err
+		}
+
+		if err := tran.Model(&models.Software{}).
+			Scopes(catalogScope(catalog), models.Active).
+			Where("created_at < ?", run.CreatedAt).
+			Where("NOT EXISTS (?)", tran.Table("software_urls").
+				Select("1").
+				Joins("JOIN catalog_run_urls ON catalog_run_urls.url = software_urls.url").
+				Where("software_urls.software_id = software.id AND catalog_run_urls.catalog_run_id = ?", run.ID),
+			).
+			Order("id").
+			Pluck("id", &run.Software).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		run.Status = common.RunStatusClosed
+		run.Deactivated = len(run.Software)
+		run.ClosedAt = &now
+
+		if int64(run.Deactivated)*100 > int64(run.MaxDeactivated)*active {
+			run.Status = common.RunStatusAborted
+			run.Deactivated = 0
+		}
+
+		if dryRun {
+			return nil
+		}
+
+		// Only one request can close the run
+		result := tran.Model(run).
+			Where("status = ?", common.RunStatusOpen).
+			Select("Status", "Deactivated", "ClosedAt").
+			Updates(run)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errRunNotOpen
+		}
+
+		// The seen URLs aren't needed anymore
+		if err := tran.Where("catalog_run_id = ?", run.ID).Delete(&models.CatalogRunURL{}).Error; err != nil {
+			return err
+		}
+
+		if run.Status == common.RunStatusAborted {
+			return nil
+		}
+
+		return deactivateSoftware(tran, run.Software)
+	}); err != nil {
+		if errors.Is(err, errRunNotOpen) {
+			return common.Error(fiber.StatusConflict, errMsg, err.Error())
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	if run.Status == common.RunStatusAborted && !dryRun {
+		return common.Error(
+			fiber.StatusConflict,
+			errMsg,
+			fmt.Sprintf(
+				"%d of the %d active Software would be deactivated, more than maxDeactivated (%d%%)",
+				len(run.Software), active, run.MaxDeactivated,
+			),
+		)
+	}
+
+	return ctx.JSON(run)
+}
+
+// loadRun loads the run in the path, checking it belongs to the catalog in
+// the path, and returns the error response if it can't.
+func (c *CatalogRun) loadRun(ctx *fiber.Ctx, errMsg string) (*models.Catalog, *models.CatalogRun, error) {
+	catalog, err := resolveCatalog(c.db, ctx.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, common.Error(fiber.StatusNotFound, errMsg, "Catalog was not found")
+		}
+
+		return nil, nil, common.InternalServerError(errMsg)
+	}
+
+	run := models.CatalogRun{}
+
+	if err := c.db.Scopes(catalogScope(catalog)).First(&run, "id = ?", ctx.Params("runId")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, common.Error(fiber.StatusNotFound, errMsg, "Run was not found")
+		}
+
+		return nil, nil, common.InternalServerError(errMsg)
+	}
+
+	if run.Status != common.RunStatusOpen && ctx.Method() != fiber.MethodGet {
+		return nil, nil, common.Error(fiber.StatusConflict, errMsg, errRunNotOpen.Error())
+	}
+
+	return catalog, &run, nil
+}
+
+// deactivateSoftware sets the software with ids as not active, notifying
+// the webhooks and recording the revisions.
+func deactivateSoftware(tran *gorm.DB, ids []string) error {
+	for batch := range slices.Chunk(ids, 100) { //nolint:mnd
+		var software []models.Software
+
+		if err := tran.Preload("Aliases").Where("id IN ?", batch).Find(&software).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		splitCanonicalURLs(software)
+
+		for i := range software {
+			swr := &software[i]
+			swr.Active = new(bool)
+
+			if err := tran.Model(swr).Update("active", false).Error; err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			sort.Slice(swr.Aliases, func(a int, b int) bool {
+				return swr.Aliases[a].URL < swr.Aliases[b].URL
+			})
+
+			if err := saveRevision(tran, common.EventTypeUpdate, swr); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
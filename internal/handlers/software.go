@@ -13,17 +13,28 @@ import (
 	"github.com/italia/developers-italia-api/internal/common"
 	"github.com/italia/developers-italia-api/internal/handlers/general"
 	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/italia/developers-italia-api/internal/ownership"
+	"github.com/italia/developers-italia-api/internal/publiccode"
+	"github.com/italia/developers-italia-api/internal/search"
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
 	"gorm.io/gorm"
 )
 
 type SoftwareInterface interface {
 	GetAllSoftware(ctx *fiber.Ctx) error
+	GetSoftwareFacets(ctx *fiber.Ctx) error
 	GetSoftware(ctx *fiber.Ctx) error
+	GetSoftwareCopies(ctx *fiber.Ctx) error
 	PostSoftware(ctx *fiber.Ctx) error
+	PutSoftware(ctx *fiber.Ctx) error
 	PatchSoftware(ctx *fiber.Ctx) error
 	DeleteSoftware(ctx *fiber.Ctx) error
+	RestoreSoftware(ctx *fiber.Ctx) error
+	MergeSoftware(ctx *fiber.Ctx) error
 	GetSoftwareAnalysis(ctx *fiber.Ctx) error
 	PatchSoftwareAnalysis(ctx *fiber.Ctx) error
+	GetSoftwareVitality(ctx *fiber.Ctx) error
+	PostSoftwareVitality(ctx *fiber.Ctx) error
 }
 
 type Software struct {
@@ -33,6 +44,8 @@ type Software struct {
 var (
 	errLoadNotFound = errors.New("Software was not found")
 	errLoad         = errors.New("error while loading Software")
+	errManyCatalogs = errors.New("url belongs to Software of more Catalogs")
+	errTrashed      = errors.New("url belongs to Software in the trash")
 )
 
 func NewSoftware(db *gorm.DB) *Software {
@@ -43,44 +56,54 @@ func NewSoftware(db *gorm.DB) *Software {
 func (p *Software) GetAllSoftware(ctx *fiber.Ctx) error { //nolint:cyclop // mostly error handling ifs
 	var software []models.Software
 
+	// A URL that software doesn't have anymore redirects to it, unless the
+	// software of another catalog still has it
+	if url := common.NormalizeURL(ctx.Query("url")); url != "" {
+		var count int64
+		if err := p.db.Model(&models.SoftwareURL{}).Where("url = ?", url).Count(&count).Error; err != nil {
+			return common.InternalServerError("can't get Software")
+		}
+
+		if count == 0 {
+			redirected, err := redirectMoved(ctx, p.db, models.SoftwareURL{}.TableName(), url, "/v1/software/")
+			if err != nil {
+				return common.InternalServerError("can't get Software")
+			}
+
+			if redirected {
+				return nil
+			}
+		}
+	}
+
 	// Preload will load all the associated aliases, which include
 	// also the canonical url. We'll manually handle that later.
-	stmt := p.db.Preload("Aliases")
-
-	stmt, err := general.Clauses(ctx, stmt, "")
+	stmt, err := softwareListFilters(ctx, p.db, p.db.Preload("Aliases"), "can't get Software")
 	if err != nil {
-		return common.Error(
-			fiber.StatusUnprocessableEntity,
-			"can't get Software",
-			err.Error(),
-		)
+		return err
 	}
 
-	// Return just software with a certain URL if the 'url' query filter
-	// is used.
-	if url := common.NormalizeURL(ctx.Query("url", "")); url != "" {
-		var softwareURL models.SoftwareURL
-
-		if err = p.db.First(&softwareURL, "url = ?", url).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return ctx.JSON(fiber.Map{"data": []any{}, "links": general.PaginationLinks{}})
-			}
+	stmt, err = catalogListFilters(ctx, p.db, stmt, "can't get Software")
+	if err != nil {
+		return err
+	}
 
-			return common.Error(
-				fiber.StatusInternalServerError,
-				"can't get Software",
-				fiber.ErrInternalServerError.Message,
-			)
-		}
+	pageConfig := &paginator.Config{}
 
-		stmt = stmt.Where("id = ?", softwareURL.SoftwareID)
+	// Full-text search, the results are ordered by relevance
+	query := ctx.Query("q")
+	if query != "" {
+		stmt = stmt.Table("(?) AS software", search.Software(p.db, query))
+		pageConfig.Rules = search.PaginationRules("software")
 	}
 
-	if all := ctx.QueryBool("all", false); !all {
-		stmt = stmt.Scopes(models.Active)
+	if rules, err := softwareSortRules(ctx); err != nil {
+		return common.Error(fiber.StatusUnprocessableEntity, "can't get Software", err.Error())
+	} else if rules != nil {
+		pageConfig.Rules = rules
 	}
 
-	paginator, err := general.NewPaginator(ctx)
+	paginator, err := general.NewPaginatorWithConfig(ctx, pageConfig)
 	if err != nil {
 		return common.Error(fiber.StatusUnprocessableEntity, "can't get Software", err.Error())
 	}
@@ -102,9 +125,19 @@ func (p *Software) GetAllSoftware(ctx *fiber.Ctx) error { //nolint:cyclop // mos
 		)
 	}
 
-	// Remove the canonical URL from the aliases, because it need to be its own
-	// field. It was loaded previously together with the other aliases in Preload(),
-	// because of limitation in gorm.
+	for swIdx := range software {
+		software[swIdx].Snippet = search.Snippet(software[swIdx].Snippet, query)
+	}
+
+	splitCanonicalURLs(software)
+
+	return ctx.JSON(fiber.Map{"data": &software, "links": general.NewPaginationLinks(ctx.Queries(), cursor)})
+}
+
+// splitCanonicalURLs removes the canonical URL from the aliases of software,
+// because it need to be its own field. It was loaded previously together with
+// the other aliases in Preload(), because of limitation in gorm.
+func splitCanonicalURLs(software []models.Software) {
 	for swIdx := range software {
 		swr := &software[swIdx]
 
@@ -121,18 +154,57 @@ func (p *Software) GetAllSoftware(ctx *fiber.Ctx) error { //nolint:cyclop // mos
 			}
 		}
 	}
+}
+
+// GetSoftwareFacets counts the software by the requested facets, with the
+// filters of GetAllSoftware.
+func (p *Software) GetSoftwareFacets(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Software facets"
+
+	stmt, err := softwareListFilters(ctx, p.db, p.db.Model(&models.Software{}), errMsg)
+	if err != nil {
+		return err
+	}
 
-	return ctx.JSON(fiber.Map{"data": &software, "links": general.NewPaginationLinks(ctx.Queries(), cursor)})
+	stmt, err = catalogListFilters(ctx, p.db, stmt, errMsg)
+	if err != nil {
+		return err
+	}
+
+	if query := ctx.Query("q"); query != "" {
+		stmt = stmt.Table("(?) AS software", search.Software(p.db, query))
+	}
+
+	counts, err := countFacets(ctx, p.db, stmt, errMsg)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(counts)
 }
 
 // GetSoftware gets the software with the given ID and returns any error encountered.
 func (p *Software) GetSoftware(ctx *fiber.Ctx) error {
 	const errMsg = "can't get Software"
 
+	if ctx.Query("asOf") != "" {
+		return getAsOf(ctx, p.db, models.Software{ID: ctx.Params("id")}, errMsg, "Software was not found")
+	}
+
 	software := models.Software{}
 
 	if err := loadSoftware(p.db, &software, ctx.Params("id")); err != nil {
 		if errors.Is(err, errLoadNotFound) {
+			// Software merged into another one redirects to it
+			redirected, err := redirectMoved(ctx, p.db, software.TableName(), ctx.Params("id"), "/v1/software/")
+			if err != nil {
+				return common.InternalServerError(errMsg)
+			}
+
+			if redirected {
+				return nil
+			}
+
 			return common.Error(fiber.StatusNotFound, errMsg, "Software was not found")
 		}
 
@@ -142,6 +214,53 @@ func (p *Software) GetSoftware(ctx *fiber.Ctx) error {
 	return ctx.JSON(&software)
 }
 
+// GetSoftwareCopies lists the software of the other catalogs having any of
+// the URLs of the software with the given ID, so referring to the same
+// repository.
+func (p *Software) GetSoftwareCopies(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Software copies"
+
+	software := models.Software{}
+
+	if err := p.db.First(&software, "id = ?", ctx.Params("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Software was not found")
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	stmt := p.db.Preload("Aliases").
+		Where("id <> ?", software.ID).
+		Where("id IN (?)", p.db.Model(&models.SoftwareURL{}).
+			Select("software_id").
+			Where("url IN (?)", p.db.Model(&models.SoftwareURL{}).Select("url").Where("software_id = ?", software.ID)))
+
+	var copies []models.Software
+
+	paginator, err := general.NewPaginatorWithConfig(ctx, &paginator.Config{})
+	if err != nil {
+		return common.Error(fiber.StatusUnprocessableEntity, errMsg, err.Error())
+	}
+
+	result, cursor, err := paginator.Paginate(stmt, &copies)
+	if err != nil {
+		return common.Error(
+			fiber.StatusUnprocessableEntity,
+			errMsg,
+			"wrong cursor format in page[after] or page[before]",
+		)
+	}
+
+	if result.Error != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	splitCanonicalURLs(copies)
+
+	return ctx.JSON(fiber.Map{"data": &copies, "links": general.NewPaginationLinks(ctx.Queries(), cursor)})
+}
+
 // PostSoftware creates a new software.
 func (p *Software) PostSoftware(ctx *fiber.Ctx) error {
 	const errMsg = "can't create Software"
@@ -152,32 +271,31 @@ func (p *Software) PostSoftware(ctx *fiber.Ctx) error {
 		return err //nolint:wrapcheck
 	}
 
-	aliases := []models.SoftwareURL{}
-	for _, u := range softwareReq.Aliases {
-		aliases = append(aliases, models.SoftwareURL{ID: utils.UUIDv4(), URL: common.NormalizeURL(u)})
+	publicCode, err := parsePubliccode(ctx, softwareReq.PubliccodeYml, errMsg)
+	if err != nil {
+		return err
 	}
 
-	url := models.SoftwareURL{ID: utils.UUIDv4(), URL: common.NormalizeURL(softwareReq.URL)}
-	software := models.Software{
-		ID: utils.UUIDv4(),
+	software := newSoftware(softwareReq, nil)
 
-		// Manually set the URL and its foreign key because of a limitation in gorm
-		URL:           url,
-		SoftwareURLID: url.ID,
-
-		Aliases:       aliases,
-		PubliccodeYml: softwareReq.PubliccodeYml,
-		Active:        softwareReq.Active,
-		Vitality:      softwareReq.Vitality,
-	}
+	publiccode.SetFields(&software, publicCode)
 
-	if err := p.db.Create(&software).Error; err != nil {
-		return common.Error(fiber.StatusInternalServerError, errMsg, err.Error())
+	if err := p.db.Transaction(func(tran *gorm.DB) error {
+		return createSoftware(tran, &software)
+	}); err != nil {
+		return softwareSaveError(err, errMsg)
 	}
 
 	return ctx.JSON(&software)
 }
 
+// PutSoftware creates the software with the URL in the `url` query parameter,
+// or updates the one having it as URL or alias, whatever its catalog. If
+// more catalogs have it, the root one's software wins, if any.
+func (p *Software) PutSoftware(ctx *fiber.Ctx) error {
+	return upsertSoftware(ctx, p.db, nil, true)
+}
+
 // PatchSoftware updates the software with the given ID.
 func (p *Software) PatchSoftware(ctx *fiber.Ctx) error { //nolint:cyclop
 	const errMsg = "can't update Software"
@@ -204,8 +322,17 @@ func (p *Software) PatchSoftware(ctx *fiber.Ctx) error { //nolint:cyclop
 		return common.Error(patchErr.Code, errMsg, patchErr.Error())
 	}
 
-	// Catalog assignment is immutable via this endpoint.
-	updatedSoftware.CatalogID = software.CatalogID
+	publiccodeChanged := updatedSoftware.PubliccodeYml != software.PubliccodeYml
+	if publiccodeChanged {
+		publicCode, err := parsePubliccode(ctx, updatedSoftware.PubliccodeYml, errMsg)
+		if err != nil {
+			return err
+		}
+
+		publiccode.SetFields(&updatedSoftware, publicCode)
+	}
+
+	keepReadOnlyFields(software, &updatedSoftware)
 
 	updatedSoftware.URL.URL = common.NormalizeURL(updatedSoftware.URL.URL)
 
@@ -216,70 +343,534 @@ func (p *Software) PatchSoftware(ctx *fiber.Ctx) error { //nolint:cyclop
 	}
 
 	if err := p.db.Transaction(func(tran *gorm.DB) error {
-		//nolint:gocritic // it's fine, we want to append to another slice
-		currentURLs := append(software.Aliases, software.URL)
-
-		updatedURL, aliases, err := syncAliases(
-			tran,
-			software.ID,
-			currentURLs,
-			updatedSoftware.URL.URL,
-			expectedAliases,
-		)
-		if err != nil {
-			return err
+		return updateSoftware(tran, software, &updatedSoftware, expectedAliases, publiccodeChanged)
+	}); err != nil {
+		if field := common.DuplicateField(err); field != nil {
+			detail := alreadyExists
+			if *field != "" {
+				detail = *field + " " + alreadyExists
+			}
+
+			return common.Error(fiber.StatusConflict, errMsg, detail)
+		}
+
+		//nolint:wrapcheck // default to not wrap other errors, the handler will take care of this
+		return err
+	}
+
+	return ctx.JSON(&updatedSoftware)
+}
+
+// DeleteSoftware moves the software with the given ID to the trash. Its
+// URLs are kept, so they can't be used by other software until it's purged.
+func (p *Software) DeleteSoftware(ctx *fiber.Ctx) error {
+	deleted, err := deleteSoftware(p.db, models.Software{ID: ctx.Params("id")})
+	if err != nil {
+		return common.Error(fiber.StatusInternalServerError, "can't delete Software", "db error")
+	}
+
+	if !deleted {
+		return common.Error(fiber.StatusNotFound, "can't delete Software", "Software was not found")
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// deleteSoftware moves software to the trash, reporting whether it wasn't
+// there already.
+func deleteSoftware(gormdb *gorm.DB, software models.Software) (bool, error) {
+	var deleted bool
+
+	err := gormdb.Transaction(func(tran *gorm.DB) error {
+		result := tran.Delete(&software)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if deleted = result.RowsAffected > 0; !deleted {
+			return nil
+		}
+
+		return saveRevision(tran, common.EventTypeDelete, software)
+	})
+
+	return deleted, err //nolint:wrapcheck
+}
+
+// RestoreSoftware restores the software with the given ID from the trash,
+// linking it again to the publisher owning it.
+func (p *Software) RestoreSoftware(ctx *fiber.Ctx) error {
+	const errMsg = "can't restore Software"
+
+	software := models.Software{}
+	id := ctx.Params("id")
+
+	if err := p.db.Transaction(func(tran *gorm.DB) error {
+		if err := tran.Scopes(models.Deleted).First(&software, "id = ?", id).Error; err != nil {
+			return err //nolint:wrapcheck
 		}
 
-		// Manually set the canonical URL via the foreign key because of a limitation in gorm
-		updatedSoftware.SoftwareURLID = updatedURL.ID
-		updatedSoftware.URL = *updatedURL
+		if err := checkCatalogRestored(tran, software.CatalogID); err != nil {
+			return err
+		}
 
-		// Set Aliases to a zero value, so it's not touched by gorm's Update(),
-		// because we handle the alias manually
-		updatedSoftware.Aliases = []models.SoftwareURL{}
+		if err := tran.Unscoped().Model(&models.Software{ID: id}).Update("deleted_at", nil).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
 
-		if err := tran.Updates(&updatedSoftware).Error; err != nil {
+		software = models.Software{}
+		if err := loadSoftware(tran, &software, id); err != nil {
 			return err
 		}
 
-		updatedSoftware.Aliases = aliases
+		if err := ownership.Link(tran, &software); err != nil {
+			return err //nolint:wrapcheck
+		}
 
-		return nil
+		return saveRevision(tran, common.EventTypeUpdate, software)
 	}); err != nil {
-		if field := common.DuplicateField(err); field != nil {
-			detail := "already exists"
-			if *field != "" {
-				detail = *field + " already exists"
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Software was not found in the trash")
+		}
+
+		if errors.Is(err, errCatalogTrashed) {
+			return common.Error(fiber.StatusConflict, errMsg, err.Error())
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(&software)
+}
+
+// MergeSoftware merges the software with the id in the body, a duplicate,
+// into the software with the given ID. The URLs, logs and webhooks of the
+// duplicate are moved to it, together with the analysis namespaces it
+// doesn't have, then the duplicate is deleted and its ID redirects to it.
+func (p *Software) MergeSoftware(ctx *fiber.Ctx) error { //nolint:cyclop,funlen
+	const errMsg = "can't merge Software"
+
+	request := new(common.MergePost)
+
+	if err := common.ValidateRequestEntity(ctx, request, errMsg); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	id := ctx.Params("id")
+	if request.ID == id {
+		return common.Error(fiber.StatusUnprocessableEntity, errMsg, errMergeSelf.Error())
+	}
+
+	software := models.Software{}
+
+	if err := p.db.Transaction(func(tran *gorm.DB) error {
+		if err := tran.First(&software, "id = ?", id).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		duplicate := models.Software{}
+		if err := tran.First(&duplicate, "id = ?", request.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errDuplicateNotFound
 			}
 
-			return common.Error(fiber.StatusConflict, errMsg, detail)
+			return err //nolint:wrapcheck
 		}
 
-		//nolint:wrapcheck // default to not wrap other errors, the handler will take care of this
+		// The URLs the catalog of the software already has, fe. the duplicate
+		// is in another catalog, stay with the software having them
+		urlCatalog := urlCatalogID(software.CatalogID)
+
+		if err := tran.
+			Where("software_id = ?", duplicate.ID).
+			Where("url IN (?)", tran.Model(&models.SoftwareURL{}).Select("url").Where("catalog_id = ?", urlCatalog)).
+			Delete(&models.SoftwareURL{}).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := tran.Model(&models.SoftwareURL{}).
+			Where("software_id = ?", duplicate.ID).
+			Updates(map[string]any{"software_id": software.ID, "catalog_id": urlCatalog}).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := tran.Unscoped().Model(&models.Log{}).
+			Where("entity_type = ? AND entity_id = ?", duplicate.TableName(), duplicate.ID).
+			UpdateColumn("entity_id", software.ID).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := moveWebhooks(tran, duplicate.TableName(), duplicate.ID, software.ID); err != nil {
+			return err
+		}
+
+		// The vitality of the software is the one that counts
+		if err := tran.Where("software_id = ?", duplicate.ID).Delete(&models.SoftwareVitality{}).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := tran.Unscoped().Select("Descriptions", "Terms").Delete(&duplicate).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if err := saveRevision(tran, common.EventTypeDelete, duplicate); err != nil {
+			return err
+		}
+
+		if err := saveRedirects(tran, duplicate.TableName(), []string{duplicate.ID}, software.ID); err != nil {
+			return err
+		}
+
+		// The namespaces of the software win over the ones of the duplicate
+		analysis := maps.Clone(duplicate.Analysis)
+		if analysis == nil {
+			analysis = common.AnalysisData{}
+		}
+
+		maps.Copy(analysis, software.Analysis)
+
+		if err := tran.Model(&software).Update("analysis", analysis).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		software = models.Software{}
+		if err := loadSoftware(tran, &software, id); err != nil {
+			return err
+		}
+
+		if err := ownership.Link(tran, &software); err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		sort.Slice(software.Aliases, func(a int, b int) bool {
+			return software.Aliases[a].URL < software.Aliases[b].URL
+		})
+
+		return saveRevision(tran, common.EventTypeUpdate, software)
+	}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Software was not found")
+		}
+
+		if errors.Is(err, errDuplicateNotFound) {
+			return common.Error(fiber.StatusUnprocessableEntity, errMsg, err.Error())
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(&software)
+}
+
+// newSoftware returns the software to create from softwareReq, in the catalog
+// with catalogID (nil for the root one).
+func newSoftware(softwareReq *common.SoftwarePost, catalogID *string) models.Software {
+	urlCatalog := urlCatalogID(catalogID)
+
+	aliases := []models.SoftwareURL{}
+	for _, u := range softwareReq.Aliases {
+		aliases = append(aliases, models.SoftwareURL{
+			ID:        utils.UUIDv4(),
+			URL:       common.NormalizeURL(u),
+			CatalogID: urlCatalog,
+		})
+	}
+
+	url := models.SoftwareURL{ID: utils.UUIDv4(), URL: common.NormalizeURL(softwareReq.URL), CatalogID: urlCatalog}
+
+	return models.Software{
+		ID: utils.UUIDv4(),
+
+		// Manually set the URL and its foreign key because of a limitation in gorm
+		URL:           url,
+		SoftwareURLID: url.ID,
+
+		CatalogID:     catalogID,
+		Aliases:       aliases,
+		PubliccodeYml: softwareReq.PubliccodeYml,
+		Active:        softwareReq.Active,
+	}
+}
+
+// createSoftware saves the new software, so its URLs don't redirect anymore,
+// links it to its publisher and records its first revision.
+func createSoftware(tran *gorm.DB, software *models.Software) error {
+	if err := tran.Create(software).Error; err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	urls := []string{software.URL.URL}
+	for _, alias := range software.Aliases {
+		urls = append(urls, alias.URL)
+	}
+
+	if err := deleteURLRedirects(tran, urls); err != nil {
 		return err
 	}
 
+	if err := ownership.Link(tran, software); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return saveRevision(tran, common.EventTypeCreate, software)
+}
+
+// updateSoftware saves updatedSoftware over software, with expectedAliases
+// as aliases, and records the revision.
+func updateSoftware(
+	tran *gorm.DB,
+	software models.Software,
+	updatedSoftware *models.Software,
+	expectedAliases []string,
+	publiccodeChanged bool,
+) error {
+	//nolint:gocritic // it's fine, we want to append to another slice
+	currentURLs := append(software.Aliases, software.URL)
+
+	updatedURL, aliases, err := syncAliases(
+		tran,
+		software.ID,
+		urlCatalogID(software.CatalogID),
+		currentURLs,
+		updatedSoftware.URL.URL,
+		expectedAliases,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Manually set the canonical URL via the foreign key because of a limitation in gorm
+	updatedSoftware.SoftwareURLID = updatedURL.ID
+	updatedSoftware.URL = *updatedURL
+
+	// Set Aliases to a zero value, so it's not touched by gorm's Update(),
+	// because we handle the alias manually
+	updatedSoftware.Aliases = []models.SoftwareURL{}
+
+	// The fields extracted from publiccodeYml are saved on their own
+	if err := tran.Omit("Descriptions", "Terms").Updates(updatedSoftware).Error; err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if publiccodeChanged {
+		if err := publiccode.SaveFields(tran, updatedSoftware); err != nil {
+			return err
+		}
+	}
+
+	updatedSoftware.Aliases = aliases
+
+	if err := ownership.Link(tran, updatedSoftware); err != nil {
+		return err //nolint:wrapcheck
+	}
+
 	// Sort the aliases to always have a consistent output
 	sort.Slice(updatedSoftware.Aliases, func(a int, b int) bool {
 		return updatedSoftware.Aliases[a].URL < updatedSoftware.Aliases[b].URL
 	})
 
-	return ctx.JSON(&updatedSoftware)
+	return saveRevision(tran, common.EventTypeUpdate, updatedSoftware)
 }
 
-// DeleteSoftware deletes the software with the given ID.
-func (p *Software) DeleteSoftware(ctx *fiber.Ctx) error {
-	result := p.db.Select("Aliases").Delete(&models.Software{ID: ctx.Params("id")})
+// upsertSoftware creates or updates, in a single transaction, the software
+// having the URL in the `url` query parameter as URL or alias, from a
+// SoftwarePost body having it as url or alias too. It responds with 201 if
+// it created the software and 200 if it updated it, leaving it untouched if
+// nothing changed. The `active` field keeps its value when omitted.
+//
+// New software goes in catalog, the root one if nil, and existing software
+// is looked up in it, or in all the catalogs if anyCatalog is set, the root
+// one first.
+func upsertSoftware( //nolint:cyclop,funlen
+	ctx *fiber.Ctx,
+	gormdb *gorm.DB,
+	catalog *models.Catalog,
+	anyCatalog bool,
+) error {
+	const errMsg = "can't create or update Software"
+
+	key := common.NormalizeURL(ctx.Query("url"))
+	if key == "" {
+		return common.Error(fiber.StatusUnprocessableEntity, errMsg, "url is required")
+	}
 
-	if result.Error != nil {
-		return common.Error(fiber.StatusInternalServerError, "can't delete Software", "db error")
+	softwareReq := new(common.SoftwarePost)
+
+	if err := common.ValidateRequestEntity(ctx, softwareReq, errMsg); err != nil {
+		return err //nolint:wrapcheck
 	}
 
-	if result.RowsAffected == 0 {
-		return common.Error(fiber.StatusNotFound, "can't delete Software", "Software was not found")
+	publicCode, err := parsePubliccode(ctx, softwareReq.PubliccodeYml, errMsg)
+	if err != nil {
+		return err
 	}
 
-	return ctx.SendStatus(fiber.StatusNoContent)
+	var catalogID *string
+	if !isRoot(catalog) {
+		catalogID = &catalog.ID
+	}
+
+	expectedURL := common.NormalizeURL(softwareReq.URL)
+
+	expectedAliases := make([]string, 0, len(softwareReq.Aliases))
+	for _, alias := range softwareReq.Aliases {
+		expectedAliases = append(expectedAliases, common.NormalizeURL(alias))
+	}
+
+	// Otherwise the software wouldn't be found by the same request again
+	if key != expectedURL && !slices.Contains(expectedAliases, key) {
+		return common.Error(fiber.StatusUnprocessableEntity, errMsg, "url must be the url or one of the aliases of the body")
+	}
+
+	var (
+		result  models.Software
+		created bool
+	)
+
+	upsert := func(tran *gorm.DB) error {
+		// The root catalog sorts first, its software wins over the one of
+		// the other catalogs
+		var softwareURLs []models.SoftwareURL
+
+		stmt := tran.Where("url = ?", key)
+		if !anyCatalog {
+			stmt = stmt.Where("catalog_id = ?", urlCatalogID(catalogID))
+		}
+
+		if err := stmt.Order("catalog_id").Find(&softwareURLs).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if len(softwareURLs) > 1 && softwareURLs[0].CatalogID != "" {
+			return errManyCatalogs
+		}
+
+		if len(softwareURLs) == 0 {
+			created = true
+			result = newSoftware(softwareReq, catalogID)
+
+			publiccode.SetFields(&result, publicCode)
+
+			return createSoftware(tran, &result)
+		}
+
+		created = false
+
+		software := models.Software{}
+		if err := loadSoftware(tran, &software, softwareURLs[0].SoftwareID); err != nil {
+			if errors.Is(err, errLoadNotFound) {
+				return errTrashed
+			}
+
+			return err
+		}
+
+		result = software
+		result.Aliases = slices.Clone(software.Aliases)
+		result.URL.URL = expectedURL
+		result.PubliccodeYml = softwareReq.PubliccodeYml
+
+		if softwareReq.Active != nil {
+			result.Active = softwareReq.Active
+		}
+
+		if softwareUnchanged(software, result, expectedAliases) {
+			sort.Slice(result.Aliases, func(a int, b int) bool {
+				return result.Aliases[a].URL < result.Aliases[b].URL
+			})
+
+			return nil
+		}
+
+		publiccodeChanged := result.PubliccodeYml != software.PubliccodeYml
+		if publiccodeChanged {
+			publiccode.SetFields(&result, publicCode)
+		}
+
+		return updateSoftware(tran, software, &result, expectedAliases, publiccodeChanged)
+	}
+
+	err = gormdb.Transaction(upsert)
+
+	// Another request created software with the same URL in the meantime,
+	// update it instead.
+	if created && common.DuplicateField(err) != nil {
+		err = gormdb.Transaction(upsert)
+	}
+
+	if err != nil {
+		if errors.Is(err, errManyCatalogs) || errors.Is(err, errTrashed) {
+			return common.Error(fiber.StatusConflict, errMsg, err.Error())
+		}
+
+		return softwareSaveError(err, errMsg)
+	}
+
+	if created {
+		ctx.Status(fiber.StatusCreated)
+	}
+
+	return ctx.JSON(&result)
+}
+
+// softwareUnchanged reports whether updated, from an upsert, is the same as
+// software, with expectedAliases as aliases.
+func softwareUnchanged(software models.Software, updated models.Software, expectedAliases []string) bool {
+	aliases := make([]string, 0, len(software.Aliases))
+	for _, alias := range software.Aliases {
+		aliases = append(aliases, alias.URL)
+	}
+
+	slices.Sort(aliases)
+
+	expected := slices.Sorted(slices.Values(expectedAliases))
+
+	return updated.URL.URL == software.URL.URL &&
+		slices.Equal(aliases, slices.Compact(expected)) &&
+		updated.PubliccodeYml == software.PubliccodeYml &&
+		equalPtr(updated.Active, software.Active)
+}
+
+// keepReadOnlyFields restores in updated the fields of software a patch
+// can't change: the catalog, changed only by moving the software, and the
+// vitality, computed from its data points.
+func keepReadOnlyFields(software models.Software, updated *models.Software) {
+	updated.CatalogID = software.CatalogID
+	updated.Vitality = software.Vitality
+}
+
+// urlCatalogID returns the CatalogID of the SoftwareURLs of the software in
+// the catalog with catalogID, nil for the root one.
+func urlCatalogID(catalogID *string) string {
+	if catalogID == nil {
+		return ""
+	}
+
+	return *catalogID
+}
+
+// equalPtr reports whether a and b are both nil or point to equal values.
+func equalPtr[T comparable](a *T, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+// softwareSaveError returns the error response for err, from saving
+// software: 409 if one of its URLs belongs to other software.
+func softwareSaveError(err error, errMsg string) error {
+	if field := common.DuplicateField(err); field != nil {
+		detail := alreadyExists
+		if *field != "" {
+			detail = *field + " " + alreadyExists
+		}
+
+		return common.Error(fiber.StatusConflict, errMsg, detail)
+	}
+
+	return common.InternalServerError(errMsg)
 }
 
 func loadSoftware(gormdb *gorm.DB, software *models.Software, id string) error {
@@ -305,17 +896,20 @@ func loadSoftware(gormdb *gorm.DB, software *models.Software, id string) error {
 }
 
 // syncAliases synchs the SoftwareURLs for a `Software` in the database to reflect the
-// passed list of `expectedAliases` and the canonical `url`.
+// passed list of `expectedAliases` and the canonical `url`, in the catalog with
+// `catalogID` (see models.SoftwareURL).
 //
 // It returns the new canonical SoftwareURL and the new slice of aliases or an error if any.
 func syncAliases( //nolint:cyclop // mostly error handling ifs
 	gormdb *gorm.DB,
 	softwareID string,
+	catalogID string,
 	currentURLs []models.SoftwareURL,
 	expectedURL string,
 	expectedAliases []string,
 ) (*models.SoftwareURL, []models.SoftwareURL, error) {
 	toRemove := []string{}          // Slice of SoftwareURL ids to remove from the database
+	removedURLs := []string{}       // Slice of the urls removed, redirecting to the software
 	toAdd := []models.SoftwareURL{} // Slice of SoftwareURLs to add to the database
 
 	// Map mirroring the state of SoftwareURLs for this software in the database,
@@ -332,6 +926,7 @@ func syncAliases( //nolint:cyclop // mostly error handling ifs
 	for urlStr, softwareURL := range urlMap {
 		if !slices.Contains(allSoftwareURLs, urlStr) {
 			toRemove = append(toRemove, softwareURL.ID)
+			removedURLs = append(removedURLs, urlStr)
 
 			delete(urlMap, urlStr)
 		}
@@ -340,7 +935,7 @@ func syncAliases( //nolint:cyclop // mostly error handling ifs
 	for _, urlStr := range allSoftwareURLs {
 		_, exists := urlMap[urlStr]
 		if !exists {
-			su := models.SoftwareURL{ID: utils.UUIDv4(), URL: urlStr, SoftwareID: softwareID}
+			su := models.SoftwareURL{ID: utils.UUIDv4(), URL: urlStr, CatalogID: catalogID, SoftwareID: softwareID}
 
 			toAdd = append(toAdd, su)
 			urlMap[urlStr] = su
@@ -351,12 +946,25 @@ func syncAliases( //nolint:cyclop // mostly error handling ifs
 		if err := gormdb.Delete(&models.SoftwareURL{}, toRemove).Error; err != nil {
 			return nil, nil, err
 		}
+
+		if err := saveRedirects(gormdb, models.SoftwareURL{}.TableName(), removedURLs, softwareID); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	if len(toAdd) > 0 {
 		if err := gormdb.Create(toAdd).Error; err != nil {
 			return nil, nil, err
 		}
+
+		addedURLs := make([]string, 0, len(toAdd))
+		for _, softwareURL := range toAdd {
+			addedURLs = append(addedURLs, softwareURL.URL)
+		}
+
+		if err := deleteURLRedirects(gormdb, addedURLs); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	updatedURL := urlMap[expectedURL]
@@ -409,6 +1017,12 @@ func (p *Software) PatchSoftwareAnalysis(ctx *fiber.Ctx) error {
 		return common.InternalServerError(errMsg)
 	}
 
+	return patchSoftwareAnalysis(ctx, p.db, software, errMsg)
+}
+
+// patchSoftwareAnalysis merges the analysis in the request body into the one
+// of software, responding with the result.
+func patchSoftwareAnalysis(ctx *fiber.Ctx, gormdb *gorm.DB, software models.Software, errMsg string) error {
 	var incoming common.AnalysisData
 	if err := json.Unmarshal(ctx.Body(), &incoming); err != nil {
 		return common.Error(fiber.StatusUnprocessableEntity, errMsg, err.Error())
@@ -419,7 +1033,7 @@ func (p *Software) PatchSoftwareAnalysis(ctx *fiber.Ctx) error {
 		return common.Error(fiber.StatusUnprocessableEntity, errMsg, err.Error())
 	}
 
-	if err := p.db.Model(&software).Update("analysis", merged).Error; err != nil {
+	if err := gormdb.Model(&software).Update("analysis", merged).Error; err != nil {
 		return common.InternalServerError(errMsg)
 	}
 
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/handlers/general"
+	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInvalidSort = errors.New("sort must be vitality")
+
+// GetSoftwareVitality lists the vitality data points of the software with
+// the given ID, oldest first, from the `from` date to the `to` one, both
+// included, if given.
+func (p *Software) GetSoftwareVitality(ctx *fiber.Ctx) error {
+	const errMsg = "can't get Software vitality"
+
+	software := models.Software{}
+
+	if err := p.db.First(&software, "id = ?", ctx.Params("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Software was not found")
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	stmt := p.db.Where("software_id = ?", software.ID)
+
+	for _, bound := range []struct{ param, cond string }{{"from", "date >= ?"}, {"to", "date <= ?"}} {
+		raw := ctx.Query(bound.param)
+		if raw == "" {
+			continue
+		}
+
+		date, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return common.Error(fiber.StatusUnprocessableEntity, errMsg, bound.param+" must be a date (YYYY-MM-DD)")
+		}
+
+		stmt = stmt.Where(bound.cond, date)
+	}
+
+	var points []models.SoftwareVitality
+
+	paginator, err := general.NewPaginatorWithConfig(ctx, &paginator.Config{Keys: []string{"Date", "ID"}})
+	if err != nil {
+		return common.Error(fiber.StatusUnprocessableEntity, errMsg, err.Error())
+	}
+
+	result, cursor, err := paginator.Paginate(stmt, &points)
+	if err != nil {
+		return common.Error(
+			fiber.StatusUnprocessableEntity,
+			errMsg,
+			"wrong cursor format in page[after] or page[before]",
+		)
+	}
+
+	if result.Error != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(fiber.Map{"data": &points, "links": general.NewPaginationLinks(ctx.Queries(), cursor)})
+}
+
+// PostSoftwareVitality stores the vitality data points of the software with
+// the given ID, replacing the ones on the same dates, and sets its vitality
+// to the score of the latest one.
+func (p *Software) PostSoftwareVitality(ctx *fiber.Ctx) error {
+	const errMsg = "can't create Software vitality"
+
+	request := new(common.SoftwareVitalityPost)
+
+	if err := common.ValidateRequestEntity(ctx, request, errMsg); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	// The last data point of a date wins, a single insert can't upsert the
+	// same row twice.
+	points := make([]models.SoftwareVitality, 0, len(request.Data))
+	dates := map[string]int{}
+
+	for _, point := range request.Data {
+		date, _ := time.Parse(time.DateOnly, point.Date) // Already validated
+
+		vitality := models.SoftwareVitality{Date: date, Score: *point.Score, Components: point.Components}
+
+		if idx, ok := dates[point.Date]; ok {
+			points[idx] = vitality
+
+			continue
+		}
+
+		dates[point.Date] = len(points)
+		points = append(points, vitality)
+	}
+
+	software := models.Software{}
+
+	if err := p.db.Transaction(func(tran *gorm.DB) error {
+		if err := tran.First(&software, "id = ?", ctx.Params("id")).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		for idx := range points {
+			points[idx].SoftwareID = software.ID
+		}
+
+		if err := tran.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "software_id"}, {Name: "date"}},
+			DoUpdates: clause.AssignmentColumns([]string{"score", "components", "updated_at"}),
+		}).Create(&points).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		latest := models.SoftwareVitality{}
+		if err := tran.Order("date DESC").First(&latest, "software_id = ?", software.ID).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if equalPtr(software.Vitality, &latest.Score) {
+			return nil
+		}
+
+		return tran.Model(&software).Update("vitality_score", latest.Score).Error //nolint:wrapcheck
+	}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.Error(fiber.StatusNotFound, errMsg, "Software was not found")
+		}
+
+		return common.InternalServerError(errMsg)
+	}
+
+	return ctx.JSON(fiber.Map{"data": &points})
+}
+
+// softwareSortRules returns the paginator rules ordering a software list as
+// in the `sort` query parameter, nil to keep the default order. `vitality`
+// orders by the latest vitality score, highest first, and the software
+// without any last.
+func softwareSortRules(ctx *fiber.Ctx) ([]paginator.Rule, error) {
+	sqlType := "INTEGER"
+
+	switch ctx.Query("sort") {
+	case "":
+		return nil, nil
+	case "vitality":
+		return []paginator.Rule{
+			{
+				Key:             "Vitality",
+				SQLRepr:         "software.vitality_score",
+				SQLType:         &sqlType,
+				Order:           paginator.DESC,
+				NULLReplacement: -1,
+			},
+			{Key: "ID", SQLRepr: "software.id", Order: paginator.ASC},
+		}, nil
+	default:
+		return nil, errInvalidSort
+	}
+}
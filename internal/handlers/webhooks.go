@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"errors"
+	"net/http"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/utils"
@@ -23,7 +24,7 @@ func NewWebhook[T models.Model](db *gorm.DB) *Webhook[T] {
 func (p *Webhook[T]) GetWebhook(ctx *fiber.Ctx) error {
 	webhook := models.Webhook{}
 
-	if err := p.db.First(&webhook, "id = ?", ctx.Params("id")).Error; err != nil {
+	if err := p.db.Preload("Headers").First(&webhook, "id = ?", ctx.Params("id")).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return common.Error(fiber.StatusNotFound, "can't get Webhook", "Webhook was not found")
 		}
@@ -45,7 +46,7 @@ func (p *Webhook[T]) GetResourceWebhooks(ctx *fiber.Ctx) error {
 
 	var resource T
 
-	stmt := p.db.Where(map[string]any{"entity_type": resource.TableName()})
+	stmt := p.db.Preload("Headers").Where(map[string]any{"entity_type": resource.TableName()})
 
 	paginator, err := general.NewPaginator(ctx)
 	if err != nil {
@@ -93,6 +94,7 @@ func (p *Webhook[T]) GetSingleResourceWebhooks(ctx *fiber.Ctx) error {
 	}
 
 	stmt := p.db.
+		Preload("Headers").
 		Where(map[string]any{"entity_type": resource.TableName()}).
 		Where("entity_id = ?", resource.UUID())
 
@@ -142,6 +144,14 @@ func (p *Webhook[T]) PostResourceWebhook(ctx *fiber.Ctx) error {
 		EntityType: resource.TableName(),
 	}
 
+	headers, err := buildWebhookHeaders(webhook.ID, webhookReq.Headers)
+	if err != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	webhook.Headers = headers
+	webhook.Batch = buildWebhookBatch(webhookReq.Batch)
+
 	if err := p.db.Create(&webhook).Error; err != nil {
 		return common.Error(fiber.StatusInternalServerError, errMsg, "db error")
 	}
@@ -182,6 +192,14 @@ func (p *Webhook[T]) PostSingleResourceWebhook(ctx *fiber.Ctx) error {
 		EntityType: resource.TableName(),
 	}
 
+	headers, err := buildWebhookHeaders(webhook.ID, webhookReq.Headers)
+	if err != nil {
+		return common.InternalServerError(errMsg)
+	}
+
+	webhook.Headers = headers
+	webhook.Batch = buildWebhookBatch(webhookReq.Batch)
+
 	if err := p.db.Create(&webhook).Error; err != nil {
 		return common.Error(fiber.StatusInternalServerError, errMsg, "db error")
 	}
@@ -201,7 +219,7 @@ func (p *Webhook[T]) PatchWebhook(ctx *fiber.Ctx) error {
 
 	webhook := models.Webhook{}
 
-	if err := p.db.First(&webhook, "id = ?", ctx.Params("id")).Error; err != nil {
+	if err := p.db.Preload("Headers").First(&webhook, "id = ?", ctx.Params("id")).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return common.Error(fiber.StatusNotFound, errMsg, "Webhook was not found")
 		}
@@ -215,7 +233,36 @@ func (p *Webhook[T]) PatchWebhook(ctx *fiber.Ctx) error {
 
 	webhook.URL = common.NormalizeURL(webhookReq.URL)
 
-	if err := p.db.Updates(&webhook).Error; err != nil {
+	if webhookReq.Batch != nil {
+		webhook.Batch = buildWebhookBatch(webhookReq.Batch)
+	}
+
+	if err := p.db.Transaction(func(tran *gorm.DB) error {
+		// Headers are replaced as a whole when present in the request, secret
+		// values can't be read back so there's nothing to merge them with.
+		if webhookReq.Headers != nil {
+			headers, err := buildWebhookHeaders(webhook.ID, webhookReq.Headers)
+			if err != nil {
+				return err
+			}
+
+			if err := tran.Where("webhook_id = ?", webhook.ID).Delete(&models.WebhookHeader{}).Error; err != nil {
+				return err
+			}
+
+			if len(headers) > 0 {
+				if err := tran.Create(headers).Error; err != nil {
+					return err
+				}
+			}
+
+			webhook.Headers = headers
+		}
+
+		// Select the fields explicitly, so a batch turned off is written
+		// as NULL instead of being skipped as a zero value.
+		return tran.Select("URL", "Batch", "UpdatedAt").Updates(&webhook).Error
+	}); err != nil {
 		return common.Error(fiber.StatusInternalServerError, errMsg, "db error")
 	}
 
@@ -224,9 +271,7 @@ func (p *Webhook[T]) PatchWebhook(ctx *fiber.Ctx) error {
 
 // DeleteWebhook deletes the webhook with the given ID.
 func (p *Webhook[T]) DeleteWebhook(ctx *fiber.Ctx) error {
-	var webhook models.Webhook
-
-	result := p.db.Delete(&webhook, "id = ?", ctx.Params("id"))
+	result := p.db.Select("Headers").Delete(&models.Webhook{ID: ctx.Params("id")})
 
 	if result.Error != nil {
 		return common.Error(fiber.StatusInternalServerError, "can't delete Webhook", "db error")
@@ -238,3 +283,42 @@ func (p *Webhook[T]) DeleteWebhook(ctx *fiber.Ctx) error {
 
 	return ctx.SendStatus(fiber.StatusNoContent)
 }
+
+// buildWebhookHeaders converts the requested custom headers to their models,
+// encrypting the values of the secret ones.
+func buildWebhookHeaders(webhookID string, inputs []common.WebhookHeader) ([]models.WebhookHeader, error) {
+	headers := make([]models.WebhookHeader, 0, len(inputs))
+
+	for _, input := range inputs {
+		value := input.Value
+
+		if input.Secret {
+			encrypted, err := common.Encrypt(common.WebhookHeadersKey(), input.Value)
+			if err != nil {
+				return nil, err //nolint:wrapcheck
+			}
+
+			value = encrypted
+		}
+
+		headers = append(headers, models.WebhookHeader{
+			ID:        utils.UUIDv4(),
+			Name:      http.CanonicalHeaderKey(input.Name),
+			Value:     value,
+			Secret:    input.Secret,
+			WebhookID: webhookID,
+		})
+	}
+
+	return headers, nil
+}
+
+// buildWebhookBatch converts the requested batch configuration to its model.
+// It returns nil, meaning one delivery per event, when batching is off.
+func buildWebhookBatch(input *common.WebhookBatch) *models.WebhookBatch {
+	if input == nil || input.Window == 0 {
+		return nil
+	}
+
+	return &models.WebhookBatch{Window: input.Window, MaxEvents: input.MaxEvents}
+}
@@ -0,0 +1,77 @@
+// Package metrics holds the Prometheus metrics of the API, other than the
+// HTTP ones collected by the fiberprometheus middleware.
+package metrics
+
+import (
+	"net/url"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "webhook"
+
+//nolint:gochecknoglobals // metrics are registered once, at init
+var (
+	// Deliveries counts the webhook deliveries by receiver host and HTTP
+	// status class ("2xx", "4xx", "5xx", ...), or "error" when no response
+	// was received, fe. on a timeout.
+	Deliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deliveries_total",
+		Help:      "Webhook deliveries by receiver host and HTTP status class.",
+	}, []string{"host", "status_class"})
+
+	// DeliveryDuration observes how long webhook deliveries take, by
+	// receiver host.
+	DeliveryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "delivery_duration_seconds",
+		Help:      "Duration of webhook deliveries by receiver host.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"host"})
+
+	// DeliveryRetries counts the webhook deliveries attempted again, after
+	// being stored by a replica stopping or left claimed by one that stopped
+	// abruptly, by receiver host.
+	DeliveryRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "delivery_retries_total",
+		Help:      "Webhook deliveries attempted again by receiver host.",
+	}, []string{"host"})
+
+	// DebouncerPending is the number of entities with an event held back
+	// by the debouncer.
+	DebouncerPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "debouncer_pending",
+		Help:      "Entities with a webhook event held back by the debouncer.",
+	})
+
+	// DebouncerCoalesced counts the events merged by the debouncer with an
+	// event already pending for the same entity.
+	DebouncerCoalesced = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "debouncer_coalesced_events_total",
+		Help:      "Webhook events coalesced with one already pending for the same entity.",
+	})
+
+	// EventsDropped counts the events that couldn't be handed to the
+	// webhook dispatcher because it was busy.
+	EventsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_dropped_total",
+		Help:      "Events dropped because the webhook dispatcher was busy.",
+	})
+)
+
+// Host returns the host of rawURL, used as a label so the cardinality stays
+// bounded by the number of receivers rather than of webhooks.
+func Host(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return "invalid"
+	}
+
+	return parsed.Host
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gofiber/fiber/v2/utils"
 	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/metrics"
 	"gorm.io/gorm"
 )
 
@@ -116,6 +117,8 @@ func sendNonBlock(event Event) {
 	select {
 	case EventChan <- event:
 	default:
+		metrics.EventsDropped.Inc()
+
 		log.Printf("can't send event %v to channel\n", event)
 	}
 }
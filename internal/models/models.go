@@ -37,12 +37,13 @@ type Catalog struct {
 	Name                string              `json:"name" gorm:"not null"`
 	AlternativeID       *string             `json:"alternativeId,omitempty" gorm:"uniqueIndex"`
 	Active              *bool               `json:"active" gorm:"default:true;not null"`
-	PublishersNamespace *string             `json:"publishersNamespace,omitempty"`
+	PublishersNamespace *string             `json:"publishersNamespace,omitempty" gorm:"uniqueIndex"`
 	Scopes              []string            `json:"scopes,omitempty" gorm:"serializer:json"`
 	Sources             []CatalogSource     `json:"sources" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 	Analysis            common.AnalysisData `json:"-" gorm:"type:jsonb"`
 	CreatedAt           time.Time           `json:"createdAt" gorm:"index"`
 	UpdatedAt           time.Time           `json:"updatedAt"`
+	DeletedAt           gorm.DeletedAt      `json:"deletedAt,omitzero" gorm:"index"`
 }
 
 type CatalogSource struct {
@@ -67,16 +68,91 @@ func (c Catalog) UUID() string {
 	return c.ID
 }
 
+// CatalogRun is a crawl of a Catalog: the crawler reports the URLs of the
+// software it saw and closing the run deactivates the active software of the
+// catalog it didn't see, unless they are more than MaxDeactivated percent.
+type CatalogRun struct {
+	ID             string     `json:"id" gorm:"primaryKey"`
+	CatalogID      *string    `json:"catalogId,omitempty" gorm:"index"`
+	Status         string     `json:"status" gorm:"not null"`
+	MaxDeactivated int        `json:"maxDeactivated" gorm:"not null"`
+	Seen           int        `json:"seen" gorm:"not null;default:0"`
+	Deactivated    int        `json:"deactivated" gorm:"not null;default:0"`
+	ClosedAt       *time.Time `json:"closedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"index"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+
+	// IDs of the software deactivated, or that would be in a dry run.
+	// Set only in the response to closing the run.
+	Software []string `json:"software,omitempty" gorm:"-"`
+}
+
+func (CatalogRun) TableName() string {
+	return "catalog_runs"
+}
+
+// CatalogDeletion is the cascading deletion of a Catalog: its software and
+// publishers go to the trash with it, keeping their URLs and code hosting,
+// and are restored with it. Their logs and webhooks, and the ones of the
+// Catalog, are deleted for good. It counts what was removed, once done, as
+// large catalogs are deleted in the background.
+type CatalogDeletion struct {
+	ID           string    `json:"id" gorm:"primaryKey"`
+	CatalogID    string    `json:"catalogId" gorm:"not null;index"`
+	Status       string    `json:"status" gorm:"not null"`
+	Software     int       `json:"software" gorm:"not null;default:0"`
+	SoftwareURLs int       `json:"softwareUrls" gorm:"not null;default:0"`
+	Publishers   int       `json:"publishers" gorm:"not null;default:0"`
+	CodeHosting  int       `json:"codeHosting" gorm:"not null;default:0"`
+	Logs         int       `json:"logs" gorm:"not null;default:0"`
+	Webhooks     int       `json:"webhooks" gorm:"not null;default:0"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"index"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+func (CatalogDeletion) TableName() string {
+	return "catalog_deletions"
+}
+
+// CatalogDeletionEntity is a software or publisher moved to the trash by a
+// CatalogDeletion, to restore it with the Catalog.
+type CatalogDeletionEntity struct {
+	CatalogDeletionID string `gorm:"primaryKey"`
+	EntityType        string `gorm:"primaryKey"`
+	EntityID          string `gorm:"primaryKey"`
+}
+
+func (CatalogDeletionEntity) TableName() string {
+	return "catalog_deletion_entities"
+}
+
+// CatalogRunURL is a URL of software seen by an open CatalogRun.
+type CatalogRunURL struct {
+	CatalogRunID string `gorm:"primaryKey"`
+	URL          string `gorm:"primaryKey"`
+}
+
+func (CatalogRunURL) TableName() string {
+	return "catalog_run_urls"
+}
+
+// Publisher is unique in its catalog by Description and AlternativeID. The
+// root catalog's CatalogID is NULL, so the indexes read it as empty.
 type Publisher struct {
-	ID            string        `json:"id" gorm:"primaryKey"`
-	CatalogID     *string       `json:"catalogId,omitempty" gorm:"index"`
-	Email         *string       `json:"email,omitempty"`
-	Description   string        `json:"description" gorm:"uniqueIndex;not null"`
-	CodeHosting   []CodeHosting `json:"codeHosting" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
-	Active        *bool         `json:"active" gorm:"default:true;not null"`
-	AlternativeID *string       `json:"alternativeId,omitempty" gorm:"uniqueIndex"`
-	CreatedAt     time.Time     `json:"createdAt" gorm:"index"`
-	UpdatedAt     time.Time     `json:"updatedAt"`
+	ID            string         `json:"id" gorm:"primaryKey"`
+	CatalogID     *string        `json:"catalogId,omitempty" gorm:"index;uniqueIndex:idx_publishers_description_catalog,priority:20,expression:COALESCE(catalog_id\\,'');uniqueIndex:idx_publishers_alternative_id_catalog,priority:20,expression:COALESCE(catalog_id\\,'')"` //nolint:lll
+	Email         *string        `json:"email,omitempty"`
+	Description   string         `json:"description" gorm:"not null;uniqueIndex:idx_publishers_description_catalog"`
+	CodeHosting   []CodeHosting  `json:"codeHosting" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Active        *bool          `json:"active" gorm:"default:true;not null"`
+	AlternativeID *string        `json:"alternativeId,omitempty" gorm:"uniqueIndex:idx_publishers_alternative_id_catalog"`
+	CreatedAt     time.Time      `json:"createdAt" gorm:"index"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	DeletedAt     gorm.DeletedAt `json:"deletedAt,omitzero" gorm:"index"`
+
+	// Set only in the results of a full-text search.
+	SearchRank float64 `json:"-" gorm:"->;-:migration"`
+	Snippet    *string `json:"snippet,omitempty" gorm:"column:search_snippet;->;-:migration"`
 }
 
 func (Publisher) TableName() string {
@@ -92,12 +168,16 @@ func (CodeHosting) TableName() string {
 }
 
 type CodeHosting struct {
-	ID          string    `json:"-" gorm:"primaryKey"`
-	URL         string    `json:"url" gorm:"not null;uniqueIndex"`
-	Group       *bool     `json:"group" gorm:"default:true;not null"`
-	PublisherID string    `json:"-" gorm:"index"`
-	CreatedAt   time.Time `json:"createdAt" gorm:"index"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          string `json:"-" gorm:"primaryKey"`
+	URL         string `json:"url" gorm:"not null;uniqueIndex:idx_publishers_code_hosting_url_catalog_id"`
+	Group       *bool  `json:"group" gorm:"default:true;not null"`
+	PublisherID string `json:"-" gorm:"index"`
+
+	// Catalog of the publisher, empty for the root one, as the URLs are
+	// unique in a catalog.
+	CatalogID string    `json:"-" gorm:"uniqueIndex:idx_publishers_code_hosting_url_catalog_id;not null;default:''"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 type Software struct {
@@ -109,15 +189,38 @@ type Software struct {
 	// with SoftwareURLs (belongs to and has many).
 	SoftwareURLID string `json:"-" gorm:"uniqueIndex;not null"`
 
+	// Publisher owning the software, from the code hosting URLs of the
+	// publishers matching its URL or aliases. It's computed, never written
+	// by the clients.
+	PublisherID *string `json:"publisherId,omitempty" gorm:"index"`
+
 	URL           SoftwareURL         `json:"url"`
 	Aliases       SoftwareURLSlice    `json:"aliases"`
 	PubliccodeYml string              `json:"publiccodeYml"`
 	Logs          []Log               `json:"-" gorm:"polymorphic:Entity;"`
 	Active        *bool               `json:"active" gorm:"default:true;not null"`
-	Vitality      *string             `json:"vitality"`
+	Vitality      *int                `json:"vitality" gorm:"column:vitality_score;index"`
 	Analysis      common.AnalysisData `json:"-" gorm:"type:jsonb"`
 	CreatedAt     time.Time           `json:"createdAt" gorm:"index"`
 	UpdatedAt     time.Time           `json:"updatedAt"`
+	DeletedAt     gorm.DeletedAt      `json:"deletedAt,omitzero" gorm:"index"`
+
+	// Fields extracted from PubliccodeYml on write, to filter on them.
+	Name              string                `json:"-" gorm:"index"`
+	License           string                `json:"-" gorm:"index"`
+	DevelopmentStatus string                `json:"-" gorm:"index"`
+	SoftwareType      string                `json:"-" gorm:"index"`
+	MaintenanceType   string                `json:"-" gorm:"index"`
+	Descriptions      []SoftwareDescription `json:"-"`
+	Terms             []SoftwareTerm        `json:"-"`
+
+	// Text of all the descriptions and features, for the full-text search.
+	SearchDescription string `json:"-"`
+	SearchFeatures    string `json:"-"`
+
+	// Set only in the results of a full-text search.
+	SearchRank float64 `json:"-" gorm:"->;-:migration"`
+	Snippet    *string `json:"snippet,omitempty" gorm:"column:search_snippet;->;-:migration"`
 }
 
 func (Software) TableName() string {
@@ -129,15 +232,73 @@ func (s Software) UUID() string {
 	return s.ID
 }
 
+// SoftwareVitality is a data point of the vitality of a Software: its score
+// on a day, 0-100, and the scores of its components (fe. codeActivity or
+// userCommunity). The Vitality of a Software is the score of its latest
+// one, computed, never written by the clients.
+type SoftwareVitality struct {
+	ID         uint           `json:"-" gorm:"primaryKey"`
+	SoftwareID string         `json:"-" gorm:"not null;uniqueIndex:idx_software_vitality_date"`
+	Date       time.Time      `json:"date" gorm:"not null;uniqueIndex:idx_software_vitality_date"`
+	Score      int            `json:"score" gorm:"not null"`
+	Components map[string]int `json:"components,omitempty" gorm:"serializer:json"`
+	CreatedAt  time.Time      `json:"-"`
+	UpdatedAt  time.Time      `json:"-"`
+}
+
+func (SoftwareVitality) TableName() string {
+	return "software_vitality"
+}
+
+func (v SoftwareVitality) MarshalJSON() ([]byte, error) {
+	// Alias drops the methods, so json.Marshal doesn't recurse in here.
+	type alias SoftwareVitality
+
+	return json.Marshal(struct {
+		alias
+
+		Date string `json:"date"`
+	}{alias(v), v.Date.Format(time.DateOnly)})
+}
+
+// SoftwareDescription is the description of a Software in a language, as
+// in its publiccode.yml.
+type SoftwareDescription struct {
+	ID               uint   `gorm:"primaryKey"`
+	SoftwareID       string `gorm:"not null;index"`
+	Language         string `gorm:"not null"`
+	LocalisedName    string
+	ShortDescription string
+	LongDescription  string
+}
+
+// SoftwareTerm is an item of a list in the publiccode.yml of a Software, fe.
+// a category or a platform. Path is the path of the list in the file.
+type SoftwareTerm struct {
+	ID         uint   `gorm:"primaryKey"`
+	SoftwareID string `gorm:"not null;index"`
+	Path       string `gorm:"not null;index:idx_software_terms_path_value"`
+	Value      string `gorm:"not null;index:idx_software_terms_path_value"`
+}
+
+// SoftwareURL is a URL of a Software, unique in its catalog: the same
+// repository can be listed by more catalogs. CatalogID copies the one of the
+// Software, empty for the root catalog so the unique index applies to it too.
+//
 //nolint:musttag // we are using a custom MarshalJSON method
 type SoftwareURL struct {
 	ID         string    `gorm:"primarykey"`
-	URL        string    `gorm:"uniqueIndex"`
+	URL        string    `gorm:"uniqueIndex:idx_software_urls_url_catalog_id"`
+	CatalogID  string    `gorm:"uniqueIndex:idx_software_urls_url_catalog_id;not null;default:''"`
 	SoftwareID string    `gorm:"not null;index"`
 	CreatedAt  time.Time `gorm:"index"`
 	UpdatedAt  time.Time
 }
 
+func (SoftwareURL) TableName() string {
+	return "software_urls"
+}
+
 func (su SoftwareURL) MarshalJSON() ([]byte, error) {
 	return fmt.Appendf(nil, `"%s"`, su.URL), nil
 }
@@ -176,17 +337,106 @@ func (slice *SoftwareURLSlice) UnmarshalJSON(data []byte) error {
 }
 
 type Webhook struct {
-	ID        string    `json:"id" gorm:"primaryKey"`
-	URL       string    `json:"url" gorm:"index:idx_webhook_url,unique"`
-	Secret    string    `json:"-"`
-	CreatedAt time.Time `json:"createdAt" gorm:"index"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID        string          `json:"id" gorm:"primaryKey"`
+	URL       string          `json:"url" gorm:"index:idx_webhook_url,unique"`
+	Secret    string          `json:"-"`
+	Headers   []WebhookHeader `json:"headers,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Batch     *WebhookBatch   `json:"batch,omitempty" gorm:"serializer:json"`
+	CreatedAt time.Time       `json:"createdAt" gorm:"index"`
+	UpdatedAt time.Time       `json:"updatedAt"`
 
 	// Entity this Webhook is for (fe. Publisher, Software, etc.)
 	EntityID   string `json:"-" gorm:"index:idx_webhook_url,unique"`
 	EntityType string `json:"-" gorm:"index:idx_webhook_url,unique"`
 }
 
+// WebhookBatch makes a Webhook collect events and deliver them together as
+// a JSON array, once Window milliseconds passed since the first one or as
+// soon as MaxEvents are collected (0 means no limit).
+type WebhookBatch struct {
+	Window    int `json:"window"`
+	MaxEvents int `json:"maxEvents,omitempty"`
+}
+
+// WebhookHeader is a custom header added to every delivery of a Webhook,
+// fe. the bearer token a gateway in front of the receiver requires.
+//
+// When Secret is true, Value is stored encrypted and never returned.
+type WebhookHeader struct {
+	ID        string    `json:"-" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null"`
+	Value     string    `json:"value,omitempty" gorm:"not null"`
+	Secret    bool      `json:"secret" gorm:"default:false;not null"`
+	WebhookID string    `json:"-" gorm:"not null;index"`
+	CreatedAt time.Time `json:"-" gorm:"index"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+func (WebhookHeader) TableName() string {
+	return "webhook_headers"
+}
+
+func (h WebhookHeader) MarshalJSON() ([]byte, error) {
+	// Alias drops the methods, so json.Marshal doesn't recurse in here.
+	type alias WebhookHeader
+
+	if h.Secret {
+		h.Value = ""
+	}
+
+	return json.Marshal(alias(h))
+}
+
+// Revision is a snapshot of a Catalog, Publisher or Software as returned by
+// the API, appended on every change. Revisions are never updated or deleted.
+//
+// Version is the sequence number of the revision for its entity, starting
+// from 1. Data is empty for the revision deleting the entity.
+type Revision struct {
+	ID         uint      `json:"-" gorm:"primaryKey"`
+	EntityType string    `json:"-" gorm:"not null;uniqueIndex:idx_revision_entity"`
+	EntityID   string    `json:"-" gorm:"not null;uniqueIndex:idx_revision_entity"`
+	Version    int       `json:"version" gorm:"not null;uniqueIndex:idx_revision_entity"`
+	Type       string    `json:"type" gorm:"not null"`
+	Data       string    `json:"-" gorm:"not null"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"index"`
+}
+
+func (Revision) TableName() string {
+	return "revisions"
+}
+
+// Redirect is the ID of a Publisher or Software merged into another one,
+// and the ID of the one it was merged into. The alternative ID of a merged
+// Publisher redirects too, and so do the URLs a Software doesn't have
+// anymore, with the EntityType of SoftwareURL, to the ID of that Software.
+type Redirect struct {
+	EntityType string    `gorm:"primaryKey"`
+	FromID     string    `gorm:"primaryKey"`
+	ToID       string    `gorm:"not null;index"`
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+func (Redirect) TableName() string {
+	return "redirects"
+}
+
+func (r Revision) MarshalJSON() ([]byte, error) {
+	// Alias drops the methods, so json.Marshal doesn't recurse in here.
+	type alias Revision
+
+	var data json.RawMessage
+	if r.Data != "" {
+		data = json.RawMessage(r.Data)
+	}
+
+	return json.Marshal(struct {
+		alias
+
+		Data json.RawMessage `json:"data"`
+	}{alias(r), data})
+}
+
 type Event struct {
 	ID         string `gorm:"primaryKey"`
 	Type       string
@@ -196,3 +446,41 @@ type Event struct {
 	UpdatedAt  time.Time
 	DeletedAt  gorm.DeletedAt `gorm:"index"`
 }
+
+// PendingEvent is an Event held back by the webhook debouncing, stored in
+// the database so the debounce window is shared by all the replicas.
+//
+// EntityKey identifies the entity the event is about, Version is bumped on
+// every change so replicas can claim or update the row without locks.
+type PendingEvent struct {
+	EntityKey  string    `gorm:"primaryKey"`
+	EventID    string    `gorm:"not null"`
+	Type       string    `gorm:"not null"`
+	EntityType string    `gorm:"not null"`
+	EntityID   string    `gorm:"not null"`
+	Deadline   time.Time `gorm:"not null"`
+	DueAt      time.Time `gorm:"not null;index"`
+	Version    int       `gorm:"not null"`
+}
+
+func (PendingEvent) TableName() string {
+	return "pending_events"
+}
+
+// PendingDelivery is a webhook delivery interrupted by a shutdown, stored
+// so the next instance delivers it again. The signature and the custom
+// headers aren't stored: they are computed again from the Webhook.
+//
+// ClaimedAt is set by the replica delivering it, which deletes it once the
+// delivery is done.
+type PendingDelivery struct {
+	ID        uint   `gorm:"primaryKey"`
+	WebhookID string `gorm:"not null"`
+	Payload   string `gorm:"not null"`
+	ClaimedAt *time.Time
+	CreatedAt time.Time
+}
+
+func (PendingDelivery) TableName() string {
+	return "pending_deliveries"
+}
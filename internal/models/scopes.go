@@ -5,3 +5,8 @@ import "gorm.io/gorm"
 func Active(db *gorm.DB) *gorm.DB {
 	return db.Where("active = ?", true)
 }
+
+// Deleted selects just the soft deleted rows, the ones in the trash.
+func Deleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Where("deleted_at IS NOT NULL")
+}
@@ -0,0 +1,240 @@
+// Package ownership links software to the publisher owning it, matching
+// its URL and aliases against the code hosting URLs of the publishers.
+//
+// A code hosting with Group set owns all the repositories under its URL
+// (fe. https://github.com/italia owns https://github.com/italia/design),
+// otherwise only the repository at its exact URL. Only the publishers in the
+// catalog of a software can own it.
+package ownership
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/italia/developers-italia-api/internal/models"
+	"gorm.io/gorm"
+)
+
+const batchSize = 100
+
+// Owner returns the ID of the publisher in the catalog with catalogID, the
+// root one if nil, owning the repositories at urls, nil if none. The urls
+// are tried in order, so the canonical URL of a software goes first.
+//
+// For a URL, a single repository code hosting at the same URL wins over a
+// group, and a group wins over the groups containing it.
+func Owner(tran *gorm.DB, catalogID *string, urls []string) (*string, error) {
+	for _, url := range urls {
+		var codeHosting []models.CodeHosting
+
+		// The code hosting of the publishers in the trash doesn't own anything
+		if err := tran.
+			Where("catalog_id = ?", codeHostingCatalogID(catalogID)).
+			Where("url IN ?", prefixes(url)).
+			Where("publisher_id IN (?)", tran.Model(&models.Publisher{}).Select("id")).
+			Find(&codeHosting).Error; err != nil {
+			return nil, fmt.Errorf("can't find code hosting for %s: %w", url, err)
+		}
+
+		var best *models.CodeHosting
+
+		for i := range codeHosting {
+			candidate := &codeHosting[i]
+			if !owns(*candidate, url) {
+				continue
+			}
+
+			if best == nil || better(*candidate, *best) {
+				best = candidate
+			}
+		}
+
+		if best != nil {
+			return &best.PublisherID, nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil
+}
+
+// Link sets the publisher owning software, from its URL and aliases, and
+// saves it. It doesn't touch the other columns nor run the hooks, so it
+// doesn't notify any webhook.
+func Link(tran *gorm.DB, software *models.Software) error {
+	urls := []string{software.URL.URL}
+
+	aliases := make([]string, 0, len(software.Aliases))
+	for _, alias := range software.Aliases {
+		aliases = append(aliases, alias.URL)
+	}
+
+	slices.Sort(aliases)
+
+	publisherID, err := Owner(tran, software.CatalogID, append(urls, aliases...))
+	if err != nil {
+		return err
+	}
+
+	software.PublisherID = publisherID
+
+	if err := tran.Model(software).UpdateColumn("publisher_id", publisherID).Error; err != nil {
+		return fmt.Errorf("can't link Software %s: %w", software.ID, err)
+	}
+
+	return nil
+}
+
+// Relink links again the software currently owned by publisher and the
+// software in its catalog its code hosting owns, after the publisher
+// changed or was deleted (with no code hosting).
+func Relink(tran *gorm.DB, publisher models.Publisher) error {
+	var ids []string
+
+	if err := tran.Model(&models.Software{}).
+		Where("publisher_id = ?", publisher.ID).
+		Pluck("id", &ids).Error; err != nil {
+		return fmt.Errorf("can't find Software of Publisher %s: %w", publisher.ID, err)
+	}
+
+	for _, codeHosting := range publisher.CodeHosting {
+		urls := tran.Where("url = ?", codeHosting.URL)
+
+		if codeHosting.Group == nil || *codeHosting.Group {
+			urls = urls.Or(`url LIKE ? ESCAPE '\'`, escapeLike(codeHosting.URL)+"/%")
+		}
+
+		var owned []string
+		if err := tran.Model(&models.SoftwareURL{}).
+			Where("catalog_id = ?", codeHostingCatalogID(publisher.CatalogID)).
+			Where(urls).
+			Distinct().
+			Pluck("software_id", &owned).Error; err != nil {
+			return fmt.Errorf("can't find Software under %s: %w", codeHosting.URL, err)
+		}
+
+		ids = append(ids, owned...)
+	}
+
+	slices.Sort(ids)
+
+	for batch := range slices.Chunk(slices.Compact(ids), batchSize) {
+		var software []models.Software
+
+		if err := tran.Preload("Aliases").Where("id IN ?", batch).Find(&software).Error; err != nil {
+			return fmt.Errorf("can't load Software: %w", err)
+		}
+
+		if err := linkAll(tran, software); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LinkAll links all the software, fe. the software existing before the
+// links were introduced.
+func LinkAll(gormdb *gorm.DB) error {
+	var software []models.Software
+
+	result := gormdb.Preload("Aliases").FindInBatches(&software, batchSize, func(tran *gorm.DB, _ int) error {
+		return linkAll(tran, software)
+	})
+
+	if result.Error != nil {
+		return fmt.Errorf("can't link Software: %w", result.Error)
+	}
+
+	return nil
+}
+
+// linkAll links software loaded with all their SoftwareURLs in Aliases,
+// the canonical URL included.
+func linkAll(tran *gorm.DB, software []models.Software) error {
+	for i := range software {
+		swr := &software[i]
+
+		aliases := make([]models.SoftwareURL, 0, len(swr.Aliases))
+
+		for _, alias := range swr.Aliases {
+			if alias.ID == swr.SoftwareURLID {
+				swr.URL = alias
+			} else {
+				aliases = append(aliases, alias)
+			}
+		}
+
+		swr.Aliases = aliases
+
+		if err := Link(tran, swr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// codeHostingCatalogID returns the CatalogID of the code hosting and the
+// software URLs in the catalog with catalogID, empty for the root one.
+func codeHostingCatalogID(catalogID *string) string {
+	if catalogID == nil {
+		return ""
+	}
+
+	return *catalogID
+}
+
+// owns reports whether codeHosting owns the repository at url.
+func owns(codeHosting models.CodeHosting, url string) bool {
+	if codeHosting.URL == url {
+		return true
+	}
+
+	group := codeHosting.Group == nil || *codeHosting.Group
+
+	return group && strings.HasPrefix(url, codeHosting.URL+"/")
+}
+
+// better reports whether a is a more specific owner than b, both owning
+// the same repository.
+func better(a models.CodeHosting, b models.CodeHosting) bool {
+	if len(a.URL) != len(b.URL) {
+		return len(a.URL) > len(b.URL)
+	}
+
+	aGroup := a.Group == nil || *a.Group
+	bGroup := b.Group == nil || *b.Group
+
+	if aGroup != bGroup {
+		return !aGroup
+	}
+
+	// Stable choice between equivalent ones
+	return a.PublisherID < b.PublisherID
+}
+
+// prefixes returns url and the URLs of its parent paths, the ones a code
+// hosting owning url might have.
+func prefixes(url string) []string {
+	result := []string{url}
+
+	schemeEnd := strings.Index(url, "://")
+
+	for {
+		i := strings.LastIndex(url, "/")
+		if i <= schemeEnd+len("://")-1 {
+			break
+		}
+
+		url = url[:i]
+		result = append(result, url)
+	}
+
+	return result
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,53 @@
+package ownership
+
+import (
+	"testing"
+
+	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixes(t *testing.T) {
+	assert.Equal(t, []string{
+		"https://github.com/italia/design",
+		"https://github.com/italia",
+		"https://github.com",
+	}, prefixes("https://github.com/italia/design"))
+
+	assert.Equal(t, []string{"https://github.com"}, prefixes("https://github.com"))
+	assert.Equal(t, []string{"github.com/italia", "github.com"}, prefixes("github.com/italia"))
+}
+
+func TestOwns(t *testing.T) {
+	group, single := true, false
+
+	italia := models.CodeHosting{URL: "https://github.com/italia", Group: &group}
+	design := models.CodeHosting{URL: "https://github.com/italia/design", Group: &single}
+
+	assert.True(t, owns(italia, "https://github.com/italia/design"))
+	assert.True(t, owns(italia, "https://github.com/italia"))
+	assert.False(t, owns(italia, "https://github.com/italia-other/design"))
+
+	assert.True(t, owns(design, "https://github.com/italia/design"))
+	assert.False(t, owns(design, "https://github.com/italia/design/sub"))
+
+	// Group defaults to true
+	assert.True(t, owns(models.CodeHosting{URL: "https://github.com/italia"}, "https://github.com/italia/design"))
+}
+
+func TestBetter(t *testing.T) {
+	group, single := true, false
+
+	italia := models.CodeHosting{URL: "https://github.com/italia", Group: &group, PublisherID: "b"}
+	designGroup := models.CodeHosting{URL: "https://github.com/italia/design", Group: &group, PublisherID: "c"}
+	design := models.CodeHosting{URL: "https://github.com/italia/design", Group: &single, PublisherID: "d"}
+
+	assert.True(t, better(designGroup, italia))
+	assert.False(t, better(italia, designGroup))
+	assert.True(t, better(design, designGroup))
+	assert.False(t, better(designGroup, design))
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `https://example.org/my\_org/100\%`, escapeLike("https://example.org/my_org/100%"))
+}
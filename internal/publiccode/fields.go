@@ -0,0 +1,141 @@
+package publiccode
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/italia/developers-italia-api/internal/models"
+	"gorm.io/gorm"
+)
+
+const extractBatchSize = 100
+
+// Paths of the publiccode.yml lists stored as SoftwareTerms.
+const (
+	TermCategories = "categories"
+	TermPlatforms  = "platforms"
+	TermCountries  = "intendedAudience.countries"
+	TermScope      = "intendedAudience.scope"
+)
+
+// columns are the columns of Software extracted from its publiccode.yml.
+//
+//nolint:gochecknoglobals // read-only
+var columns = []string{
+	"Name", "License", "DevelopmentStatus", "SoftwareType", "MaintenanceType", "SearchDescription", "SearchFeatures",
+}
+
+// SetFields sets the fields of software extracted from its
+// publiccode.yml, clearing them if publicCode is nil.
+func SetFields(software *models.Software, publicCode *PublicCode) {
+	if publicCode == nil {
+		publicCode = &PublicCode{}
+	}
+
+	software.Name = publicCode.Name
+	software.License = publicCode.Legal.License
+	software.DevelopmentStatus = publicCode.DevelopmentStatus
+	software.SoftwareType = publicCode.SoftwareType
+	software.MaintenanceType = publicCode.Maintenance.Type
+
+	texts := []string{}
+	features := []string{}
+
+	software.Descriptions = make([]models.SoftwareDescription, 0, len(publicCode.Description))
+	for _, language := range slices.Sorted(maps.Keys(publicCode.Description)) {
+		description := publicCode.Description[language]
+
+		software.Descriptions = append(software.Descriptions, models.SoftwareDescription{
+			SoftwareID:       software.ID,
+			Language:         language,
+			LocalisedName:    description.LocalisedName,
+			ShortDescription: description.ShortDescription,
+			LongDescription:  description.LongDescription,
+		})
+
+		texts = append(texts, description.LocalisedName, description.ShortDescription, description.LongDescription)
+		features = append(features, description.Features...)
+	}
+
+	software.SearchDescription = strings.Join(texts, "\n")
+	software.SearchFeatures = strings.Join(features, "\n")
+
+	software.Terms = []models.SoftwareTerm{}
+	for path, values := range map[string][]string{
+		TermCategories: publicCode.Categories,
+		TermPlatforms:  publicCode.Platforms,
+		TermCountries:  publicCode.IntendedAudience.Countries,
+		TermScope:      publicCode.IntendedAudience.Scope,
+	} {
+		for _, value := range values {
+			software.Terms = append(software.Terms, models.SoftwareTerm{
+				SoftwareID: software.ID,
+				Path:       path,
+				Value:      value,
+			})
+		}
+	}
+}
+
+// SaveFields writes the fields extracted from the publiccode.yml
+// of an existing software, replacing the previous ones.
+func SaveFields(tran *gorm.DB, software *models.Software) error {
+	if err := tran.Unscoped().Model(software).Select(columns).Updates(software).Error; err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if err := tran.Where("software_id = ?", software.ID).Delete(&models.SoftwareDescription{}).Error; err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if err := tran.Where("software_id = ?", software.ID).Delete(&models.SoftwareTerm{}).Error; err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if len(software.Descriptions) > 0 {
+		if err := tran.Create(&software.Descriptions).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	if len(software.Terms) > 0 {
+		if err := tran.Create(&software.Terms).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
+
+// ExtractAll sets the fields of all the software, in the trash too, from the
+// publiccode.yml they have stored. Invalid files are extracted as far as
+// they can be decoded, like with the validation off.
+//
+// It doesn't touch updatedAt and doesn't notify the webhooks, the software
+// itself doesn't change.
+func ExtractAll(database *gorm.DB) error {
+	var software []models.Software
+
+	save := database.Session(&gorm.Session{SkipHooks: true})
+
+	result := database.Unscoped().
+		Select("id", "publiccode_yml").
+		FindInBatches(&software, extractBatchSize, func(_ *gorm.DB, _ int) error {
+			return save.Transaction(func(tran *gorm.DB) error {
+				for i := range software {
+					publicCode, _, _ := Parse([]byte(software[i].PubliccodeYml))
+
+					SetFields(&software[i], publicCode)
+
+					if err := SaveFields(tran, &software[i]); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+		})
+
+	return result.Error //nolint:wrapcheck
+}
@@ -0,0 +1,64 @@
+package publiccode
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestExtractAll(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open("file:"+filepath.Join(t.TempDir(), "extract.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, database.AutoMigrate(
+		&models.SoftwareURL{}, &models.Software{}, &models.SoftwareDescription{}, &models.SoftwareTerm{},
+	))
+
+	updatedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, row := range []struct {
+		id            string
+		publiccodeYml string
+		deletedAt     *time.Time
+	}{
+		{"valid", readValid(t), nil},
+		{"trashed", readValid(t), &updatedAt},
+		{"garbage", "-", nil},
+	} {
+		require.NoError(t, database.Exec(
+			"INSERT INTO software (id, software_url_id, publiccode_yml, created_at, updated_at, deleted_at) "+
+				"VALUES (?, ?, ?, ?, ?, ?)",
+			row.id, row.id+"-url", row.publiccodeYml, updatedAt, updatedAt, row.deletedAt,
+		).Error)
+	}
+
+	require.NoError(t, ExtractAll(database))
+
+	var software []models.Software
+
+	require.NoError(t, database.Unscoped().Preload("Descriptions").Preload("Terms").Order("id").Find(&software).Error)
+	require.Len(t, software, 3)
+
+	garbage, trashed, valid := software[0], software[1], software[2]
+
+	assert.Empty(t, garbage.Name)
+	assert.Empty(t, garbage.Descriptions)
+	assert.Empty(t, garbage.Terms)
+
+	for _, extracted := range []models.Software{trashed, valid} {
+		assert.Equal(t, "Medusa", extracted.Name, extracted.ID)
+		assert.Equal(t, "AGPL-3.0-or-later", extracted.License, extracted.ID)
+		assert.Equal(t, "stable", extracted.DevelopmentStatus, extracted.ID)
+		assert.Equal(t, "community", extracted.MaintenanceType, extracted.ID)
+		assert.Contains(t, extracted.SearchFeatures, "Just one feature", extracted.ID)
+		assert.Len(t, extracted.Descriptions, 1, extracted.ID)
+		assert.Len(t, extracted.Terms, 4, extracted.ID)
+		assert.True(t, updatedAt.Equal(extracted.UpdatedAt), extracted.ID)
+	}
+}
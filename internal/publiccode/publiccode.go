@@ -0,0 +1,133 @@
+// Package publiccode parses and validates publiccode.yml files, as defined
+// by the standard at https://github.com/publiccodeyml/publiccode.yml.
+//
+// The validation is the one of publiccode-parser-go, the reference parser of
+// the standard, without the checks on external resources (fe. the existence
+// of the logo). Its problems are reported per key in the same shape as the
+// validation errors of the request bodies.
+package publiccode
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/italia/developers-italia-api/internal/common"
+	parser "github.com/italia/publiccode-parser-go/v5"
+	"gopkg.in/yaml.v3"
+)
+
+const maxProvidedValue = 255
+
+// PublicCode holds the fields of a publiccode.yml used by the API.
+type PublicCode struct {
+	PubliccodeYmlVersion string                 `yaml:"publiccodeYmlVersion"`
+	Name                 string                 `yaml:"name"`
+	URL                  string                 `yaml:"url"`
+	LandingURL           string                 `yaml:"landingURL"`
+	SoftwareVersion      string                 `yaml:"softwareVersion"`
+	ReleaseDate          string                 `yaml:"releaseDate"`
+	Platforms            []string               `yaml:"platforms"`
+	Categories           []string               `yaml:"categories"`
+	DevelopmentStatus    string                 `yaml:"developmentStatus"`
+	SoftwareType         string                 `yaml:"softwareType"`
+	IntendedAudience     IntendedAudience       `yaml:"intendedAudience"`
+	Description          map[string]Description `yaml:"description"`
+	Legal                Legal                  `yaml:"legal"`
+	Maintenance          Maintenance            `yaml:"maintenance"`
+	Localisation         Localisation           `yaml:"localisation"`
+}
+
+type IntendedAudience struct {
+	Countries []string `yaml:"countries"`
+	Scope     []string `yaml:"scope"`
+}
+
+type Description struct {
+	LocalisedName    string   `yaml:"localisedName"`
+	ShortDescription string   `yaml:"shortDescription"`
+	LongDescription  string   `yaml:"longDescription"`
+	Features         []string `yaml:"features"`
+}
+
+type Legal struct {
+	License            string `yaml:"license"`
+	MainCopyrightOwner string `yaml:"mainCopyrightOwner"`
+	RepoOwner          string `yaml:"repoOwner"`
+}
+
+type Maintenance struct {
+	Type string `yaml:"type"`
+}
+
+type Localisation struct {
+	LocalisationReady  bool     `yaml:"localisationReady"`
+	AvailableLanguages []string `yaml:"availableLanguages"`
+}
+
+// Parse parses and validates a publiccode.yml.
+//
+// errs are the problems that make the file invalid, warnings the ones that
+// don't, like a version that isn't the latest. The Field of each problem is
+// the path of its key in the file, fe. "description.en.features", and its
+// Value the description of the problem, with the "publiccode" Rule.
+//
+// The returned PublicCode is nil if data isn't a YAML mapping, otherwise it
+// holds what could be decoded even if there are errors.
+func Parse(data []byte) (*PublicCode, []common.ValidationError, []common.ValidationError) {
+	errs, warnings := validate(data)
+
+	var root map[string]any
+
+	if err := yaml.Unmarshal(data, &root); err != nil || root == nil {
+		return nil, errs, warnings
+	}
+
+	var publicCode PublicCode
+
+	// Type mismatches are reported by the validation already
+	_ = yaml.Unmarshal(data, &publicCode)
+
+	return &publicCode, errs, warnings
+}
+
+// validate returns the problems of data found by publiccode-parser-go.
+func validate(data []byte) ([]common.ValidationError, []common.ValidationError) {
+	publiccodeParser, err := parser.NewParser(parser.ParserConfig{DisableExternalChecks: true})
+	if err != nil {
+		return []common.ValidationError{problem("", err.Error())}, nil
+	}
+
+	_, err = publiccodeParser.ParseStream(bytes.NewReader(data))
+	if err == nil {
+		return nil, nil
+	}
+
+	var results parser.ValidationResults
+	if !errors.As(err, &results) {
+		return []common.ValidationError{problem("", err.Error())}, nil
+	}
+
+	var errs, warnings []common.ValidationError
+
+	for _, result := range results {
+		switch result := result.(type) {
+		case parser.ValidationWarning:
+			warnings = append(warnings, problem(result.Key, result.Description))
+		case parser.ValidationError:
+			errs = append(errs, problem(result.Key, result.Description))
+		default:
+			errs = append(errs, problem("", result.Error()))
+		}
+	}
+
+	return errs, warnings
+}
+
+func problem(key, description string) common.ValidationError {
+	runes := []rune(description)
+	if len(runes) > maxProvidedValue {
+		description = string(runes[:maxProvidedValue])
+	}
+
+	return common.ValidationError{Field: key, Rule: "publiccode", Value: description}
+}
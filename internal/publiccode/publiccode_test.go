@@ -0,0 +1,164 @@
+package publiccode
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readValid(t *testing.T) string {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/valid.yml")
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+func TestParseValid(t *testing.T) {
+	publicCode, errs, warnings := Parse([]byte(readValid(t)))
+
+	assert.Empty(t, errs)
+	assert.Empty(t, warnings)
+
+	require.NotNil(t, publicCode)
+	assert.Equal(t, "Medusa", publicCode.Name)
+	assert.Equal(t, "2024-05-01", publicCode.ReleaseDate)
+	assert.Equal(t, []string{"web"}, publicCode.Platforms)
+	assert.Equal(t, "AGPL-3.0-or-later", publicCode.Legal.License)
+	assert.Equal(t, "Just one feature", publicCode.Description["en"].Features[0])
+	assert.True(t, publicCode.Localisation.LocalisationReady)
+}
+
+func TestParseNotYAML(t *testing.T) {
+	for _, data := range []string{"-", "just a string", "{ unterminated", ""} {
+		publicCode, errs, _ := Parse([]byte(data))
+
+		assert.Nil(t, publicCode, data)
+		require.Len(t, errs, 1, data)
+		assert.Equal(t, "publiccode", errs[0].Rule, data)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		replace  [2]string
+		expected []common.ValidationError
+	}{
+		{
+			"missing name",
+			[2]string{"name: Medusa\n", ""},
+			[]common.ValidationError{{Field: "name", Rule: "publiccode", Value: "name is a required field"}},
+		},
+		{
+			"unsupported version",
+			[2]string{`publiccodeYmlVersion: "0"`, `publiccodeYmlVersion: "9"`},
+			[]common.ValidationError{{
+				Field: "publiccodeYmlVersion",
+				Rule:  "publiccode",
+				Value: "unsupported version: '9'. Supported versions: 0, 0.2, 0.2.0, 0.2.1, 0.2.2, 0.3, 0.3.0, 0.4, 0.4.0, 0.5.0, 0.5",
+			}},
+		},
+		{
+			"invalid url",
+			[2]string{`url: "https://github.com/italia/medusa.git"`, `url: "not a url"`},
+			[]common.ValidationError{{Field: "url", Rule: "publiccode", Value: "url must be a valid URL"}},
+		},
+		{
+			"invalid release date",
+			[2]string{`releaseDate: "2024-05-01"`, `releaseDate: "yesterday"`},
+			[]common.ValidationError{{
+				Field: "releaseDate", Rule: "publiccode", Value: "releaseDate must be a date with format 'YYYY-MM-DD'",
+			}},
+		},
+		{
+			"invalid development status",
+			[2]string{"developmentStatus: stable", "developmentStatus: done"},
+			[]common.ValidationError{{
+				Field: "developmentStatus",
+				Rule:  "publiccode",
+				Value: `developmentStatus must be one of the following: "concept", "development", "beta", "stable" or "obsolete"`,
+			}},
+		},
+		{
+			"invalid license",
+			[2]string{"license: AGPL-3.0-or-later", "license: Not-A-License"},
+			[]common.ValidationError{{
+				Field: "legal.license", Rule: "publiccode", Value: "license must be a valid license (see https://spdx.org/licenses)",
+			}},
+		},
+		{
+			"short description too long",
+			[2]string{
+				"shortDescription: A rather short description which is probably useless",
+				"shortDescription: " + strings.Repeat("a", 151),
+			},
+			[]common.ValidationError{{
+				Field: "description.en.shortDescription",
+				Rule:  "publiccode",
+				Value: "shortDescription must be a maximum of 150 characters in length",
+			}},
+		},
+		{
+			"missing features",
+			[2]string{"    features:\n      - Just one feature\n", ""},
+			[]common.ValidationError{{
+				Field: "description.en.features", Rule: "publiccode", Value: "features must contain more than 0 items",
+			}},
+		},
+		{
+			"missing contacts",
+			[2]string{"  contacts:\n    - name: Francesco Rossi\n", ""},
+			[]common.ValidationError{{
+				Field: "maintenance.contacts", Rule: "publiccode", Value: `contacts is a required field when "type" is "community"`,
+			}},
+		},
+		{
+			"unknown key",
+			[2]string{"legal:\n", "notAKey: true\nlegal:\n"},
+			[]common.ValidationError{{Field: "notAKey", Rule: "publiccode", Value: `unknown field "notAKey"`}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := readValid(t)
+			require.Contains(t, data, test.replace[0])
+
+			_, errs, _ := Parse([]byte(strings.Replace(data, test.replace[0], test.replace[1], 1)))
+
+			assert.Equal(t, test.expected, errs)
+		})
+	}
+}
+
+func TestParseWarnings(t *testing.T) {
+	data := strings.Replace(readValid(t), `publiccodeYmlVersion: "0"`, `publiccodeYmlVersion: "0.2"`, 1)
+
+	publicCode, errs, warnings := Parse([]byte(data))
+
+	assert.Empty(t, errs)
+	assert.Equal(t, []common.ValidationError{{
+		Field: "publiccodeYmlVersion",
+		Rule:  "publiccode",
+		Value: "v0.2 is not the latest version, use '0'. Parsing this file as v0.5.",
+	}}, warnings)
+	require.NotNil(t, publicCode)
+	assert.Equal(t, "Medusa", publicCode.Name)
+}
+
+func TestParseInvalidKeepsFields(t *testing.T) {
+	data := strings.Replace(readValid(t), "developmentStatus: stable", "developmentStatus: done", 1)
+
+	publicCode, errs, _ := Parse([]byte(data))
+
+	assert.NotEmpty(t, errs)
+	require.NotNil(t, publicCode)
+	assert.Equal(t, "done", publicCode.DevelopmentStatus)
+	assert.Equal(t, "AGPL-3.0-or-later", publicCode.Legal.License)
+}
@@ -0,0 +1,412 @@
+// Package search implements the full-text search on software and publishers.
+//
+// On PostgreSQL the documents are tsvector generated columns, in both the
+// Italian and the English configuration, with a GIN index. On SQLite they are
+// FTS5 tables kept in sync by triggers, if go-sqlite3 was built with FTS5
+// (-tags sqlite_fts5), otherwise the search falls back to LIKE.
+package search
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+	"gorm.io/gorm"
+)
+
+const (
+	// Delimiters of the matches in the raw snippets, replaced by <mark> once
+	// the snippet is HTML escaped.
+	startSel = "\x02"
+	stopSel  = "\x03"
+
+	// Runes of context around the first match in the snippets built by the
+	// LIKE fallback.
+	snippetBefore = 60
+	snippetAfter  = 140
+)
+
+// column is a column of an index, with its weight in the ranking. Weight is
+// the PostgreSQL weight label, Boost the FTS5 bm25 weight.
+type column struct {
+	Name   string
+	Weight string
+	Boost  float64
+}
+
+// index is the full-text index of a table.
+type index struct {
+	Table   string
+	Columns []column
+}
+
+//nolint:gochecknoglobals // read-only
+var (
+	softwareIndex = index{
+		Table: "software",
+		Columns: []column{
+			{Name: "name", Weight: "A", Boost: 10},
+			{Name: "search_description", Weight: "B", Boost: 5},
+			{Name: "search_features", Weight: "C", Boost: 2},
+		},
+	}
+	publishersIndex = index{
+		Table: "publishers",
+		Columns: []column{
+			{Name: "description", Weight: "A", Boost: 10},
+		},
+	}
+
+	// configurations are the PostgreSQL text search configurations the
+	// documents are indexed with.
+	configurations = []string{"italian", "english"}
+
+	termSeparators = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+	// fts5 is whether SQLite has FTS5, as found by Setup.
+	fts5 atomic.Bool
+)
+
+// Setup creates the full-text indexes, if they don't exist already.
+func Setup(db *gorm.DB) error {
+	for _, idx := range []index{softwareIndex, publishersIndex} {
+		var err error
+
+		switch db.Dialector.Name() {
+		case "postgres":
+			err = idx.setupPostgres(db)
+		case "sqlite":
+			err = idx.setupSQLite(db)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", idx.Table, err)
+		}
+	}
+
+	return nil
+}
+
+// Software returns the software matching query, to use as the table of a
+// query on software, with their rank and snippet.
+func Software(db *gorm.DB, query string) *gorm.DB {
+	return softwareIndex.search(db, query)
+}
+
+// Publishers returns the publishers matching query, to use as the table of
+// a query on publishers, with their rank and snippet.
+func Publishers(db *gorm.DB, query string) *gorm.DB {
+	return publishersIndex.search(db, query)
+}
+
+// PaginationRules are the paginator rules ordering the results of a search
+// on table by relevance.
+func PaginationRules(table string) []paginator.Rule {
+	return []paginator.Rule{
+		{Key: "SearchRank", SQLRepr: table + ".search_rank", Order: paginator.DESC},
+		{Key: "ID", SQLRepr: table + ".id", Order: paginator.ASC},
+	}
+}
+
+// Snippet returns the HTML of a raw snippet returned by a search, with the
+// matches of query in <mark> elements.
+func Snippet(raw *string, query string) *string {
+	if raw == nil {
+		return nil
+	}
+
+	var snippet string
+
+	if strings.Contains(*raw, startSel) {
+		snippet = html.EscapeString(*raw)
+		snippet = strings.NewReplacer(startSel, "<mark>", stopSel, "</mark>").Replace(snippet)
+	} else {
+		snippet = highlight(*raw, Terms(query))
+	}
+
+	return &snippet
+}
+
+// Terms returns the words in query, ignoring punctuation and operators.
+func Terms(query string) []string {
+	return strings.Fields(termSeparators.ReplaceAllString(query, " "))
+}
+
+func (idx index) table(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Table(idx.Table)
+}
+
+func (idx index) search(db *gorm.DB, query string) *gorm.DB {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return idx.table(db).Select(idx.Table + ".*, 0 AS search_rank, NULL AS search_snippet").Where("1 = 0")
+	}
+
+	switch db.Dialector.Name() {
+	case "postgres":
+		return idx.searchPostgres(db, query)
+	case "sqlite":
+		if fts5.Load() {
+			return idx.searchFTS5(db, terms)
+		}
+	}
+
+	return idx.searchLike(db, terms)
+}
+
+func (idx index) setupPostgres(db *gorm.DB) error {
+	vectors := make([]string, 0, len(idx.Columns)*len(configurations))
+
+	for _, col := range idx.Columns {
+		for _, config := range configurations {
+			vectors = append(vectors, fmt.Sprintf(
+				"setweight(to_tsvector('%s', coalesce(%s, '')), '%s')", config, col.Name, col.Weight,
+			))
+		}
+	}
+
+	statements := []string{
+		fmt.Sprintf(
+			"ALTER TABLE %s ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (%s) STORED",
+			idx.Table, strings.Join(vectors, " || "),
+		),
+		fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS idx_%s_search_vector ON %s USING GIN (search_vector)", idx.Table, idx.Table,
+		),
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
+
+func (idx index) searchPostgres(db *gorm.DB, query string) *gorm.DB {
+	const tsquery = "(websearch_to_tsquery('italian', ?) || websearch_to_tsquery('english', ?))"
+
+	// Highlight with the configuration that matched, the text is parsed
+	// and stemmed again.
+	config := "(CASE WHEN search_vector @@ websearch_to_tsquery('italian', ?) THEN 'italian' ELSE 'english' END)::regconfig"
+	options := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=35, MinWords=15", startSel, stopSel)
+
+	return idx.table(db).
+		Select(
+			fmt.Sprintf(
+				"%s.*, ts_rank(search_vector, %s)::float8 AS search_rank, ts_headline(%s, %s, %s, ?) AS search_snippet",
+				idx.Table, tsquery, config, idx.text(), tsquery,
+			),
+			query, query, query, query, query, options,
+		).
+		Where("search_vector @@ "+tsquery, query, query)
+}
+
+func (idx index) ftsTable() string {
+	return idx.Table + "_fts"
+}
+
+func (idx index) setupSQLite(db *gorm.DB) error {
+	names := make([]string, 0, len(idx.Columns))
+	newValues := make([]string, 0, len(idx.Columns))
+	oldValues := make([]string, 0, len(idx.Columns))
+
+	for _, col := range idx.Columns {
+		names = append(names, col.Name)
+		newValues = append(newValues, "new."+col.Name)
+		oldValues = append(oldValues, "old."+col.Name)
+	}
+
+	fts := idx.ftsTable()
+	columns := strings.Join(names, ", ")
+	insert := fmt.Sprintf(
+		"INSERT INTO %s(rowid, %s) VALUES (new.rowid, %s);", fts, columns, strings.Join(newValues, ", "),
+	)
+	remove := fmt.Sprintf(
+		"INSERT INTO %s(%s, rowid, %s) VALUES ('delete', old.rowid, %s);", fts, fts, columns, strings.Join(oldValues, ", "),
+	)
+
+	// The index is new, or idx.Table was rebuilt by a migration, dropping
+	// its triggers, and its rowids might have changed with it
+	var triggers int64
+
+	err := db.Raw(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = ?", fts+"_insert",
+	).Scan(&triggers).Error
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	// External content table, the text is read from idx.Table.
+	err = db.Exec(fmt.Sprintf(
+		"CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(%s, content='%s', tokenize='porter unicode61 remove_diacritics 2')",
+		fts, columns, idx.Table,
+	)).Error
+	if err != nil {
+		if !strings.Contains(err.Error(), "no such module: fts5") {
+			return err //nolint:wrapcheck
+		}
+
+		log.Printf("SQLite was built without FTS5, full-text search on %s falls back to LIKE", idx.Table)
+
+		// The triggers of a build with FTS5 would fail every write
+		for _, trigger := range []string{"insert", "delete", "update"} {
+			if err := db.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s_%s", fts, trigger)).Error; err != nil {
+				return err //nolint:wrapcheck
+			}
+		}
+
+		fts5.Store(false)
+
+		return nil
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s_insert AFTER INSERT ON %s BEGIN %s END", fts, idx.Table, insert),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s_delete AFTER DELETE ON %s BEGIN %s END", fts, idx.Table, remove),
+		fmt.Sprintf(
+			"CREATE TRIGGER IF NOT EXISTS %s_update AFTER UPDATE ON %s BEGIN %s %s END", fts, idx.Table, remove, insert,
+		),
+	}
+
+	if triggers == 0 {
+		statements = append(statements, fmt.Sprintf("INSERT INTO %s(%s) VALUES ('rebuild')", fts, fts))
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	fts5.Store(true)
+
+	return nil
+}
+
+func (idx index) searchFTS5(db *gorm.DB, terms []string) *gorm.DB {
+	fts := idx.ftsTable()
+	boosts := make([]string, 0, len(idx.Columns))
+
+	for _, col := range idx.Columns {
+		boosts = append(boosts, fmt.Sprint(col.Boost))
+	}
+
+	// Every term quoted, so it's matched as a string and not as an operator.
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+term+`"`)
+	}
+
+	return idx.table(db).
+		Select(
+			fmt.Sprintf(
+				"%s.*, -bm25(%s, %s) AS search_rank, snippet(%s, -1, ?, ?, '…', 24) AS search_snippet",
+				idx.Table, fts, strings.Join(boosts, ", "), fts,
+			),
+			startSel, stopSel,
+		).
+		Joins(fmt.Sprintf("JOIN %s ON %s.rowid = %s.rowid", fts, fts, idx.Table)).
+		Where(fts+" MATCH ?", strings.Join(quoted, " "))
+}
+
+// searchLike matches the documents containing all the terms, ranked by the
+// weights of the columns they're in. The snippet is the whole text, cut and
+// highlighted by Snippet.
+func (idx index) searchLike(db *gorm.DB, terms []string) *gorm.DB {
+	stmt := idx.table(db)
+
+	ranks := make([]string, 0, len(terms)*len(idx.Columns))
+	rankArgs := make([]any, 0, len(terms)*len(idx.Columns))
+
+	for _, term := range terms {
+		pattern := "%" + strings.ToLower(term) + "%"
+		matches := make([]string, 0, len(idx.Columns))
+		matchArgs := make([]any, 0, len(idx.Columns))
+
+		for _, col := range idx.Columns {
+			matches = append(matches, fmt.Sprintf("LOWER(%s) LIKE ?", col.Name))
+			matchArgs = append(matchArgs, pattern)
+
+			ranks = append(ranks, fmt.Sprintf("(CASE WHEN LOWER(%s) LIKE ? THEN %v ELSE 0 END)", col.Name, col.Boost))
+			rankArgs = append(rankArgs, pattern)
+		}
+
+		stmt = stmt.Where(strings.Join(matches, " OR "), matchArgs...)
+	}
+
+	return stmt.Select(
+		fmt.Sprintf(
+			"%s.*, CAST(%s AS REAL) AS search_rank, %s AS search_snippet",
+			idx.Table, strings.Join(ranks, " + "), idx.text(),
+		),
+		rankArgs...,
+	)
+}
+
+// text is the SQL expression of the whole text of the documents.
+func (idx index) text() string {
+	values := make([]string, 0, len(idx.Columns))
+
+	for _, col := range idx.Columns {
+		values = append(values, fmt.Sprintf("coalesce(%s, '')", col.Name))
+	}
+
+	return strings.Join(values, " || ' ' || ")
+}
+
+// highlight cuts text around the first match of terms, and puts all the
+// matches in <mark> elements.
+func highlight(text string, terms []string) string {
+	if len(terms) == 0 {
+		return html.EscapeString(text)
+	}
+
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, regexp.QuoteMeta(term))
+	}
+
+	matcher := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+
+	start := 0
+	if loc := matcher.FindStringIndex(text); loc != nil {
+		start = loc[0]
+	}
+
+	before := []rune(text[:start])
+	after := []rune(text[start:])
+	prefix, suffix := "", ""
+
+	if len(before) > snippetBefore {
+		before = before[len(before)-snippetBefore:]
+		prefix = "…"
+	}
+
+	if len(after) > snippetAfter {
+		after = after[:snippetAfter]
+		suffix = "…"
+	}
+
+	text = prefix + string(before) + string(after) + suffix
+
+	var builder strings.Builder
+
+	last := 0
+
+	for _, loc := range matcher.FindAllStringIndex(text, -1) {
+		builder.WriteString(html.EscapeString(text[last:loc[0]]))
+		builder.WriteString("<mark>" + html.EscapeString(text[loc[0]:loc[1]]) + "</mark>")
+
+		last = loc[1]
+	}
+
+	builder.WriteString(html.EscapeString(text[last:]))
+
+	return strings.TrimSpace(builder.String())
+}
@@ -0,0 +1,98 @@
+package search
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"gestione", "documentale"}, Terms("gestione documentale"))
+	assert.Equal(t, []string{"AGPL", "3", "0"}, Terms(`"AGPL-3.0"`))
+	assert.Equal(t, []string{"città"}, Terms("  città*  "))
+	assert.Empty(t, Terms(`" - ( ) *`))
+}
+
+func TestSnippetNil(t *testing.T) {
+	assert.Nil(t, Snippet(nil, "medusa"))
+}
+
+func TestSnippetFromDatabase(t *testing.T) {
+	raw := "a <b>CMS</b> for " + startSel + "Medusa" + stopSel + " & co"
+
+	snippet := Snippet(&raw, "medusa")
+
+	require.NotNil(t, snippet)
+	assert.Equal(t, "a &lt;b&gt;CMS&lt;/b&gt; for <mark>Medusa</mark> &amp; co", *snippet)
+}
+
+func TestSnippetHighlightsTerms(t *testing.T) {
+	raw := "Medusa is a CMS <by> Medusa's authors"
+
+	snippet := Snippet(&raw, "medusa cms")
+
+	require.NotNil(t, snippet)
+	assert.Equal(t, "<mark>Medusa</mark> is a <mark>CMS</mark> &lt;by&gt; <mark>Medusa</mark>&#39;s authors", *snippet)
+}
+
+func TestSnippetCutsAroundFirstMatch(t *testing.T) {
+	raw := strings.Repeat("a ", 100) + "medusa" + strings.Repeat(" z", 100)
+
+	snippet := Snippet(&raw, "medusa")
+
+	require.NotNil(t, snippet)
+	assert.True(t, strings.HasPrefix(*snippet, "…"))
+	assert.True(t, strings.HasSuffix(*snippet, "…"))
+	assert.Contains(t, *snippet, "<mark>medusa</mark>")
+	assert.Len(t, []rune(*snippet), snippetBefore+snippetAfter+len("<mark></mark>")+2)
+}
+
+func TestSnippetWithoutMatches(t *testing.T) {
+	raw := strings.Repeat("z", 300)
+
+	snippet := Snippet(&raw, "medusa")
+
+	require.NotNil(t, snippet)
+	assert.Equal(t, strings.Repeat("z", snippetAfter)+"…", *snippet)
+}
+
+func TestSetupSQLiteRebuildsOnlyNewIndexes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+filepath.Join(t.TempDir(), "search.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Exec("CREATE TABLE software (id TEXT, name TEXT, search_description TEXT, search_features TEXT)").Error)
+	require.NoError(t, db.Exec("CREATE TABLE publishers (id TEXT, description TEXT)").Error)
+	require.NoError(t, db.Exec("INSERT INTO software VALUES ('medusa', 'Medusa', 'A CMS', '')").Error)
+
+	matches := func() int64 {
+		var n int64
+
+		require.NoError(t, db.Raw("SELECT COUNT(*) FROM software_fts WHERE software_fts MATCH 'medusa'").Scan(&n).Error)
+
+		return n
+	}
+
+	require.NoError(t, Setup(db))
+
+	if !fts5.Load() {
+		t.Skip("SQLite was built without FTS5")
+	}
+
+	assert.EqualValues(t, 1, matches(), "a new index is built from the existing rows")
+
+	// Rows not in the index stay out of it on the next startup
+	require.NoError(t, db.Exec("INSERT INTO software_fts(software_fts, rowid, name, search_description, search_features) "+
+		"SELECT 'delete', rowid, name, search_description, search_features FROM software").Error)
+	require.NoError(t, Setup(db))
+	assert.Zero(t, matches())
+
+	// A migration rebuilding the table drops its triggers
+	require.NoError(t, db.Exec("DROP TRIGGER software_fts_insert").Error)
+	require.NoError(t, Setup(db))
+	assert.EqualValues(t, 1, matches())
+}
@@ -0,0 +1,313 @@
+// Package sources describes the drivers the crawlers use to find the
+// repositories of the sources of catalogs, and validates the sources
+// against them.
+//
+// A source is a URL with a driver and its arguments, fe. a GitHub
+// organization or a JSON file with the path of the repository URLs in it.
+// Drivers are looked up by name in a registry holding the built-in ones,
+// more can be added with Register.
+package sources
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/italia/developers-italia-api/internal/common"
+)
+
+// Schema describes a driver to the clients: the URL and the arguments of
+// the sources using it.
+type Schema struct {
+	// Name is the driver of the sources using it
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// URL describes the URL the sources must have
+	URL  string `json:"url"`
+	Args []Arg  `json:"args"`
+}
+
+// Arg describes an argument of a driver, by its position.
+type Arg struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	// Pattern is the regular expression the argument must match, if any
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// SourceDriver is a kind of catalog source the crawlers know how to find
+// the repositories of.
+type SourceDriver interface {
+	// Schema describes the driver, its name included.
+	Schema() Schema
+
+	// Validate returns the problems of a source using the driver with
+	// sourceURL and args, with fields relative to the source.
+	Validate(sourceURL *url.URL, args []string) []common.ValidationError
+}
+
+//nolint:gochecknoglobals // the registry of the drivers
+var (
+	registryMu sync.RWMutex
+	registry   = byName(builtinDrivers)
+)
+
+// Register makes driver available to the sources by the name in its schema.
+// It panics if a driver with that name is already registered, or if the
+// pattern of one of its arguments isn't a valid regular expression.
+func Register(driver SourceDriver) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	schema := driver.Schema()
+	if _, ok := registry[schema.Name]; ok {
+		panic("sources: driver " + schema.Name + " registered twice")
+	}
+
+	// The clients match the arguments with the patterns too
+	if _, err := compilePatterns(schema); err != nil {
+		panic(err)
+	}
+
+	registry[schema.Name] = driver
+}
+
+// Lookup returns the driver with name, if registered.
+func Lookup(name string) (SourceDriver, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	driver, ok := registry[name]
+
+	return driver, ok
+}
+
+// Drivers returns the registered drivers, sorted by name.
+func Drivers() []SourceDriver {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	drivers := make([]SourceDriver, 0, len(registry))
+	for _, driver := range registry {
+		drivers = append(drivers, driver)
+	}
+
+	slices.SortFunc(drivers, func(a SourceDriver, b SourceDriver) int {
+		return strings.Compare(a.Schema().Name, b.Schema().Name)
+	})
+
+	return drivers
+}
+
+// Validate returns the problems of a source with driver, sourceURL and
+// args, with fields relative to the source. Sources without a driver can't
+// have arguments, the crawlers guess it from the URL.
+func Validate(driver *string, sourceURL string, args []string) []common.ValidationError {
+	if driver == nil {
+		if len(args) > 0 {
+			return []common.ValidationError{{Field: "driver", Rule: "required"}}
+		}
+
+		return nil
+	}
+
+	registered, ok := Lookup(*driver)
+	if !ok {
+		return []common.ValidationError{{Field: "driver", Rule: "oneof", Value: *driver}}
+	}
+
+	parsed, err := url.Parse(sourceURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return []common.ValidationError{{Field: "url", Rule: "driver_url", Value: sourceURL}}
+	}
+
+	return registered.Validate(parsed, args)
+}
+
+// driver is a built-in SourceDriver, matching the URLs with matchURL and
+// the arguments with the patterns in its schema.
+type driver struct {
+	schema   Schema
+	patterns []*regexp.Regexp
+	matchURL func(sourceURL *url.URL) bool
+}
+
+// newDriver returns the driver with schema and matchURL, compiling the
+// patterns of its arguments. It panics if one isn't valid.
+func newDriver(schema Schema, matchURL func(sourceURL *url.URL) bool) driver {
+	patterns, err := compilePatterns(schema)
+	if err != nil {
+		panic(err)
+	}
+
+	return driver{schema: schema, patterns: patterns, matchURL: matchURL}
+}
+
+func (d driver) Schema() Schema {
+	return d.schema
+}
+
+func (d driver) Validate(sourceURL *url.URL, args []string) []common.ValidationError {
+	var problems []common.ValidationError
+
+	if !d.matchURL(sourceURL) {
+		problems = append(problems, common.ValidationError{Field: "url", Rule: "driver_url", Value: sourceURL.String()})
+	}
+
+	return append(problems, validateArgs(d.schema.Args, d.patterns, args)...)
+}
+
+// compilePatterns compiles the patterns of the arguments in schema, by
+// position, nil for the ones without.
+func compilePatterns(schema Schema) ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, len(schema.Args))
+
+	for i, arg := range schema.Args {
+		if arg.Pattern == "" {
+			continue
+		}
+
+		pattern, err := regexp.Compile(arg.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("sources: pattern of argument %s of driver %s: %w", arg.Name, schema.Name, err)
+		}
+
+		patterns[i] = pattern
+	}
+
+	return patterns, nil
+}
+
+// validateArgs returns the problems of args according to schema: the
+// required ones must be there, and all must match their pattern, compiled
+// in patterns.
+func validateArgs(schema []Arg, patterns []*regexp.Regexp, args []string) []common.ValidationError {
+	required := 0
+
+	for _, arg := range schema {
+		if arg.Required {
+			required++
+		}
+	}
+
+	if len(args) < required {
+		return []common.ValidationError{{Field: "args", Rule: "min"}}
+	}
+
+	if len(args) > len(schema) {
+		return []common.ValidationError{{Field: "args", Rule: "max"}}
+	}
+
+	var problems []common.ValidationError
+
+	for i, value := range args {
+		if patterns[i] == nil {
+			continue
+		}
+
+		if !patterns[i].MatchString(value) {
+			problems = append(problems, common.ValidationError{
+				Field: fmt.Sprintf("args[%d]", i),
+				Rule:  "pattern",
+				Value: value,
+			})
+		}
+	}
+
+	return problems
+}
+
+// pathSegments returns the non empty segments of the path of sourceURL.
+func pathSegments(sourceURL *url.URL) []string {
+	return strings.FieldsFunc(sourceURL.Path, func(r rune) bool { return r == '/' })
+}
+
+// onHost returns a URL matcher for the given host and number of path
+// segments.
+func onHost(host string, segments int) func(*url.URL) bool {
+	return func(sourceURL *url.URL) bool {
+		return strings.EqualFold(sourceURL.Hostname(), host) && len(pathSegments(sourceURL)) == segments
+	}
+}
+
+// anyPath matches the URLs with a path, on any host.
+func anyPath(sourceURL *url.URL) bool {
+	return len(pathSegments(sourceURL)) > 0
+}
+
+//nolint:gochecknoglobals // read-only
+var builtinDrivers = []SourceDriver{
+	newDriver(
+		Schema{
+			Name:        "github",
+			Description: "The repositories of a GitHub organization or user",
+			URL:         "https://github.com/{organization}",
+			Args:        []Arg{},
+		},
+		onHost("github.com", 1),
+	),
+	newDriver(
+		Schema{
+			Name:        "gitlab",
+			Description: "The repositories of a GitLab group and its subgroups, on any GitLab instance",
+			URL:         "https://{host}/{group}[/{subgroup}...]",
+			Args:        []Arg{},
+		},
+		anyPath,
+	),
+	newDriver(
+		Schema{
+			Name:        "bitbucket",
+			Description: "The repositories of a Bitbucket workspace",
+			URL:         "https://bitbucket.org/{workspace}",
+			Args:        []Arg{},
+		},
+		onHost("bitbucket.org", 1),
+	),
+	newDriver(
+		Schema{
+			Name:        "list",
+			Description: "A static list of repositories, in a text file with a repository URL per line",
+			URL:         "https://{host}/{path to the file}",
+			Args:        []Arg{},
+		},
+		anyPath,
+	),
+	newDriver(
+		Schema{
+			Name:        "publiccode-index",
+			Description: "The repositories in a publiccode.yml index file, with a publiccode.yml URL per line",
+			URL:         "https://{host}/{path to the file}",
+			Args:        []Arg{},
+		},
+		anyPath,
+	),
+	newDriver(
+		Schema{
+			Name:        "json",
+			Description: "The repositories in a JSON document, found by a JSONPath expression",
+			URL:         "https://{host}/{path to the document}",
+			Args: []Arg{{
+				Name:        "path",
+				Description: "JSONPath expression matching the repository URLs in the document",
+				Required:    true,
+				Pattern:     `^\$`,
+			}},
+		},
+		anyPath,
+	),
+}
+
+// byName returns drivers by their name.
+func byName(drivers []SourceDriver) map[string]SourceDriver {
+	named := make(map[string]SourceDriver, len(drivers))
+	for _, driver := range drivers {
+		named[driver.Schema().Name] = driver
+	}
+
+	return named
+}
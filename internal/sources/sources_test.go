@@ -0,0 +1,93 @@
+package sources
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type testDriver struct{}
+
+func (testDriver) Schema() Schema {
+	return Schema{Name: "test"}
+}
+
+func (testDriver) Validate(*url.URL, []string) []common.ValidationError {
+	return nil
+}
+
+type badPatternDriver struct{ testDriver }
+
+func (badPatternDriver) Schema() Schema {
+	return Schema{Name: "bad-pattern", Args: []Arg{{Name: "path", Pattern: "("}}}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestValidate(t *testing.T) {
+	assert.Empty(t, Validate(nil, "https://code.example.org/repo", nil))
+	assert.Equal(t, []common.ValidationError{{Field: "driver", Rule: "required"}},
+		Validate(nil, "https://example.org/repos.json", []string{"$.items"}))
+
+	assert.Equal(t, []common.ValidationError{{Field: "driver", Rule: "oneof", Value: "gihub"}},
+		Validate(ptr("gihub"), "https://github.com/italia", nil))
+
+	assert.Empty(t, Validate(ptr("github"), "https://github.com/italia", nil))
+	assert.Empty(t, Validate(ptr("github"), "https://GitHub.com/italia/", nil))
+	assert.Equal(t, []common.ValidationError{{Field: "url", Rule: "driver_url", Value: "https://github.com/italia/design"}},
+		Validate(ptr("github"), "https://github.com/italia/design", nil))
+	assert.Equal(t, []common.ValidationError{{Field: "url", Rule: "driver_url", Value: "https://gitlab.com/italia"}},
+		Validate(ptr("github"), "https://gitlab.com/italia", nil))
+	assert.Equal(t, []common.ValidationError{{Field: "url", Rule: "driver_url", Value: "ftp://github.com/italia"}},
+		Validate(ptr("github"), "ftp://github.com/italia", nil))
+
+	assert.Empty(t, Validate(ptr("gitlab"), "https://gitlab.example.org/group/subgroup", nil))
+	assert.Equal(t, []common.ValidationError{{Field: "url", Rule: "driver_url", Value: "https://gitlab.com"}},
+		Validate(ptr("gitlab"), "https://gitlab.com", nil))
+	assert.Equal(t, []common.ValidationError{{Field: "args", Rule: "max"}},
+		Validate(ptr("gitlab"), "https://gitlab.com/italia", []string{"extra"}))
+
+	assert.Empty(t, Validate(ptr("json"), "https://example.org/repos.json", []string{"$.items[*].url"}))
+	assert.Equal(t, []common.ValidationError{{Field: "args", Rule: "min"}},
+		Validate(ptr("json"), "https://example.org/repos.json", nil))
+	assert.Equal(t, []common.ValidationError{{Field: "args[0]", Rule: "pattern", Value: "items"}},
+		Validate(ptr("json"), "https://example.org/repos.json", []string{"items"}))
+}
+
+func TestRegister(t *testing.T) {
+	Register(testDriver{})
+
+	t.Cleanup(func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+
+		delete(registry, "test")
+	})
+
+	driver, ok := Lookup("test")
+	assert.True(t, ok)
+	assert.Equal(t, testDriver{}, driver)
+	assert.Empty(t, Validate(ptr("test"), "https://example.org/anything", []string{"any"}))
+
+	assert.Panics(t, func() { Register(testDriver{}) })
+	assert.Panics(t, func() { Register(builtinDrivers[0]) })
+
+	assert.Panics(t, func() { Register(badPatternDriver{}) })
+	_, ok = Lookup("bad-pattern")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { newDriver(badPatternDriver{}.Schema(), anyPath) })
+}
+
+func TestDrivers(t *testing.T) {
+	names := make([]string, 0, len(builtinDrivers))
+	for _, driver := range Drivers() {
+		names = append(names, driver.Schema().Name)
+	}
+
+	assert.Equal(t, []string{"bitbucket", "github", "gitlab", "json", "list", "publiccode-index"}, names)
+}
@@ -0,0 +1,194 @@
+// Package trash permanently deletes the software, publishers and catalogs
+// moved to the trash, once they have been there for long enough.
+package trash
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/italia/developers-italia-api/internal/models"
+	"gorm.io/gorm"
+)
+
+const batchSize = 100
+
+// Purged is the number of resources deleted by Purge.
+type Purged struct {
+	Software   int
+	Publishers int
+	Catalogs   int
+}
+
+// Purge permanently deletes the resources moved to the trash before before,
+// together with the rows depending on them, their logs and webhooks and the
+// redirects to them. The revisions are kept.
+//
+// Catalogs still having software or publishers, even if in the trash, are
+// left in the trash.
+func Purge(gormdb *gorm.DB, before time.Time) (Purged, error) {
+	var purged Purged
+
+	// Purging doesn't notify any webhook, the resources were already
+	// deleted when moved to the trash.
+	gormdb = gormdb.Session(&gorm.Session{SkipHooks: true})
+
+	err := gormdb.Transaction(func(tran *gorm.DB) error {
+		var err error
+
+		if purged.Software, err = purgeSoftware(tran, before); err != nil {
+			return err
+		}
+
+		if purged.Publishers, err = purgePublishers(tran, before); err != nil {
+			return err
+		}
+
+		purged.Catalogs, err = purgeCatalogs(tran, before)
+
+		return err
+	})
+
+	return purged, err //nolint:wrapcheck
+}
+
+func purgeSoftware(tran *gorm.DB, before time.Time) (int, error) {
+	var ids []string
+
+	if err := tran.Unscoped().Model(&models.Software{}).
+		Where("deleted_at < ?", before).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("can't find Software in the trash: %w", err)
+	}
+
+	for batch := range slices.Chunk(ids, batchSize) {
+		for _, model := range []any{
+			&models.SoftwareURL{}, &models.SoftwareDescription{}, &models.SoftwareTerm{}, &models.SoftwareVitality{},
+		} {
+			if err := tran.Where("software_id IN ?", batch).Delete(model).Error; err != nil {
+				return 0, fmt.Errorf("can't purge Software: %w", err)
+			}
+		}
+
+		if err := tran.Where("to_id IN ?", batch).Delete(&models.Redirect{}).Error; err != nil {
+			return 0, fmt.Errorf("can't purge Software: %w", err)
+		}
+
+		if _, _, err := DeleteEntityRows(tran, models.Software{}.TableName(), batch); err != nil {
+			return 0, fmt.Errorf("can't purge Software: %w", err)
+		}
+
+		if err := tran.Unscoped().Where("id IN ?", batch).Delete(&models.Software{}).Error; err != nil {
+			return 0, fmt.Errorf("can't purge Software: %w", err)
+		}
+	}
+
+	return len(ids), nil
+}
+
+func purgePublishers(tran *gorm.DB, before time.Time) (int, error) {
+	var ids []string
+
+	if err := tran.Unscoped().Model(&models.Publisher{}).
+		Where("deleted_at < ?", before).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("can't find Publishers in the trash: %w", err)
+	}
+
+	for batch := range slices.Chunk(ids, batchSize) {
+		// Software in the trash keeps the link to the publisher it had
+		if err := tran.Unscoped().Model(&models.Software{}).
+			Where("publisher_id IN ?", batch).
+			UpdateColumn("publisher_id", nil).Error; err != nil {
+			return 0, fmt.Errorf("can't unlink Software from Publishers: %w", err)
+		}
+
+		if err := tran.Where("publisher_id IN ?", batch).Delete(&models.CodeHosting{}).Error; err != nil {
+			return 0, fmt.Errorf("can't purge Publishers: %w", err)
+		}
+
+		if err := tran.Where("to_id IN ?", batch).Delete(&models.Redirect{}).Error; err != nil {
+			return 0, fmt.Errorf("can't purge Publishers: %w", err)
+		}
+
+		if _, _, err := DeleteEntityRows(tran, models.Publisher{}.TableName(), batch); err != nil {
+			return 0, fmt.Errorf("can't purge Publishers: %w", err)
+		}
+
+		if err := tran.Unscoped().Where("id IN ?", batch).Delete(&models.Publisher{}).Error; err != nil {
+			return 0, fmt.Errorf("can't purge Publishers: %w", err)
+		}
+	}
+
+	return len(ids), nil
+}
+
+func purgeCatalogs(tran *gorm.DB, before time.Time) (int, error) {
+	var ids []string
+
+	if err := tran.Unscoped().Model(&models.Catalog{}).
+		Where("deleted_at < ?", before).
+		Where("NOT EXISTS (?)", tran.Unscoped().Model(&models.Software{}).
+			Select("1").
+			Where("software.catalog_id = catalogs.id")).
+		Where("NOT EXISTS (?)", tran.Unscoped().Model(&models.Publisher{}).
+			Select("1").
+			Where("publishers.catalog_id = catalogs.id")).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("can't find Catalogs in the trash: %w", err)
+	}
+
+	for batch := range slices.Chunk(ids, batchSize) {
+		runs := tran.Model(&models.CatalogRun{}).Select("id").Where("catalog_id IN ?", batch)
+		if err := tran.Where("catalog_run_id IN (?)", runs).Delete(&models.CatalogRunURL{}).Error; err != nil {
+			return 0, fmt.Errorf("can't purge Catalogs: %w", err)
+		}
+
+		if _, _, err := DeleteEntityRows(tran, models.Catalog{}.TableName(), batch); err != nil {
+			return 0, fmt.Errorf("can't purge Catalogs: %w", err)
+		}
+
+		deletions := tran.Model(&models.CatalogDeletion{}).Select("id").Where("catalog_id IN ?", batch)
+		if err := tran.Where("catalog_deletion_id IN (?)", deletions).
+			Delete(&models.CatalogDeletionEntity{}).Error; err != nil {
+			return 0, fmt.Errorf("can't purge Catalogs: %w", err)
+		}
+
+		for _, model := range []any{&models.CatalogRun{}, &models.CatalogSource{}, &models.CatalogDeletion{}} {
+			if err := tran.Where("catalog_id IN ?", batch).Delete(model).Error; err != nil {
+				return 0, fmt.Errorf("can't purge Catalogs: %w", err)
+			}
+		}
+
+		if err := tran.Unscoped().Where("id IN ?", batch).Delete(&models.Catalog{}).Error; err != nil {
+			return 0, fmt.Errorf("can't purge Catalogs: %w", err)
+		}
+	}
+
+	return len(ids), nil
+}
+
+// DeleteEntityRows permanently deletes the logs and the webhooks, with their
+// headers, of the entities of entityType with ids, returning how many logs
+// and webhooks it deleted.
+func DeleteEntityRows(tran *gorm.DB, entityType string, ids []string) (int64, int64, error) {
+	logs := tran.Unscoped().Where("entity_type = ? AND entity_id IN ?", entityType, ids).Delete(&models.Log{})
+	if logs.Error != nil {
+		return 0, 0, fmt.Errorf("can't delete Logs: %w", logs.Error)
+	}
+
+	webhooks := tran.Model(&models.Webhook{}).
+		Select("id").
+		Where("entity_type = ? AND entity_id IN ?", entityType, ids)
+
+	if err := tran.Where("webhook_id IN (?)", webhooks).Delete(&models.WebhookHeader{}).Error; err != nil {
+		return 0, 0, fmt.Errorf("can't delete Webhook headers: %w", err)
+	}
+
+	deleted := tran.Where("entity_type = ? AND entity_id IN ?", entityType, ids).Delete(&models.Webhook{})
+	if deleted.Error != nil {
+		return 0, 0, fmt.Errorf("can't delete Webhooks: %w", deleted.Error)
+	}
+
+	return logs.RowsAffected, deleted.RowsAffected, nil
+}
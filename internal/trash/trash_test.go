@@ -0,0 +1,115 @@
+package trash
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	database, err := gorm.Open(sqlite.Open("file:"+filepath.Join(t.TempDir(), "trash.db")), &gorm.Config{
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, database.AutoMigrate(
+		&models.Catalog{}, &models.CatalogSource{}, &models.CatalogRun{}, &models.CatalogRunURL{},
+		&models.CatalogDeletion{}, &models.CatalogDeletionEntity{}, &models.Publisher{}, &models.CodeHosting{},
+		&models.Software{}, &models.SoftwareURL{}, &models.SoftwareDescription{}, &models.SoftwareTerm{},
+		&models.SoftwareVitality{}, &models.Redirect{}, &models.Log{}, &models.Webhook{}, &models.WebhookHeader{},
+	))
+
+	return database.Session(&gorm.Session{SkipHooks: true})
+}
+
+func count(t *testing.T, database *gorm.DB, model any, query string, args ...any) int64 {
+	t.Helper()
+
+	var n int64
+
+	require.NoError(t, database.Unscoped().Model(model).Where(query, args...).Count(&n).Error)
+
+	return n
+}
+
+func addEntityRows(t *testing.T, database *gorm.DB, entityType, entityID string) {
+	t.Helper()
+
+	id := entityType + "-" + entityID
+
+	require.NoError(t, database.Create(&models.Log{
+		ID: id + "-log", Message: "A log message", EntityType: &entityType, EntityID: &entityID,
+	}).Error)
+	require.NoError(t, database.Create(&models.Webhook{
+		ID: id + "-webhook", URL: "https://example.org/receiver", EntityType: entityType, EntityID: entityID,
+		Headers: []models.WebhookHeader{{ID: id + "-header", Name: "X-Token", Value: "token"}},
+	}).Error)
+}
+
+func TestPurge(t *testing.T) {
+	database := openDatabase(t)
+
+	trashedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := trashedAt.Add(time.Hour)
+
+	for _, catalog := range []models.Catalog{
+		{ID: "empty", Name: "Empty", DeletedAt: gorm.DeletedAt{Time: trashedAt, Valid: true}},
+		{ID: "nonempty", Name: "Non empty", DeletedAt: gorm.DeletedAt{Time: trashedAt, Valid: true}},
+	} {
+		require.NoError(t, database.Create(&catalog).Error)
+	}
+
+	emptyID, nonemptyID := "empty", "nonempty"
+
+	for _, software := range []models.Software{
+		{
+			ID: "purged", CatalogID: &emptyID, SoftwareURLID: "purged-url", PubliccodeYml: "-",
+			DeletedAt: gorm.DeletedAt{Time: trashedAt, Valid: true},
+		},
+		{
+			ID: "recent", CatalogID: &nonemptyID, SoftwareURLID: "recent-url", PubliccodeYml: "-",
+			DeletedAt: gorm.DeletedAt{Time: before.Add(time.Hour), Valid: true},
+		},
+	} {
+		require.NoError(t, database.Omit("URL", "Aliases").Create(&software).Error)
+	}
+
+	require.NoError(t, database.Create(&models.Publisher{
+		ID: "purged", CatalogID: &emptyID, DeletedAt: gorm.DeletedAt{Time: trashedAt, Valid: true},
+	}).Error)
+
+	addEntityRows(t, database, "software", "purged")
+	addEntityRows(t, database, "software", "recent")
+	addEntityRows(t, database, "publishers", "purged")
+	addEntityRows(t, database, "catalogs", "empty")
+
+	purged, err := Purge(database, before)
+	require.NoError(t, err)
+
+	assert.Equal(t, Purged{Software: 1, Publishers: 1, Catalogs: 1}, purged)
+
+	for _, entityType := range []string{"software", "publishers"} {
+		assert.Zero(t, count(t, database, &models.Log{}, "entity_type = ? AND entity_id = ?", entityType, "purged"))
+		assert.Zero(t, count(t, database, &models.Webhook{}, "entity_type = ? AND entity_id = ?", entityType, "purged"))
+	}
+
+	assert.Zero(t, count(t, database, &models.WebhookHeader{},
+		"webhook_id IN ?", []string{"software-purged-webhook", "publishers-purged-webhook"}))
+	assert.Zero(t, count(t, database, &models.Log{}, "entity_type = ? AND entity_id = ?", "catalogs", "empty"))
+	assert.Zero(t, count(t, database, &models.Catalog{}, "id = ?", "empty"))
+
+	// Catalogs still having software in the trash are skipped
+	assert.EqualValues(t, 1, count(t, database, &models.Catalog{}, "id = ?", "nonempty"))
+	assert.EqualValues(t, 1, count(t, database, &models.Software{}, "id = ?", "recent"))
+	assert.EqualValues(t, 1, count(t, database, &models.Log{}, "entity_id = ?", "recent"))
+	assert.EqualValues(t, 1, count(t, database, &models.Webhook{}, "entity_id = ?", "recent"))
+	assert.EqualValues(t, 1, count(t, database, &models.WebhookHeader{}, "webhook_id = ?", "software-recent-webhook"))
+}
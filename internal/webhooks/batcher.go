@@ -0,0 +1,117 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/italia/developers-italia-api/internal/models"
+)
+
+// Delivery is the function called by the Batcher when a batch is ready to
+// be sent to its webhook.
+type Delivery func(webhook models.Webhook, body []byte, signature string, headers http.Header)
+
+// Batcher collects the payloads for webhooks in batched mode and delivers
+// them as a single JSON array, `Window` after the first payload of the batch
+// or as soon as it holds `MaxEvents` payloads, whichever comes first.
+type Batcher struct {
+	deliver  Delivery
+	newTimer func(time.Duration, func()) Timer
+	mu       sync.Mutex
+	pending  map[string]*pendingBatch
+}
+
+type pendingBatch struct {
+	timer    Timer
+	webhook  models.Webhook
+	headers  http.Header
+	payloads []json.RawMessage
+}
+
+func NewBatcher(deliver Delivery) *Batcher {
+	return &Batcher{
+		deliver: deliver,
+		newTimer: func(d time.Duration, f func()) Timer {
+			return realTimer{t: time.AfterFunc(d, f)}
+		},
+		pending: make(map[string]*pendingBatch),
+	}
+}
+
+// Add appends payload to the batch of webhook, starting a new one if needed.
+// webhook.Batch must not be nil.
+func (b *Batcher) Add(webhook models.Webhook, headers http.Header, payload []byte) {
+	b.mu.Lock()
+
+	batch, ok := b.pending[webhook.ID]
+	if !ok {
+		batch = &pendingBatch{}
+		batch.timer = b.newTimer(
+			time.Duration(webhook.Batch.Window)*time.Millisecond,
+			func() { b.flush(webhook.ID) },
+		)
+		b.pending[webhook.ID] = batch
+	}
+
+	// Keep the latest configuration, the webhook might have been updated
+	// since the batch started.
+	batch.webhook = webhook
+	batch.headers = headers
+	batch.payloads = append(batch.payloads, payload)
+
+	full := webhook.Batch.MaxEvents > 0 && len(batch.payloads) >= webhook.Batch.MaxEvents
+
+	b.mu.Unlock()
+
+	if full {
+		b.flush(webhook.ID)
+	}
+}
+
+// Drain stops every pending timer and delivers the batches that were
+// waiting, synchronously. Use it on graceful shutdown, after the Debouncer
+// has been drained.
+func (b *Batcher) Drain() {
+	b.mu.Lock()
+
+	batches := make([]*pendingBatch, 0, len(b.pending))
+
+	for id, batch := range b.pending {
+		batch.timer.Stop()
+		batches = append(batches, batch)
+		delete(b.pending, id)
+	}
+
+	b.mu.Unlock()
+
+	for _, batch := range batches {
+		b.send(batch)
+	}
+}
+
+func (b *Batcher) flush(webhookID string) {
+	b.mu.Lock()
+
+	batch, ok := b.pending[webhookID]
+	if !ok {
+		b.mu.Unlock()
+
+		return
+	}
+
+	batch.timer.Stop()
+	delete(b.pending, webhookID)
+
+	b.mu.Unlock()
+
+	b.send(batch)
+}
+
+func (b *Batcher) send(batch *pendingBatch) {
+	// A slice of json.RawMessage can't fail to marshal.
+	body, _ := json.Marshal(batch.payloads)
+
+	b.deliver(batch.webhook, body, sign(batch.webhook.Secret, body), batch.headers)
+}
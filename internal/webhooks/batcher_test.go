@@ -0,0 +1,121 @@
+package webhooks
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type delivered struct {
+	url       string
+	body      string
+	signature string
+}
+
+func newBatcherWithClock() (*Batcher, *fakeClock, func() []delivered) {
+	var (
+		mu         sync.Mutex
+		deliveries []delivered
+	)
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := &Batcher{
+		deliver: func(webhook models.Webhook, body []byte, signature string, _ http.Header) {
+			mu.Lock()
+			deliveries = append(deliveries, delivered{url: webhook.URL, body: string(body), signature: signature})
+			mu.Unlock()
+		},
+		newTimer: clock.NewTimer,
+		pending:  make(map[string]*pendingBatch),
+	}
+
+	return b, clock, func() []delivered {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]delivered(nil), deliveries...)
+	}
+}
+
+func batchedWebhook(id string, maxEvents int) models.Webhook {
+	return models.Webhook{
+		ID:     id,
+		URL:    "https://" + id + ".example.org/receiver",
+		Secret: "batch-secret-1234",
+		Batch:  &models.WebhookBatch{Window: 60000, MaxEvents: maxEvents},
+	}
+}
+
+func TestBatcherDeliversWindowAsSingleArray(t *testing.T) {
+	b, clock, deliveries := newBatcherWithClock()
+
+	webhook := batchedWebhook("a", 0)
+
+	b.Add(webhook, nil, []byte(`{"event":"create","subject":"/software/1"}`))
+	b.Add(webhook, nil, []byte(`{"event":"update","subject":"/software/2"}`))
+	b.Add(webhook, nil, []byte(`{"event":"delete","subject":"/software/3"}`))
+
+	assert.Empty(t, deliveries(), "nothing is delivered before the window ends")
+	assert.Equal(t, time.Minute, clock.LastWait(), "the window starts with the first event")
+
+	clock.FireLatest()
+
+	got := deliveries()
+	require.Len(t, got, 1, "the whole window is delivered at once")
+
+	body := `[{"event":"create","subject":"/software/1"},` +
+		`{"event":"update","subject":"/software/2"},` +
+		`{"event":"delete","subject":"/software/3"}]`
+
+	assert.Equal(t, "https://a.example.org/receiver", got[0].url)
+	assert.JSONEq(t, body, got[0].body)
+	assert.Equal(t, expectedSignature("batch-secret-1234", []byte(got[0].body)), got[0].signature,
+		"the signature covers the whole batch")
+}
+
+func TestBatcherDeliversWhenMaxEventsReached(t *testing.T) {
+	b, clock, deliveries := newBatcherWithClock()
+
+	webhook := batchedWebhook("a", 2)
+
+	b.Add(webhook, nil, []byte(`{"event":"create","subject":"/software/1"}`))
+	b.Add(webhook, nil, []byte(`{"event":"create","subject":"/software/2"}`))
+
+	require.Len(t, deliveries(), 1, "a full batch doesn't wait for the window")
+
+	b.Add(webhook, nil, []byte(`{"event":"create","subject":"/software/3"}`))
+
+	clock.FireAll()
+
+	got := deliveries()
+	require.Len(t, got, 2, "the timer of the full batch was stopped")
+	assert.JSONEq(t, `[{"event":"create","subject":"/software/3"}]`, got[1].body)
+}
+
+func TestBatcherSeparateWebhooksBatchIndependently(t *testing.T) {
+	b, clock, deliveries := newBatcherWithClock()
+
+	b.Add(batchedWebhook("a", 0), nil, []byte(`{"event":"create","subject":"/software/1"}`))
+	b.Add(batchedWebhook("b", 0), nil, []byte(`{"event":"create","subject":"/software/1"}`))
+
+	clock.FireAll()
+
+	assert.Len(t, deliveries(), 2)
+}
+
+func TestBatcherDrainDeliversPendingBatches(t *testing.T) {
+	b, _, deliveries := newBatcherWithClock()
+
+	b.Add(batchedWebhook("a", 0), nil, []byte(`{"event":"create","subject":"/software/1"}`))
+	b.Add(batchedWebhook("b", 0), nil, []byte(`{"event":"create","subject":"/software/1"}`))
+
+	b.Drain()
+
+	assert.Len(t, deliveries(), 2, "Drain delivers every pending batch")
+	assert.Empty(t, b.pending, "Drain leaves the pending map empty")
+}
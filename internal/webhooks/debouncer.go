@@ -5,6 +5,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/metrics"
 	"github.com/italia/developers-italia-api/internal/models"
 )
 
@@ -12,11 +14,24 @@ import (
 // event is ready to be sent.
 type Dispatcher func(models.Event)
 
+// EventDebouncer debounces the webhook events before dispatching them. It's
+// implemented by the in-memory Debouncer and by the SharedDebouncer, which
+// keeps pending events in the database.
+type EventDebouncer interface {
+	Submit(event models.Event)
+	Drain()
+}
+
+var _ EventDebouncer = (*Debouncer)(nil)
+
 // Debouncer collapses bursts of webhook events on the same
-// (EntityType, EntityID, Type) key into a single dispatch. A trailing
+// (EntityType, EntityID) key into a single dispatch. A trailing
 // timer fires `delay` after the last Submit for that key, capped at
 // `cap` from the first Submit so a key under continuous churn still
 // gets dispatched.
+//
+// Events of different types on the same entity are coalesced following
+// its lifecycle, see coalesce.
 type Debouncer struct {
 	delay    time.Duration
 	cap      time.Duration
@@ -61,7 +76,7 @@ func (d *Debouncer) Submit(event models.Event) {
 		return
 	}
 
-	key := event.EntityType + "/" + event.EntityID + "/" + event.Type
+	key := event.EntityType + "/" + event.EntityID
 
 	d.mu.Lock()
 
@@ -70,13 +85,27 @@ func (d *Debouncer) Submit(event models.Event) {
 	prev, ok := d.pending[key]
 	if ok {
 		prev.timer.Stop()
-		prev.event = event
+
+		metrics.DebouncerCoalesced.Inc()
+
+		merged, keep := coalesce(prev.event, event)
+		if !keep {
+			delete(d.pending, key)
+			metrics.DebouncerPending.Dec()
+			d.mu.Unlock()
+
+			return
+		}
+
+		prev.event = merged
 	} else {
 		prev = &pendingEvent{
 			deadline: now.Add(d.cap),
 			event:    event,
 		}
 		d.pending[key] = prev
+
+		metrics.DebouncerPending.Inc()
 	}
 
 	wait := d.delay
@@ -111,6 +140,8 @@ func (d *Debouncer) Drain() {
 		delete(d.pending, key)
 	}
 
+	metrics.DebouncerPending.Sub(float64(len(events)))
+
 	d.mu.Unlock()
 
 	for _, event := range events {
@@ -118,6 +149,34 @@ func (d *Debouncer) Drain() {
 	}
 }
 
+// coalesce merges next into the pending event prev for the same entity,
+// so subscribers see the net change over the window:
+//
+//   - create then update is still a create
+//   - update then delete is a delete
+//   - create then delete cancels out, and keep is false
+//   - delete then create is an update, the entity was replaced
+//
+// In any other case the latest event wins.
+func coalesce(prev, next models.Event) (models.Event, bool) {
+	switch {
+	case prev.Type == common.EventTypeCreate && next.Type == common.EventTypeUpdate:
+		merged := next
+		merged.Type = common.EventTypeCreate
+
+		return merged, true
+	case prev.Type == common.EventTypeCreate && next.Type == common.EventTypeDelete:
+		return models.Event{}, false
+	case prev.Type == common.EventTypeDelete && next.Type == common.EventTypeCreate:
+		merged := next
+		merged.Type = common.EventTypeUpdate
+
+		return merged, true
+	default:
+		return next, true
+	}
+}
+
 func (d *Debouncer) flush(key string) {
 	d.mu.Lock()
 
@@ -129,6 +188,7 @@ func (d *Debouncer) flush(key string) {
 	}
 
 	delete(d.pending, key)
+	metrics.DebouncerPending.Dec()
 
 	event := pending.event
 
@@ -137,10 +197,14 @@ func (d *Debouncer) flush(key string) {
 	d.safeDispatch(event)
 }
 
+func (d *Debouncer) safeDispatch(event models.Event) {
+	safeDispatch(d.dispatch, event)
+}
+
 // safeDispatch isolates the user-supplied Dispatcher so a panic from it
 // (a nil deref in the dispatcher closure, a panicking GORM call, etc.)
 // does not bring down the timer goroutine and through it the process.
-func (d *Debouncer) safeDispatch(event models.Event) {
+func safeDispatch(dispatch Dispatcher, event models.Event) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("debouncer: dispatch panicked for %s/%s/%s: %v",
@@ -148,5 +212,5 @@ func (d *Debouncer) safeDispatch(event models.Event) {
 		}
 	}()
 
-	d.dispatch(event)
+	dispatch(event)
 }
@@ -5,7 +5,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/italia/developers-italia-api/internal/metrics"
 	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -251,3 +253,119 @@ func TestDebouncerDispatchPanicDoesNotCrashTimer(t *testing.T) {
 
 	assert.Equal(t, 1, dispatched)
 }
+
+func TestDebouncerCoalescesLifecycle(t *testing.T) {
+	tests := []struct {
+		name     string
+		types    []string
+		expected []string
+	}{
+		{"create then update", []string{"create", "update", "update"}, []string{"create"}},
+		{"update then delete", []string{"update", "update", "delete"}, []string{"delete"}},
+		{"create then delete", []string{"create", "update", "delete"}, nil},
+		{"delete then create", []string{"delete", "create"}, []string{"update"}},
+		{"updates", []string{"update", "update"}, []string{"update"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				received []models.Event
+			)
+
+			dispatch := func(e models.Event) {
+				mu.Lock()
+				received = append(received, e)
+				mu.Unlock()
+			}
+
+			d, clock := newDebouncerWithClock(time.Second, 10*time.Second, dispatch)
+
+			for i, eventType := range tt.types {
+				d.Submit(models.Event{
+					EntityType: "software",
+					EntityID:   "abc",
+					Type:       eventType,
+					ID:         string(rune('a' + i)),
+				})
+				clock.Advance(100 * time.Millisecond)
+			}
+
+			clock.FireAll()
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			types := []string(nil)
+			for _, e := range received {
+				types = append(types, e.Type)
+			}
+
+			assert.Equal(t, tt.expected, types)
+			assert.Empty(t, d.pending)
+		})
+	}
+}
+
+func TestDebouncerCreateThenDeleteDoesNotDelayOtherEntities(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []models.Event
+	)
+
+	dispatch := func(e models.Event) {
+		mu.Lock()
+		received = append(received, e)
+		mu.Unlock()
+	}
+
+	d, clock := newDebouncerWithClock(time.Second, 10*time.Second, dispatch)
+
+	d.Submit(models.Event{EntityType: "software", EntityID: "a", Type: "create"})
+	d.Submit(models.Event{EntityType: "software", EntityID: "b", Type: "update"})
+	d.Submit(models.Event{EntityType: "software", EntityID: "a", Type: "delete"})
+
+	clock.FireAll()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Len(t, received, 1)
+	assert.Equal(t, "b", received[0].EntityID)
+}
+
+func TestDebouncerCoalescedCreateKeepsLatestID(t *testing.T) {
+	var received []models.Event
+
+	d, clock := newDebouncerWithClock(time.Second, 10*time.Second, func(e models.Event) {
+		received = append(received, e)
+	})
+
+	d.Submit(models.Event{ID: "first", EntityType: "software", EntityID: "a", Type: "create"})
+	d.Submit(models.Event{ID: "second", EntityType: "software", EntityID: "a", Type: "update"})
+
+	clock.FireLatest()
+
+	assert.Len(t, received, 1)
+	assert.Equal(t, "create", received[0].Type)
+	assert.Equal(t, "second", received[0].ID)
+}
+
+func TestDebouncerMetrics(t *testing.T) {
+	d, clock := newDebouncerWithClock(time.Second, 10*time.Second, func(models.Event) {})
+
+	coalesced := testutil.ToFloat64(metrics.DebouncerCoalesced)
+	pending := testutil.ToFloat64(metrics.DebouncerPending)
+
+	d.Submit(models.Event{EntityType: "Software", EntityID: "abc", Type: "Updated"})
+	d.Submit(models.Event{EntityType: "Software", EntityID: "abc", Type: "Updated"})
+	d.Submit(models.Event{EntityType: "Software", EntityID: "def", Type: "Updated"})
+
+	assert.InDelta(t, coalesced+1, testutil.ToFloat64(metrics.DebouncerCoalesced), 0)
+	assert.InDelta(t, pending+2, testutil.ToFloat64(metrics.DebouncerPending), 0)
+
+	clock.FireAll()
+
+	assert.InDelta(t, pending, testutil.ToFloat64(metrics.DebouncerPending), 0)
+}
@@ -0,0 +1,248 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/italia/developers-italia-api/internal/metrics"
+	"github.com/italia/developers-italia-api/internal/models"
+	"gorm.io/gorm"
+)
+
+// Tracker keeps track of the webhook deliveries in flight, so shutdown can
+// wait for them and store the ones that don't complete in time.
+type Tracker struct {
+	post     func(ctx context.Context, url string, body []byte, signature string, headers http.Header)
+	ctx      context.Context //nolint:containedctx // cancels every delivery on shutdown
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	next     uint64
+	inflight map[uint64]models.PendingDelivery
+	stop     chan struct{}
+	stopOnce sync.Once
+	rescans  sync.WaitGroup
+}
+
+func NewTracker() *Tracker {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Tracker{
+		post:     post,
+		ctx:      ctx,
+		cancel:   cancel,
+		inflight: make(map[uint64]models.PendingDelivery),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Deliver posts body to webhook in the background.
+func (t *Tracker) Deliver(webhook models.Webhook, body []byte, signature string, headers http.Header) {
+	t.deliver(webhook, body, signature, headers, models.PendingDelivery{WebhookID: webhook.ID, Payload: string(body)}, nil)
+}
+
+// deliver posts body to webhook in the background, as delivery, and calls
+// done once the delivery succeeded or failed, unless cancelled by Shutdown.
+func (t *Tracker) deliver(
+	webhook models.Webhook,
+	body []byte,
+	signature string,
+	headers http.Header,
+	delivery models.PendingDelivery,
+	done func(),
+) {
+	t.mu.Lock()
+
+	id := t.next
+	t.next++
+	t.inflight[id] = delivery
+
+	t.wg.Add(1)
+	t.mu.Unlock()
+
+	go func() {
+		defer t.wg.Done()
+
+		t.post(t.ctx, webhook.URL, body, signature, headers)
+
+		// Cancelled by Shutdown, that stores it
+		if t.ctx.Err() != nil {
+			return
+		}
+
+		t.mu.Lock()
+		delete(t.inflight, id)
+		t.mu.Unlock()
+
+		if done != nil {
+			done()
+		}
+	}()
+}
+
+// Shutdown stops the rescans of ResumeEvery and waits up to timeout for the
+// deliveries in flight. The ones still running after that are cancelled and
+// stored in db, for Resume to deliver them again.
+//
+// A delivery completing right when the timeout expires might be stored
+// anyway, and so be delivered twice.
+func (t *Tracker) Shutdown(db *gorm.DB, timeout time.Duration) error {
+	t.stopOnce.Do(func() { close(t.stop) })
+	t.rescans.Wait()
+
+	done := make(chan struct{})
+
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+	}
+
+	t.mu.Lock()
+
+	var (
+		pending []models.PendingDelivery
+		claimed []uint
+	)
+
+	for _, delivery := range t.inflight {
+		// Resumed from db, it's there already
+		if delivery.ID != 0 {
+			claimed = append(claimed, delivery.ID)
+
+			continue
+		}
+
+		pending = append(pending, delivery)
+	}
+
+	t.mu.Unlock()
+
+	t.cancel()
+	<-done
+
+	if len(claimed) != 0 {
+		if err := db.Model(&models.PendingDelivery{}).
+			Where("id IN ?", claimed).
+			Update("claimed_at", nil).Error; err != nil {
+			return fmt.Errorf("can't release %d pending deliveries: %w", len(claimed), err)
+		}
+	}
+
+	if len(pending) != 0 {
+		if err := db.Create(&pending).Error; err != nil {
+			return fmt.Errorf("can't store %d pending deliveries: %w", len(pending), err)
+		}
+	}
+
+	if count := len(pending) + len(claimed); count != 0 {
+		log.Printf("stored %d webhook deliveries still in flight at shutdown", count)
+	}
+
+	return nil
+}
+
+// Resume delivers again the deliveries stored by Shutdown, claiming each of
+// them so only one replica delivers it. A delivery is deleted once it
+// succeeded or failed, and claimed again if the replica delivering it
+// stopped before.
+func (t *Tracker) Resume(db *gorm.DB) error {
+	now := time.Now()
+
+	// A delivery takes dispatchTimeout at most, a claim older than that was
+	// left by a replica that stopped abruptly
+	staleBefore := now.Add(-2 * dispatchTimeout)
+
+	var pending []models.PendingDelivery
+
+	if err := db.Where("claimed_at IS NULL OR claimed_at < ?", staleBefore).
+		Order("id").
+		Find(&pending).Error; err != nil {
+		return fmt.Errorf("can't find pending deliveries: %w", err)
+	}
+
+	for _, delivery := range pending {
+		result := db.Model(&models.PendingDelivery{}).
+			Where("id = ? AND (claimed_at IS NULL OR claimed_at < ?)", delivery.ID, staleBefore).
+			Update("claimed_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("can't claim pending delivery: %w", result.Error)
+		}
+
+		// Another replica claimed it first.
+		if result.RowsAffected != 1 {
+			continue
+		}
+
+		remove := func() {
+			if err := db.Delete(&models.PendingDelivery{}, delivery.ID).Error; err != nil {
+				log.Printf("can't delete pending delivery %d: %s", delivery.ID, err.Error())
+			}
+		}
+
+		var webhook models.Webhook
+
+		err := db.Preload("Headers").First(&webhook, "id = ?", delivery.WebhookID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The webhook was deleted in the meantime
+			remove()
+
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("can't find webhook %s: %w", delivery.WebhookID, err)
+		}
+
+		headers, err := customHeaders(webhook)
+		if err != nil {
+			log.Printf("can't dispatch webhook %s: %s", webhook.URL, err.Error())
+			remove()
+
+			continue
+		}
+
+		body := []byte(delivery.Payload)
+
+		metrics.DeliveryRetries.WithLabelValues(metrics.Host(webhook.URL)).Inc()
+
+		t.deliver(webhook, body, sign(webhook.Secret, body), headers, delivery, remove)
+	}
+
+	return nil
+}
+
+// ResumeEvery calls Resume every interval in the background until Shutdown,
+// to deliver the deliveries stored by the other replicas stopping, or left
+// claimed by the ones that stopped abruptly.
+func (t *Tracker) ResumeEvery(db *gorm.DB, interval time.Duration) {
+	t.rescans.Add(1)
+
+	go func() {
+		defer t.rescans.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-ticker.C:
+				if err := t.Resume(db); err != nil {
+					log.Println(err)
+				}
+			}
+		}
+	}()
+}
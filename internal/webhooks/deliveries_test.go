@@ -0,0 +1,224 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/italia/developers-italia-api/internal/metrics"
+	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerShutdownWaitsForDeliveries(t *testing.T) {
+	db := setupDB(t, nil)
+
+	var delivered sync.WaitGroup
+
+	delivered.Add(1)
+
+	tracker := NewTracker()
+	tracker.post = func(context.Context, string, []byte, string, http.Header) {
+		time.Sleep(100 * time.Millisecond)
+		delivered.Done()
+	}
+
+	tracker.Deliver(models.Webhook{ID: "wh-wait", URL: "https://example.org"}, []byte(`{}`), "", nil)
+
+	require.NoError(t, tracker.Shutdown(db, time.Second))
+
+	// Shutdown returned, so the delivery must have completed already.
+	delivered.Wait()
+
+	var count int64
+
+	require.NoError(t, db.Model(&models.PendingDelivery{}).Where("webhook_id = ?", "wh-wait").Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+func TestTrackerShutdownStoresDeliveriesInFlight(t *testing.T) {
+	db := setupDB(t, nil)
+
+	cancelled := make(chan struct{})
+
+	tracker := NewTracker()
+	tracker.post = func(ctx context.Context, _ string, _ []byte, _ string, _ http.Header) {
+		<-ctx.Done()
+		close(cancelled)
+	}
+
+	tracker.Deliver(models.Webhook{ID: "wh-slow", URL: "https://example.org"}, []byte(`{"event":"create"}`), "", nil)
+
+	require.NoError(t, tracker.Shutdown(db, 50*time.Millisecond))
+
+	select {
+	case <-cancelled:
+	default:
+		t.Fatal("the delivery in flight should have been cancelled")
+	}
+
+	var pending []models.PendingDelivery
+
+	require.NoError(t, db.Where("webhook_id = ?", "wh-slow").Find(&pending).Error)
+	require.Len(t, pending, 1)
+	assert.JSONEq(t, `{"event":"create"}`, pending[0].Payload)
+}
+
+func TestTrackerResume(t *testing.T) {
+	received := make(chan *http.Request, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	const secret = "resume-secret"
+
+	db := setupDB(t, []models.Webhook{
+		{ID: "wh-resume", URL: srv.URL, Secret: secret, EntityType: "software"},
+	})
+
+	payload := `{"event":"update","subject":"/software"}`
+
+	require.NoError(t, db.Create(&[]models.PendingDelivery{
+		{WebhookID: "wh-resume", Payload: payload},
+		{WebhookID: "wh-deleted", Payload: payload},
+	}).Error)
+
+	retries := metrics.DeliveryRetries.WithLabelValues(metrics.Host(srv.URL))
+	before := testutil.ToFloat64(retries)
+
+	tracker := NewTracker()
+	require.NoError(t, tracker.Resume(db))
+
+	select {
+	case r := <-received:
+		assert.Equal(t, expectedSignature(secret, []byte(payload)), r.Header.Get("X-Webhook-Signature"))
+	case <-time.After(time.Second):
+		t.Fatal("the stored delivery wasn't resumed")
+	}
+
+	// The delivery of the deleted webhook isn't attempted
+	assert.InDelta(t, before+1, testutil.ToFloat64(retries), 0)
+
+	require.NoError(t, tracker.Shutdown(db, time.Second))
+
+	var count int64
+
+	require.NoError(t, db.Model(&models.PendingDelivery{}).
+		Where("webhook_id IN ?", []string{"wh-resume", "wh-deleted"}).
+		Count(&count).Error)
+	assert.Zero(t, count, "resumed deliveries are removed, as the ones of deleted webhooks")
+}
+
+func TestTrackerResumeKeepsDeliveriesUntilDone(t *testing.T) {
+	db := setupDB(t, []models.Webhook{
+		{ID: "wh-keep", URL: "https://keep.example.org", EntityType: "software"},
+	})
+
+	require.NoError(t, db.Create(&models.PendingDelivery{WebhookID: "wh-keep", Payload: `{}`}).Error)
+
+	posting := make(chan struct{})
+	release := make(chan struct{})
+
+	tracker := NewTracker()
+	tracker.post = func(context.Context, string, []byte, string, http.Header) {
+		close(posting)
+		<-release
+	}
+
+	require.NoError(t, tracker.Resume(db))
+
+	<-posting
+
+	var delivery models.PendingDelivery
+
+	require.NoError(t, db.First(&delivery, "webhook_id = ?", "wh-keep").Error)
+	assert.NotNil(t, delivery.ClaimedAt, "the delivery in flight is claimed, not deleted")
+
+	// Claimed, so not delivered twice
+	require.NoError(t, tracker.Resume(db))
+
+	close(release)
+	require.NoError(t, tracker.Shutdown(db, time.Second))
+
+	var count int64
+
+	require.NoError(t, db.Model(&models.PendingDelivery{}).Where("webhook_id = ?", "wh-keep").Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+func TestTrackerShutdownReleasesResumedDeliveries(t *testing.T) {
+	db := setupDB(t, []models.Webhook{
+		{ID: "wh-release", URL: "https://release.example.org", EntityType: "software"},
+	})
+
+	require.NoError(t, db.Create(&models.PendingDelivery{WebhookID: "wh-release", Payload: `{}`}).Error)
+
+	tracker := NewTracker()
+	tracker.post = func(ctx context.Context, _ string, _ []byte, _ string, _ http.Header) {
+		<-ctx.Done()
+	}
+
+	require.NoError(t, tracker.Resume(db))
+	require.NoError(t, tracker.Shutdown(db, 50*time.Millisecond))
+
+	var pending []models.PendingDelivery
+
+	require.NoError(t, db.Where("webhook_id = ?", "wh-release").Find(&pending).Error)
+	require.Len(t, pending, 1, "stored once, not again")
+	assert.Nil(t, pending[0].ClaimedAt)
+	require.NoError(t, db.Delete(&pending).Error)
+}
+
+func TestTrackerResumeEvery(t *testing.T) {
+	db := setupDB(t, []models.Webhook{
+		{ID: "wh-every", URL: "https://every.example.org", EntityType: "software"},
+	})
+
+	delivered := make(chan string, 2)
+
+	tracker := NewTracker()
+	tracker.post = func(_ context.Context, url string, body []byte, _ string, _ http.Header) {
+		// The database is shared with the other tests
+		if url == "https://every.example.org" {
+			delivered <- string(body)
+		}
+	}
+
+	tracker.ResumeEvery(db, 10*time.Millisecond)
+
+	stale := time.Now().Add(-time.Hour)
+
+	// Stored by another replica, and left claimed by one that stopped abruptly
+	require.NoError(t, db.Create(&[]models.PendingDelivery{
+		{WebhookID: "wh-every", Payload: `{"stored":true}`},
+		{WebhookID: "wh-every", Payload: `{"stale":true}`, ClaimedAt: &stale},
+	}).Error)
+
+	var bodies []string
+
+	for range 2 {
+		select {
+		case body := <-delivered:
+			bodies = append(bodies, body)
+		case <-time.After(time.Second):
+			t.Fatal("the stored deliveries weren't resumed")
+		}
+	}
+
+	assert.ElementsMatch(t, []string{`{"stored":true}`, `{"stale":true}`}, bodies)
+
+	require.NoError(t, tracker.Shutdown(db, time.Second))
+
+	var count int64
+
+	require.NoError(t, db.Model(&models.PendingDelivery{}).Where("webhook_id = ?", "wh-every").Count(&count).Error)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,243 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/metrics"
+	"github.com/italia/developers-italia-api/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxSubmitAttempts bounds the retries of a Submit racing with
+	// another replica on the same entity.
+	maxSubmitAttempts = 5
+
+	// pollBatchSize is the maximum number of due events claimed per poll.
+	pollBatchSize = 100
+)
+
+// pollInterval is how often a SharedDebouncer looks for due events. It is
+// a var (not const) so tests can shorten it.
+//
+//nolint:gochecknoglobals // tunable for tests, effectively const at runtime
+var pollInterval = 250 * time.Millisecond
+
+var errSubmitContention = errors.New("too many concurrent updates")
+
+var _ EventDebouncer = (*SharedDebouncer)(nil)
+
+// SharedDebouncer debounces like Debouncer, but keeps the pending events
+// in the database so the window and the cap apply across all the replicas
+// sharing it.
+//
+// Every replica polls for due events and claims each of them by deleting
+// its row at the version it read: only the replica whose delete succeeds
+// dispatches it. Pending events survive a restart, and are dispatched by
+// the next replica that polls.
+type SharedDebouncer struct {
+	db       *gorm.DB
+	delay    time.Duration
+	cap      time.Duration
+	dispatch Dispatcher
+	now      func() time.Time
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewSharedDebouncer returns a SharedDebouncer and starts polling db for
+// due events.
+func NewSharedDebouncer(db *gorm.DB, delay, capDuration time.Duration, dispatch Dispatcher) *SharedDebouncer {
+	d := &SharedDebouncer{
+		db:       db,
+		delay:    delay,
+		cap:      capDuration,
+		dispatch: dispatch,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go d.run()
+
+	return d
+}
+
+func (d *SharedDebouncer) Submit(event models.Event) {
+	if d.delay <= 0 {
+		safeDispatch(d.dispatch, event)
+
+		return
+	}
+
+	if err := d.submit(event); err != nil {
+		// Better a notification that wasn't debounced than a lost one.
+		log.Printf("debouncer: can't store pending event %s/%s/%s, dispatching it now: %s",
+			event.EntityType, event.EntityID, event.Type, err.Error())
+
+		safeDispatch(d.dispatch, event)
+	}
+}
+
+// Drain stops polling. Unlike Debouncer.Drain it doesn't dispatch the
+// pending events: they are in the database, and other replicas or the next
+// instance will dispatch them when they are due.
+//
+// Submit must not be called concurrently with Drain or after it returns.
+func (d *SharedDebouncer) Drain() {
+	close(d.stop)
+	<-d.done
+}
+
+func (d *SharedDebouncer) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			if err := d.poll(); err != nil {
+				log.Printf("debouncer: can't poll pending events: %s", err.Error())
+			}
+		}
+	}
+}
+
+// submit stores event, coalescing it with the pending one for the same
+// entity if any. Every write is conditional on the version read, and is
+// retried when another replica got there first.
+func (d *SharedDebouncer) submit(event models.Event) error { //nolint:cyclop // mostly error handling ifs
+	key := event.EntityType + "/" + event.EntityID
+
+	for range maxSubmitAttempts {
+		now := d.now().UTC()
+
+		var prev models.PendingEvent
+
+		err := d.db.Take(&prev, "entity_key = ?", key).Error
+
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			pending := models.PendingEvent{
+				EntityKey:  key,
+				EventID:    event.ID,
+				Type:       event.Type,
+				EntityType: event.EntityType,
+				EntityID:   event.EntityID,
+				Deadline:   now.Add(d.cap),
+				Version:    1,
+			}
+			pending.DueAt = d.dueAt(now, pending.Deadline)
+
+			err := d.db.Create(&pending).Error
+			if err == nil {
+				return nil
+			}
+
+			if common.DuplicateField(err) == nil {
+				return fmt.Errorf("can't create pending event: %w", err)
+			}
+		case err != nil:
+			return fmt.Errorf("can't read pending event: %w", err)
+		default:
+			merged, keep := coalesce(pendingToEvent(prev), event)
+
+			stmt := d.db.Where("entity_key = ? AND version = ?", key, prev.Version)
+
+			var result *gorm.DB
+			if keep {
+				result = stmt.Model(&models.PendingEvent{}).Updates(map[string]any{
+					"event_id": merged.ID,
+					"type":     merged.Type,
+					"due_at":   d.dueAt(now, prev.Deadline),
+					"version":  prev.Version + 1,
+				})
+			} else {
+				result = stmt.Delete(&models.PendingEvent{})
+			}
+
+			if result.Error != nil {
+				return fmt.Errorf("can't update pending event: %w", result.Error)
+			}
+
+			if result.RowsAffected == 1 {
+				metrics.DebouncerCoalesced.Inc()
+
+				return nil
+			}
+		}
+	}
+
+	return errSubmitContention
+}
+
+// dueAt is when an event submitted at now is dispatched: `delay` later,
+// capped at deadline.
+func (d *SharedDebouncer) dueAt(now, deadline time.Time) time.Time {
+	due := now.Add(d.delay)
+
+	if d.cap > 0 && deadline.Before(due) {
+		return deadline
+	}
+
+	return due
+}
+
+// poll claims and dispatches the events that are due.
+func (d *SharedDebouncer) poll() error {
+	var pending int64
+
+	// Every replica reports the same value here, the count shared by all
+	// of them.
+	if err := d.db.Model(&models.PendingEvent{}).Count(&pending).Error; err != nil {
+		return fmt.Errorf("can't count pending events: %w", err)
+	}
+
+	metrics.DebouncerPending.Set(float64(pending))
+
+	var due []models.PendingEvent
+
+	if err := d.db.
+		Where("due_at <= ?", d.now().UTC()).
+		Order("due_at").
+		Limit(pollBatchSize).
+		Find(&due).Error; err != nil {
+		return fmt.Errorf("can't find due events: %w", err)
+	}
+
+	for _, pending := range due {
+		result := d.db.
+			Where("entity_key = ? AND version = ?", pending.EntityKey, pending.Version).
+			Delete(&models.PendingEvent{})
+		if result.Error != nil {
+			return fmt.Errorf("can't claim pending event: %w", result.Error)
+		}
+
+		// Another replica claimed it first, or it was coalesced with a
+		// newer event after we read it.
+		if result.RowsAffected != 1 {
+			continue
+		}
+
+		safeDispatch(d.dispatch, pendingToEvent(pending))
+	}
+
+	return nil
+}
+
+func pendingToEvent(pending models.PendingEvent) models.Event {
+	return models.Event{
+		ID:         pending.EventID,
+		Type:       pending.Type,
+		EntityType: pending.EntityType,
+		EntityID:   pending.EntityID,
+	}
+}
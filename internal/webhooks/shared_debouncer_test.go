@@ -0,0 +1,148 @@
+package webhooks
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/italia/developers-italia-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// replicas returns n SharedDebouncers sharing a fresh database and a fake
+// clock, like replicas of the API sharing the same PostgreSQL.
+func replicas(
+	t *testing.T, n int, delay, capDuration time.Duration,
+) ([]*SharedDebouncer, *fakeClock, func() []models.Event) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.PendingEvent{}))
+
+	var (
+		mu       sync.Mutex
+		received []models.Event
+	)
+
+	dispatch := func(e models.Event) {
+		mu.Lock()
+		received = append(received, e)
+		mu.Unlock()
+	}
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+
+	debouncers := make([]*SharedDebouncer, n)
+	for i := range debouncers {
+		debouncers[i] = &SharedDebouncer{
+			db:       db,
+			delay:    delay,
+			cap:      capDuration,
+			dispatch: dispatch,
+			now:      clock.Now,
+		}
+	}
+
+	return debouncers, clock, func() []models.Event {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]models.Event(nil), received...)
+	}
+}
+
+func TestSharedDebouncerCoalescesAcrossReplicas(t *testing.T) {
+	replica, clock, received := replicas(t, 3, time.Second, 10*time.Second)
+
+	for i := range 6 {
+		replica[i%3].Submit(models.Event{
+			ID:         string(rune('a' + i)),
+			EntityType: "software",
+			EntityID:   "abc",
+			Type:       "update",
+		})
+		clock.Advance(100 * time.Millisecond)
+	}
+
+	for _, r := range replica {
+		require.NoError(t, r.poll())
+	}
+
+	assert.Empty(t, received(), "nothing is due before the delay since the last Submit")
+
+	clock.Advance(time.Second)
+
+	for _, r := range replica {
+		require.NoError(t, r.poll())
+	}
+
+	got := received()
+	require.Len(t, got, 1, "a burst spread across replicas is dispatched once")
+	assert.Equal(t, "f", got[0].ID, "the latest event in the burst is the one dispatched")
+}
+
+func TestSharedDebouncerCapAppliesAcrossReplicas(t *testing.T) {
+	replica, clock, received := replicas(t, 2, 100*time.Millisecond, 300*time.Millisecond)
+
+	for i := range 8 {
+		replica[i%2].Submit(models.Event{EntityType: "software", EntityID: "x", Type: "update"})
+		clock.Advance(50 * time.Millisecond)
+
+		require.NoError(t, replica[(i+1)%2].poll())
+	}
+
+	assert.Len(t, received(), 1, "the cap forces a dispatch despite the delay resets")
+}
+
+func TestSharedDebouncerCoalescesLifecycle(t *testing.T) {
+	replica, clock, received := replicas(t, 2, time.Second, 10*time.Second)
+
+	replica[0].Submit(models.Event{EntityType: "software", EntityID: "a", Type: "create"})
+	replica[1].Submit(models.Event{EntityType: "software", EntityID: "a", Type: "update"})
+	replica[0].Submit(models.Event{EntityType: "software", EntityID: "b", Type: "create"})
+	replica[1].Submit(models.Event{EntityType: "software", EntityID: "b", Type: "delete"})
+
+	clock.Advance(time.Second)
+
+	require.NoError(t, replica[0].poll())
+
+	got := received()
+	require.Len(t, got, 1)
+	assert.Equal(t, "a", got[0].EntityID)
+	assert.Equal(t, "create", got[0].Type)
+}
+
+func TestSharedDebouncerDrainKeepsPendingEvents(t *testing.T) {
+	replica, clock, received := replicas(t, 2, time.Second, 10*time.Second)
+
+	replica[0].stop = make(chan struct{})
+	replica[0].done = make(chan struct{})
+
+	go replica[0].run()
+
+	replica[0].Submit(models.Event{EntityType: "software", EntityID: "a", Type: "update"})
+	replica[0].Drain()
+
+	assert.Empty(t, received(), "Drain doesn't dispatch events that aren't due")
+
+	clock.Advance(time.Second)
+
+	require.NoError(t, replica[1].poll())
+
+	assert.Len(t, received(), 1, "another replica dispatches the event once due")
+}
+
+func TestSharedDebouncerZeroDelayDispatchesImmediately(t *testing.T) {
+	replica, _, received := replicas(t, 1, 0, 0)
+
+	replica[0].Submit(models.Event{EntityType: "software", EntityID: "a", Type: "update"})
+
+	assert.Len(t, received(), 1, "zero delay disables debouncing")
+}
@@ -11,8 +11,11 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/metrics"
 	"github.com/italia/developers-italia-api/internal/models"
 	"gorm.io/gorm"
 )
@@ -23,6 +26,10 @@ import (
 //nolint:gochecknoglobals // tunable for tests, effectively const at runtime
 var dispatchTimeout = 10 * time.Second
 
+// resumeInterval is how often the deliveries stored by Shutdown are looked
+// for, as other replicas store theirs while this one runs.
+const resumeInterval = time.Minute
+
 // httpClient is shared across dispatches so the underlying http.Transport
 // can reuse TCP and TLS connections to the same subscriber. The per-request
 // deadline is enforced via the request context, not via Client.Timeout.
@@ -30,6 +37,38 @@ var dispatchTimeout = 10 * time.Second
 //nolint:gochecknoglobals // singleton needed for connection pool reuse
 var httpClient = &http.Client{}
 
+// tracker runs every delivery, so shutdown can wait for them.
+//
+//nolint:gochecknoglobals // shared across dispatches, like httpClient
+var tracker = NewTracker()
+
+// batcher holds the payloads of the webhooks in batched mode until their
+// batch is delivered.
+//
+//nolint:gochecknoglobals // shared across dispatches, like httpClient
+var batcher = NewBatcher(tracker.Deliver)
+
+// DrainBatches delivers the batches still being collected. Call it on
+// graceful shutdown, after draining the Debouncer.
+func DrainBatches() {
+	batcher.Drain()
+}
+
+// Shutdown waits up to timeout for the deliveries in flight, and stores the
+// ones that didn't complete in db. Call it on graceful shutdown, after
+// DrainBatches.
+func Shutdown(db *gorm.DB, timeout time.Duration) error {
+	return tracker.Shutdown(db, timeout)
+}
+
+// ResumeDeliveries delivers again the deliveries stored by Shutdown, now and
+// every resumeInterval until Shutdown. Call it on start.
+func ResumeDeliveries(db *gorm.DB) error {
+	tracker.ResumeEvery(db, resumeInterval)
+
+	return tracker.Resume(db)
+}
+
 func DispatchWebhooks(event models.Event, gorm *gorm.DB) error {
 	var webhooks []models.Webhook
 
@@ -47,7 +86,7 @@ func DispatchWebhooks(event models.Event, gorm *gorm.DB) error {
 			event.EntityID,
 		)
 
-	if err := stmt.Select("url, secret").Find(&webhooks).Error; err != nil {
+	if err := stmt.Preload("Headers").Select("id, url, secret, batch").Find(&webhooks).Error; err != nil {
 		return fmt.Errorf("error finding webhooks for %s: %w", subject, err)
 	}
 
@@ -60,25 +99,65 @@ func DispatchWebhooks(event models.Event, gorm *gorm.DB) error {
 	}
 
 	for _, webhook := range webhooks {
-		signature := ""
+		headers, err := customHeaders(webhook)
+		if err != nil {
+			log.Printf("can't dispatch webhook %s: %s", webhook.URL, err.Error())
 
-		if webhook.Secret != "" {
-			h := hmac.New(sha256.New, []byte(webhook.Secret))
+			continue
+		}
 
-			// This can't fail
-			_, _ = h.Write(jsonBody)
+		if webhook.Batch != nil {
+			batcher.Add(webhook, headers, jsonBody)
 
-			signature = hex.EncodeToString(h.Sum(nil))
+			continue
 		}
 
-		go post(webhook.URL, jsonBody, signature)
+		tracker.Deliver(webhook, jsonBody, sign(webhook.Secret, jsonBody), headers)
 	}
 
 	return nil
 }
 
-func post(url string, body []byte, signature string) {
-	ctx, cancel := context.WithTimeout(context.Background(), dispatchTimeout)
+// sign returns the hex encoded HMAC-SHA256 of body with secret, or an empty
+// string if there's no secret.
+func sign(secret string, body []byte) string {
+	if secret == "" {
+		return ""
+	}
+
+	h := hmac.New(sha256.New, []byte(secret))
+
+	// This can't fail
+	_, _ = h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// customHeaders returns the custom headers to add to deliveries of webhook,
+// with the secret values decrypted.
+func customHeaders(webhook models.Webhook) (http.Header, error) {
+	headers := make(http.Header, len(webhook.Headers))
+
+	for _, header := range webhook.Headers {
+		value := header.Value
+
+		if header.Secret {
+			decrypted, err := common.Decrypt(common.WebhookHeadersKey(), header.Value)
+			if err != nil {
+				return nil, fmt.Errorf("can't decrypt header %s: %w", header.Name, err)
+			}
+
+			value = decrypted
+		}
+
+		headers.Add(header.Name, value)
+	}
+
+	return headers, nil
+}
+
+func post(ctx context.Context, url string, body []byte, signature string, headers http.Header) {
+	ctx, cancel := context.WithTimeout(ctx, dispatchTimeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(
@@ -91,6 +170,11 @@ func post(url string, body []byte, signature string) {
 		return
 	}
 
+	// Custom headers go first, so they can never override the ones below.
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+
 	req.Header.Set("User-Agent", "DevelopersItaliaAPI-Webhook/1.0")
 	req.Header.Set("Content-Type", "application/json")
 
@@ -98,17 +182,23 @@ func post(url string, body []byte, signature string) {
 		req.Header.Set("X-Webhook-Signature", signature)
 	}
 
+	host := metrics.Host(url)
+	start := time.Now()
+
 	response, err := httpClient.Do(req)
+
+	metrics.DeliveryDuration.WithLabelValues(host).Observe(time.Since(start).Seconds())
+
 	if err != nil {
-		//nolint:godox // need to implement this in the future
-		// TODO: Replace this and send anonymous failure metrics to a monitoring
-		// system instead.
-		// (https://github.com/italia/developers-italia-api/issues/73)
+		metrics.Deliveries.WithLabelValues(host, "error").Inc()
+
 		log.Printf("error while dispatching webhook %s: %s", url, err.Error())
 
 		return
 	}
 
+	metrics.Deliveries.WithLabelValues(host, strconv.Itoa(response.StatusCode/100)+"xx").Inc()
+
 	// Drain and close so the connection can return to the pool, regardless
 	// of whether the response is 2xx or an error status below.
 	defer func() {
@@ -117,10 +207,6 @@ func post(url string, body []byte, signature string) {
 	}()
 
 	if response.StatusCode < 200 || response.StatusCode > 299 {
-		//nolint:godox // need to implement this in the future
-		// TODO: Replace this and send anonymous failure metrics to a monitoring
-		// system instead.
-		// (https://github.com/italia/developers-italia-api/issues/73)
 		log.Printf("error while dispatching webhook %s: got HTTP %d", url, response.StatusCode)
 
 		return
@@ -1,6 +1,7 @@
 package webhooks
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -11,12 +12,16 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
+	"github.com/italia/developers-italia-api/internal/common"
+	"github.com/italia/developers-italia-api/internal/metrics"
 	"github.com/italia/developers-italia-api/internal/models"
 )
 
@@ -28,7 +33,7 @@ func setupDB(t *testing.T, webhooks []models.Webhook) *gorm.DB {
 	})
 	require.NoError(t, err)
 
-	require.NoError(t, db.AutoMigrate(&models.Webhook{}))
+	require.NoError(t, db.AutoMigrate(&models.Webhook{}, &models.WebhookHeader{}, &models.PendingDelivery{}))
 
 	for i := range webhooks {
 		require.NoError(t, db.Create(&webhooks[i]).Error)
@@ -58,7 +63,7 @@ func TestPostTimesOut(t *testing.T) {
 	defer srv.Close()
 
 	start := time.Now()
-	post(srv.URL, []byte(`{"event":"test","subject":"/software"}`), "")
+	post(context.Background(), srv.URL, []byte(`{"event":"test","subject":"/software"}`), "", nil)
 	elapsed := time.Since(start)
 
 	require.Less(t, elapsed, serverDelay-100*time.Millisecond,
@@ -161,3 +166,81 @@ func TestDispatchWebhooks_EmptySecretNoHeader(t *testing.T) {
 
 	assert.False(t, present, "X-Webhook-Signature header must be absent when secret is empty")
 }
+
+// TestDispatchWebhooks_CustomHeaders verifies that custom headers are added
+// to the delivery, with secret values decrypted, and that they can't
+// override the headers set by the API.
+func TestDispatchWebhooks_CustomHeaders(t *testing.T) {
+	original := common.EnvironmentConfig.PasetoKey
+	common.EnvironmentConfig.PasetoKey = &common.Base64Key{}
+	defer func() { common.EnvironmentConfig.PasetoKey = original }()
+
+	var (
+		mu       sync.Mutex
+		received http.Header
+	)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = r.Header.Clone()
+		mu.Unlock()
+
+		wg.Done()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	encrypted, err := common.Encrypt(common.WebhookHeadersKey(), "Bearer s3cr3t")
+	require.NoError(t, err)
+
+	db := setupDB(t, []models.Webhook{
+		{
+			ID: "wh-4", URL: srv.URL, EntityType: "software", EntityID: "",
+			Headers: []models.WebhookHeader{
+				{ID: "h-1", Name: "Authorization", Value: encrypted, Secret: true},
+				{ID: "h-2", Name: "X-Tenant", Value: "developers-italia"},
+			},
+		},
+	})
+
+	event := models.Event{Type: "created", EntityType: "software", EntityID: ""}
+
+	require.NoError(t, DispatchWebhooks(event, db))
+
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, "Bearer s3cr3t", received.Get("Authorization"))
+	assert.Equal(t, "developers-italia", received.Get("X-Tenant"))
+	assert.Equal(t, "application/json", received.Get("Content-Type"))
+}
+
+func TestPostMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	host := metrics.Host(srv.URL)
+	failed := metrics.Deliveries.WithLabelValues(host, "5xx")
+	before := testutil.ToFloat64(failed)
+
+	post(context.Background(), srv.URL, []byte(`{"event":"test","subject":"/software"}`), "", nil)
+
+	assert.InDelta(t, before+1, testutil.ToFloat64(failed), 0)
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.DeliveryDuration.WithLabelValues(host).(prometheus.Histogram)))
+
+	srv.Close()
+
+	unreachable := metrics.Deliveries.WithLabelValues(host, "error")
+	before = testutil.ToFloat64(unreachable)
+
+	post(context.Background(), srv.URL, []byte(`{"event":"test","subject":"/software"}`), "", nil)
+
+	assert.InDelta(t, before+1, testutil.ToFloat64(unreachable), 0)
+}
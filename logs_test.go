@@ -389,11 +389,80 @@ func TestLogsEndpoints(t *testing.T) {
 			expectedContentType: "application/problem+json",
 			expectedBody:        `{"title":"can't create Log","detail":"Catalog was not found","status":404}`,
 		},
+
+		// GET /catalogs/:id/logs
+		{
+			description: "GET catalog logs",
+			setupFunc:   addCatalogLogs,
+			query:       "GET /v1/catalogs/italia/logs",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				require.Equal(t, 1, len(data))
+
+				assert.Equal(t, "Catalog log", data[0]["message"])
+				assert.Equal(t, "/catalogs/a8e5e6d7-0b1c-4f2a-8e3d-9c4b5a6f7e8d", data[0]["entity"])
+
+				assertPaginationLinks(t, response, nil, nil)
+			},
+		},
+		{
+			description: "GET catalog logs - root catalog (∅) has the logs with null entity",
+			setupFunc:   addCatalogLogs,
+			query:       "GET /v1/catalogs/%E2%88%85/logs",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				require.Equal(t, 1, len(data))
+
+				assert.Equal(t, "Root catalog log", data[0]["message"])
+				assert.NotContains(t, data[0], "entity")
+			},
+		},
+		{
+			description:         "GET catalog logs - non-existing catalog returns 404",
+			query:               "GET /v1/catalogs/00000000-0000-0000-0000-000000000000/logs",
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Logs","detail":"Catalog was not found","status":404}`,
+		},
 	}
 
 	runTestCases(t, tests)
 }
 
+func addCatalogLogs(t *testing.T) {
+	t.Helper()
+
+	query := fmt.Sprintf(
+		"INSERT INTO logs (id, message, entity_type, entity_id, created_at, updated_at) VALUES (%s, %s, %s, %s, %s, %s)",
+		placeholder(1),
+		placeholder(2),
+		placeholder(3),
+		placeholder(4),
+		placeholder(5),
+		placeholder(6),
+	)
+
+	createdAt := time.Date(2011, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := db.Exec(
+		query, "7c1e3a4b-5d6f-4a8b-9c0d-1e2f3a4b5c6d", "Catalog log", "catalogs", italiaID, createdAt, createdAt,
+	)
+	require.NoError(t, err)
+
+	_, err = db.Exec(
+		query, "8d2f4b5c-6e7a-4b9c-8d1e-2f3a4b5c6d7e", "Root catalog log", "catalogs", nil, createdAt, createdAt,
+	)
+	require.NoError(t, err)
+}
+
 func addLogsForPaginationCapTest(t *testing.T) {
 	t.Helper()
 
@@ -32,7 +32,7 @@ func main() {
 		Use:          "developers-italia-api",
 		SilenceUsage: true,
 		RunE: func(_ *cobra.Command, _ []string) error {
-			app, debouncer := Setup()
+			app, drain := Setup()
 
 			sigCh := make(chan os.Signal, 1)
 			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -47,10 +47,10 @@ func main() {
 
 			err := app.Listen(":3000")
 
-			// Drain runs after Listen returns so any webhook event held
-			// in the debouncer's pending window is dispatched before the
-			// process exits.
-			debouncer.Drain()
+			// Drain runs after Listen returns so the pending webhook events
+			// are dispatched, and their deliveries completed or stored,
+			// before the process exits.
+			drain()
 
 			if err != nil {
 				return fmt.Errorf("listen: %w", err)
@@ -61,13 +61,16 @@ func main() {
 	}
 
 	rootCmd.AddCommand(cmd.NewTokenCmd())
+	rootCmd.AddCommand(cmd.NewPurgeCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
 }
 
-func Setup() (*fiber.App, *webhooks.Debouncer) {
+// Setup returns the app, and the function to call after it shut down to
+// drain the webhook events and deliveries still pending.
+func Setup() (*fiber.App, func()) {
 	if err := env.Parse(&common.EnvironmentConfig); err != nil {
 		panic(err)
 	}
@@ -82,15 +85,11 @@ func Setup() (*fiber.App, *webhooks.Debouncer) {
 	//
 	// It dispatches the webhooks related to the event that occurred
 	// (es. Publisher creation, Software delete, etc.)
-	debouncer := webhooks.NewDebouncer(
-		time.Duration(common.EnvironmentConfig.WebhookDebounceMS)*time.Millisecond,
-		time.Duration(common.EnvironmentConfig.WebhookDebounceMaxMS)*time.Millisecond,
-		func(event models.Event) {
-			if err := webhooks.DispatchWebhooks(event, gormDB); err != nil {
-				log.Println(err)
-			}
-		},
-	)
+	debouncer := newDebouncer(gormDB, func(event models.Event) {
+		if err := webhooks.DispatchWebhooks(event, gormDB); err != nil {
+			log.Println(err)
+		}
+	})
 
 	go func() {
 		for event := range models.EventChan {
@@ -98,6 +97,16 @@ func Setup() (*fiber.App, *webhooks.Debouncer) {
 		}
 	}()
 
+	// Deliveries interrupted by the last shutdown
+	if err := webhooks.ResumeDeliveries(gormDB); err != nil {
+		log.Println(err)
+	}
+
+	// Cascading deletions of catalogs interrupted by the last shutdown
+	if err := handlers.FailInterruptedDeletions(gormDB); err != nil {
+		log.Println(err)
+	}
+
 	app := fiber.New(fiber.Config{
 		ErrorHandler: common.CustomErrorHandler,
 		// Fiber doesn't set DisallowUnknownFields by default
@@ -144,7 +153,8 @@ func Setup() (*fiber.App, *webhooks.Debouncer) {
 		common.EnvironmentConfig.PasetoKey = middleware.NewRandomPasetoKey()
 	}
 
-	prometheus := fiberprometheus.New(os.Args[0])
+	// Use the default registry, where the webhook metrics are registered too.
+	prometheus := fiberprometheus.NewWithDefaultRegistry(os.Args[0])
 	prometheus.RegisterAt(app, "/metrics")
 	app.Use(prometheus.Middleware)
 
@@ -152,7 +162,38 @@ func Setup() (*fiber.App, *webhooks.Debouncer) {
 
 	setupHandlers(app, gormDB)
 
-	return app, debouncer
+	drain := func() {
+		// The deletions in the background send events too, and would be
+		// rolled back if interrupted.
+		handlers.WaitDeletions()
+
+		// The batches might have been filled by the debouncer, and
+		// both start deliveries.
+		debouncer.Drain()
+		webhooks.DrainBatches()
+
+		timeout := time.Duration(common.EnvironmentConfig.WebhookShutdownTimeoutMS) * time.Millisecond
+		if err := webhooks.Shutdown(gormDB, timeout); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return app, drain
+}
+
+// newDebouncer returns the webhook debouncer for the configured backend.
+func newDebouncer(gormDB *gorm.DB, dispatch webhooks.Dispatcher) webhooks.EventDebouncer {
+	delay := time.Duration(common.EnvironmentConfig.WebhookDebounceMS) * time.Millisecond
+	capDuration := time.Duration(common.EnvironmentConfig.WebhookDebounceMaxMS) * time.Millisecond
+
+	switch backend := common.EnvironmentConfig.WebhookDebounceBackend; backend {
+	case "memory":
+		return webhooks.NewDebouncer(delay, capDuration, dispatch)
+	case "database":
+		return webhooks.NewSharedDebouncer(gormDB, delay, capDuration, dispatch)
+	default:
+		panic(fmt.Sprintf("invalid WEBHOOK_DEBOUNCE_BACKEND %q, must be \"memory\" or \"database\"", backend))
+	}
 }
 
 func setupHandlers(app *fiber.App, gormDB *gorm.DB) { //nolint:funlen
@@ -163,24 +204,55 @@ func setupHandlers(app *fiber.App, gormDB *gorm.DB) { //nolint:funlen
 	logHandler := handlers.NewLog(gormDB)
 	publisherWebhookHandler := handlers.NewWebhook[models.Publisher](gormDB)
 	softwareWebhookHandler := handlers.NewWebhook[models.Software](gormDB)
+	catalogRevisionHandler := handlers.NewRevision[models.Catalog](gormDB)
+	publisherRevisionHandler := handlers.NewRevision[models.Publisher](gormDB)
+	softwareRevisionHandler := handlers.NewRevision[models.Software](gormDB)
+	catalogRunHandler := handlers.NewCatalogRun(gormDB)
 
 	//nolint:varnamelen
 	v1 := app.Group("/v1")
 
 	v1.Get("/catalogs", catalogHandler.GetCatalogs)
 	v1.Post("/catalogs", catalogHandler.PostCatalog)
+	v1.Get("/catalogs/drivers", catalogHandler.GetCatalogDrivers)
 	v1.Get("/catalogs/:id", catalogHandler.GetCatalog)
 	v1.Patch("/catalogs/:id", catalogHandler.PatchCatalog)
 	v1.Delete("/catalogs/:id", catalogHandler.DeleteCatalog)
+	v1.Post("/catalogs/:id/restore", catalogHandler.RestoreCatalog)
+	v1.Get("/catalogs/:id/deletions/:deletionId", catalogHandler.GetCatalogDeletion)
 	v1.Get("/catalogs/:id/publishers", catalogHandler.GetCatalogPublishers)
 	v1.Post("/catalogs/:id/publishers", catalogHandler.PostCatalogPublisher)
+	v1.Post("/catalogs/:id/publishers/move", catalogHandler.MoveCatalogPublishersBulk)
+	v1.Get("/catalogs/:id/publishers/:publisherId", catalogHandler.GetCatalogPublisher)
 	v1.Patch("/catalogs/:id/publishers/:publisherId", catalogHandler.PatchCatalogPublisher)
+	v1.Delete("/catalogs/:id/publishers/:publisherId", catalogHandler.DeleteCatalogPublisher)
+	v1.Get("/catalogs/:id/publishers/:publisherId/logs", logHandler.GetCatalogPublisherLogs)
+	v1.Post("/catalogs/:id/publishers/:publisherId/logs", logHandler.PostCatalogPublisherLog)
+	v1.Post("/catalogs/:id/publishers/:publisherId/move", catalogHandler.MoveCatalogPublisher)
 	v1.Get("/catalogs/:id/software", catalogHandler.GetCatalogSoftware)
+	v1.Get("/catalogs/:id/software/facets", catalogHandler.GetCatalogSoftwareFacets)
 	v1.Post("/catalogs/:id/software", catalogHandler.PostCatalogSoftware)
+	v1.Put("/catalogs/:id/software", catalogHandler.PutCatalogSoftware)
+	v1.Post("/catalogs/:id/software/move", catalogHandler.MoveCatalogSoftwareBulk)
+	v1.Get("/catalogs/:id/software/:softwareId", catalogHandler.GetCatalogSingleSoftware)
 	v1.Patch("/catalogs/:id/software/:softwareId", catalogHandler.PatchCatalogSoftware)
+	v1.Delete("/catalogs/:id/software/:softwareId", catalogHandler.DeleteCatalogSoftware)
+	v1.Get("/catalogs/:id/software/:softwareId/analysis", catalogHandler.GetCatalogSoftwareAnalysis)
+	v1.Patch("/catalogs/:id/software/:softwareId/analysis", catalogHandler.PatchCatalogSoftwareAnalysis)
+	v1.Get("/catalogs/:id/software/:softwareId/logs", logHandler.GetCatalogSoftwareLogs)
+	v1.Post("/catalogs/:id/software/:softwareId/logs", logHandler.PostCatalogSoftwareLog)
+	v1.Post("/catalogs/:id/software/:softwareId/move", catalogHandler.MoveCatalogSoftware)
 	v1.Get("/catalogs/:id/analysis", catalogHandler.GetCatalogAnalysis)
 	v1.Patch("/catalogs/:id/analysis", catalogHandler.PatchCatalogAnalysis)
+	v1.Get("/catalogs/:id/logs", logHandler.GetCatalogLogs)
 	v1.Post("/catalogs/:id/logs", logHandler.PostCatalogLog)
+	v1.Get("/catalogs/:id/runs", catalogRunHandler.GetCatalogRuns)
+	v1.Post("/catalogs/:id/runs", catalogRunHandler.PostCatalogRun)
+	v1.Get("/catalogs/:id/runs/:runId", catalogRunHandler.GetCatalogRun)
+	v1.Post("/catalogs/:id/runs/:runId/urls", catalogRunHandler.PostCatalogRunURLs)
+	v1.Post("/catalogs/:id/runs/:runId/close", catalogRunHandler.PostCatalogRunClose)
+	v1.Get("/catalogs/:id/revisions", catalogRevisionHandler.GetRevisions)
+	v1.Get("/catalogs/:id/revisions/diff", catalogRevisionHandler.GetRevisionsDiff)
 
 	v1.Get("/publishers/webhooks", publisherWebhookHandler.GetResourceWebhooks)
 	v1.Post("/publishers/webhooks", publisherWebhookHandler.PostResourceWebhook)
@@ -191,18 +263,32 @@ func setupHandlers(app *fiber.App, gormDB *gorm.DB) { //nolint:funlen
 	v1.Post("/publishers", publisherHandler.PostPublisher)
 	v1.Patch("/publishers/:id", publisherHandler.PatchPublisher)
 	v1.Delete("/publishers/:id", publisherHandler.DeletePublisher)
+	v1.Post("/publishers/:id/restore", publisherHandler.RestorePublisher)
+	v1.Post("/publishers/:id/merge", publisherHandler.MergePublisher)
+	v1.Get("/publishers/:id/software", publisherHandler.GetPublisherSoftware)
+	v1.Get("/publishers/:id/revisions", publisherRevisionHandler.GetRevisions)
+	v1.Get("/publishers/:id/revisions/diff", publisherRevisionHandler.GetRevisionsDiff)
 
 	v1.Get("/software/webhooks", softwareWebhookHandler.GetResourceWebhooks)
 	v1.Post("/software/webhooks", softwareWebhookHandler.PostResourceWebhook)
 	v1.Get("/software/:id/webhooks", softwareWebhookHandler.GetSingleResourceWebhooks)
 	v1.Post("/software/:id/webhooks", softwareWebhookHandler.PostSingleResourceWebhook)
 	v1.Get("/software", softwareHandler.GetAllSoftware)
+	v1.Get("/software/facets", softwareHandler.GetSoftwareFacets)
 	v1.Get("/software/:id", softwareHandler.GetSoftware)
+	v1.Get("/software/:id/copies", softwareHandler.GetSoftwareCopies)
 	v1.Post("/software", softwareHandler.PostSoftware)
+	v1.Put("/software", softwareHandler.PutSoftware)
 	v1.Patch("/software/:id", softwareHandler.PatchSoftware)
 	v1.Delete("/software/:id", softwareHandler.DeleteSoftware)
+	v1.Post("/software/:id/restore", softwareHandler.RestoreSoftware)
+	v1.Post("/software/:id/merge", softwareHandler.MergeSoftware)
 	v1.Get("/software/:id/analysis", softwareHandler.GetSoftwareAnalysis)
 	v1.Patch("/software/:id/analysis", softwareHandler.PatchSoftwareAnalysis)
+	v1.Get("/software/:id/vitality", softwareHandler.GetSoftwareVitality)
+	v1.Post("/software/:id/vitality", softwareHandler.PostSoftwareVitality)
+	v1.Get("/software/:id/revisions", softwareRevisionHandler.GetRevisions)
+	v1.Get("/software/:id/revisions/diff", softwareRevisionHandler.GetRevisionsDiff)
 
 	v1.Get("/logs", logHandler.GetLogs)
 	v1.Get("/logs/:id<guid>", logHandler.GetLog)
@@ -211,6 +297,8 @@ func setupHandlers(app *fiber.App, gormDB *gorm.DB) { //nolint:funlen
 	v1.Delete("/logs/:id<guid>", logHandler.DeleteLog)
 	v1.Get("/software/:id/logs", logHandler.GetSoftwareLogs)
 	v1.Post("/software/:id/logs", logHandler.PostSoftwareLog)
+	v1.Get("/publishers/:id/logs", logHandler.GetPublisherLogs)
+	v1.Post("/publishers/:id/logs", logHandler.PostPublisherLog)
 
 	v1.Get("/status", statusHandler.GetStatus)
 
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -85,6 +87,32 @@ func TestPublishersEndpoints(t *testing.T) {
 				assertOnlyKeys(t, firstPub, "id", "createdAt", "updatedAt", "codeHosting", "email", "description", "active", "catalogId")
 			},
 		},
+		{
+			description: "GET with full-text search",
+			query:       "GET /v1/publishers?q=27",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				require.Equal(t, 1, len(data))
+				assert.Equal(t, "15fda7c4-6bbf-4387-8f89-258c1e6facb0", data[0]["id"])
+				assert.Equal(t, "Publisher description <mark>27</mark> with alternativeId", data[0]["snippet"])
+			},
+		},
+		{
+			description: "GET with full-text search excludes inactive publishers",
+			query:       "GET /v1/publishers?q=28",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				assert.Equal(t, 0, len(data))
+			},
+		},
 		{
 			description: "GET with page[size] query param",
 			query:       "GET /v1/publishers?page[size]=2",
@@ -368,7 +396,7 @@ func TestPublishersEndpoints(t *testing.T) {
 		{
 			description: "POST publishers with duplicate URL (when normalized)",
 			query:       "POST /v1/publishers",
-			body:        `{"codeHosting": [{"url" : "https://1-a.exAMple.org/code/repo"}], "description":"new description"}`,
+			body:        `{"codeHosting": [{"url" : "https://3-a.exAMple.org/code/repo"}], "description":"new description"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
 				"Content-Type":  {"application/json"},
@@ -436,7 +464,7 @@ func TestPublishersEndpoints(t *testing.T) {
 		},
 		{
 			query: "POST /v1/publishers - Description already exist",
-			body:  `{"codeHosting": [{"url" : "https://example-testcase-xx3.com"}], "description": "Publisher description 1"}`,
+			body:  `{"codeHosting": [{"url" : "https://example-testcase-xx3.com"}], "description": "Publisher description 3"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
 				"Content-Type":  {"application/json"},
@@ -445,6 +473,21 @@ func TestPublishersEndpoints(t *testing.T) {
 			expectedContentType: "application/problem+json",
 			expectedBody:        `{"title":"can't create Publisher","detail":"description already exists","status":409}`,
 		},
+		{
+			description: "POST publisher with the description of a publisher of another catalog",
+			query:       "POST /v1/publishers",
+			body:        `{"codeHosting": [{"url" : "https://example-testcase-xx3.com"}], "description": "Publisher description 1"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "Publisher description 1", response["description"])
+				assert.NotContains(t, response, "catalogId")
+			},
+		},
 		{
 			description: "POST new publisher with no description",
 			query:       "POST /v1/publishers",
@@ -725,8 +768,8 @@ func TestPublishersEndpoints(t *testing.T) {
 		},
 		{
 			description: "PATCH a publisher with duplicate description",
-			query:       "PATCH /v1/publishers/2ded32eb-c45e-4167-9166-a44e18b8adde",
-			body:        `{"description": "Publisher description 2"}`,
+			query:       "PATCH /v1/publishers/d6ddc11a-ff85-4f0f-bb87-df38b2a9b394",
+			body:        `{"description": "Publisher description 4"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
 				"Content-Type":  {"application/json"},
@@ -1088,6 +1131,72 @@ func TestPublishersEndpoints(t *testing.T) {
 				assert.Equal(t, "invalid or malformed JSON", response["detail"])
 			},
 		},
+
+		// GET /publishers/:id/logs
+		{
+			query: "GET /v1/publishers/2ded32eb-c45e-4167-9166-a44e18b8adde/logs",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				require.Equal(t, 1, len(data))
+
+				assert.Equal(t, "/publishers/2ded32eb-c45e-4167-9166-a44e18b8adde", data[0]["entity"])
+				assertOnlyKeys(t, data[0], "id", "createdAt", "updatedAt", "message", "entity")
+
+				assertPaginationLinks(t, response, nil, nil)
+			},
+		},
+		{
+			description:         "GET logs for non existing publisher",
+			query:               "GET /v1/publishers/NO_SUCH_PUBLISHER/logs",
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Logs","detail":"Publisher was not found","status":404}`,
+		},
+
+		// POST /publishers/:id/logs
+		{
+			query: "POST /v1/publishers/47807e0c-0613-4aea-9917-5455cc6eddad/logs",
+			body:  `{"message": "New publisher log from test suite"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "New publisher log from test suite", response["message"])
+
+				assertUUID(t, response["id"])
+				assertTimestamps(t, response)
+
+				assert.Equal(t, "/publishers/47807e0c-0613-4aea-9917-5455cc6eddad", response["entity"])
+			},
+		},
+		{
+			description:         "POST publisher log - wrong token",
+			query:               "POST /v1/publishers/47807e0c-0613-4aea-9917-5455cc6eddad/logs",
+			body:                `{"message": "new log"}`,
+			headers:             map[string][]string{"Authorization": {badToken}, "Content-Type": {"application/json"}},
+			expectedCode:        401,
+			expectedBody:        `{"title":"token authentication failed","status":401}`,
+			expectedContentType: "application/problem+json",
+		},
+		{
+			description: "POST logs for non existing publisher",
+			query:       "POST /v1/publishers/NO_SUCH_PUBLISHER/logs",
+			body:        `{"message": "new log"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Log","detail":"Publisher was not found","status":404}`,
+		},
 	}
 
 	runTestCases(t, tests)
@@ -1143,11 +1252,114 @@ func TestPublishersPatchDBChecks(t *testing.T) {
 
 		assert.Equal(t, publisherID, dbValue(t, "publishers_code_hosting", "publisher_id", "url", "https://gitlab.example.org/patched-repo"))
 		assert.Equal(t, 1, dbCount(t, "publishers_code_hosting", "publisher_id", publisherID))
+
+		assert.Equal(t, 1, dbCount(t, "revisions", "entity_id", publisherID))
+
+		// The software under the removed code hosting isn't owned anymore
+		assert.Equal(t, 0, dbCount(t, "software", "publisher_id", publisherID))
+		assert.Contains(t, dbValue(t, "revisions", "data", "entity_id", publisherID), "new PATCHed description")
+	})
+
+	t.Run("POST publisher takes over the software under its code hosting", func(t *testing.T) {
+		loadFixtures(t)
+
+		const softwareID = "c353756e-8597-4e46-a99b-7da2e141603b"
+
+		body := `{"description": "Owner of a single repository", "codeHosting": [{"url": "https://1-a.example.org/code/repo", "group": false}]}`
+		req, err := newTestRequest("POST", "/v1/catalogs/"+italiaID+"/publishers", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 409, res.StatusCode, "code hosting URLs are unique in a catalog")
+
+		body = `{"description": "Owner of a subgroup", "codeHosting": [{"url": "https://1-a.example.org/code"}]}`
+		req, err = newTestRequest("POST", "/v1/catalogs/"+italiaID+"/publishers", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err = app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		// The more specific code hosting of the existing publisher still wins
+		assert.Equal(t, "2ded32eb-c45e-4167-9166-a44e18b8adde", dbValue(t, "software", "publisher_id", "id", softwareID))
+	})
+
+	t.Run("POST publisher with the code hosting of another catalog", func(t *testing.T) {
+		loadFixtures(t)
+
+		const softwareID = "c353756e-8597-4e46-a99b-7da2e141603b"
+
+		body := `{"description": "Root owner", "codeHosting": [{"url": "https://1-a.example.org/code/repo", "group": false}]}`
+		req, err := newTestRequest("POST", "/v1/publishers", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		assert.Equal(t, 2, dbCount(t, "publishers_code_hosting", "url", "https://1-a.example.org/code/repo"))
+
+		// Only the publishers in the catalog of the software own it
+		assert.Equal(t, "2ded32eb-c45e-4167-9166-a44e18b8adde", dbValue(t, "software", "publisher_id", "id", softwareID))
+	})
+
+	t.Run("PATCH publisher is readable as of its revision", func(t *testing.T) {
+		loadFixtures(t)
+
+		const publisherID = "2ded32eb-c45e-4167-9166-a44e18b8adde"
+
+		req, err := newTestRequest("PATCH", "/v1/publishers/"+publisherID, strings.NewReader(`{"description": "revised description"}`))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		asOf := url.QueryEscape(time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+
+		req, err = newTestRequest("GET", "/v1/publishers/"+publisherID+"?asOf="+asOf, nil)
+		require.NoError(t, err)
+
+		res, err = app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		var publisher map[string]interface{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&publisher))
+		assert.Equal(t, "revised description", publisher["description"])
+
+		req, err = newTestRequest("GET", "/v1/publishers/"+publisherID+"/revisions", nil)
+		require.NoError(t, err)
+
+		res, err = app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		var revisions map[string]interface{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&revisions))
+		require.Len(t, assertListResponse(t, revisions), 1)
 	})
 }
 
 func TestPublishersDeleteDBChecks(t *testing.T) {
-	t.Run("DELETE publisher removes code hosting rows", func(t *testing.T) {
+	t.Run("DELETE publisher moves it to the trash, keeping its code hosting", func(t *testing.T) {
 		loadFixtures(t)
 
 		const publisherID = "15fda7c4-6bbf-4387-8f89-258c1e6fafb1"
@@ -1165,7 +1377,10 @@ func TestPublishersDeleteDBChecks(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, 204, res.StatusCode)
 
-		assert.Equal(t, 0, dbCount(t, "publishers_code_hosting", "publisher_id", publisherID))
+		assert.Equal(t, 1, dbCount(t, "publishers", "deleted_at IS NOT NULL AND id", publisherID))
+		assert.Equal(t, 2, dbCount(t, "publishers_code_hosting", "publisher_id", publisherID))
+		assert.Equal(t, "delete", dbValue(t, "revisions", "type", "entity_id", publisherID))
+		assert.Equal(t, 0, dbCount(t, "software", "publisher_id", publisherID))
 	})
 }
 
@@ -1205,3 +1420,258 @@ func addPublishersForPaginationCapTest(t *testing.T) {
 		_, _ = db.Exec("DELETE FROM publishers WHERE description LIKE "+placeholder(1), descriptionPrefix+"%")
 	})
 }
+
+func TestPublishersTrash(t *testing.T) {
+	const (
+		publisherID         = "3c1d8e5a-7b2f-4e69-a0d4-5f8c2b9e1a73"
+		trashedCatalogPubID = "4d2e9f6b-8c3a-4f7a-b1e5-6a9d3c0f2b84"
+		softwareID          = "6f4ab18d-0e5c-4b9c-93a7-8c1f5e2b4da6"
+	)
+
+	tests := []TestCase{
+		{
+			description:         "GET publishers in the trash",
+			query:               "GET /v1/publishers?deleted=true",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				publishers := assertListResponse(t, response)
+				require.Len(t, publishers, 2)
+
+				assert.Equal(t, publisherID, publishers[0]["id"])
+				assert.Equal(t, "2021-01-01T00:00:00Z", publishers[0]["deletedAt"])
+				assert.Equal(t, trashedCatalogPubID, publishers[1]["id"])
+			},
+		},
+		{
+			description:         "GET publisher in the trash by id",
+			query:               "GET /v1/publishers/" + publisherID,
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Publisher","detail":"Publisher was not found","status":404}`,
+		},
+		{
+			description: "POST restore publisher",
+			query:       "POST /v1/publishers/" + publisherID + "/restore",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, publisherID, response["id"])
+				assert.NotContains(t, response, "deletedAt")
+
+				codeHosting := response["codeHosting"].([]interface{})
+				require.Len(t, codeHosting, 1)
+				assert.Equal(t, "https://29.example.org", codeHosting[0].(map[string]interface{})["url"])
+
+				assert.Equal(t, 0, dbCount(t, "publishers", "deleted_at IS NOT NULL AND id", publisherID))
+				assert.Equal(t, "update", dbValue(t, "events", "type", "entity_id", publisherID))
+			},
+		},
+		{
+			description: "POST restore publisher in a Catalog in the trash",
+			query:       "POST /v1/publishers/" + trashedCatalogPubID + "/restore",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't restore Publisher","detail":"Catalog is in the trash","status":409}`,
+		},
+		{
+			description: "POST restore publisher not in the trash",
+			query:       "POST /v1/publishers/2ded32eb-c45e-4167-9166-a44e18b8adde/restore",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't restore Publisher","detail":"Publisher was not found in the trash","status":404}`,
+		},
+	}
+
+	runTestCases(t, tests)
+
+	restore := func(t *testing.T, query string) map[string]interface{} {
+		t.Helper()
+
+		parts := strings.Split(query, " ")
+		req, err := newTestRequest(parts[0], parts[1], nil)
+		require.NoError(t, err)
+		req.Header = map[string][]string{"Authorization": {goodToken}}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		var resource map[string]interface{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&resource))
+
+		return resource
+	}
+
+	t.Run("POST restore publisher and then its software", func(t *testing.T) {
+		loadFixtures(t)
+
+		restore(t, "POST /v1/publishers/"+publisherID+"/restore")
+		software := restore(t, "POST /v1/software/"+softwareID+"/restore")
+
+		assert.Equal(t, publisherID, software["publisherId"])
+	})
+
+	t.Run("DELETE publisher relinks its software to the other publishers", func(t *testing.T) {
+		loadFixtures(t)
+
+		restore(t, "POST /v1/publishers/"+publisherID+"/restore")
+		restore(t, "POST /v1/software/"+softwareID+"/restore")
+
+		req, err := newTestRequest("DELETE", "/v1/publishers/"+publisherID, nil)
+		require.NoError(t, err)
+		req.Header = map[string][]string{"Authorization": {goodToken}}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 204, res.StatusCode)
+
+		assert.Equal(t, 0, dbCount(t, "software", "publisher_id", publisherID))
+
+		restore(t, "POST /v1/publishers/"+publisherID+"/restore")
+
+		assert.Equal(t, publisherID, dbValue(t, "software", "publisher_id", "id", softwareID))
+	})
+}
+
+func TestPublishersMerge(t *testing.T) {
+	const (
+		publisherID = "2ded32eb-c45e-4167-9166-a44e18b8adde"
+		duplicateID = "47807e0c-0613-4aea-9917-5455cc6eddad"
+	)
+
+	tests := []TestCase{
+		{
+			description: "POST merge publisher",
+			setupFunc: func(t *testing.T) {
+				t.Helper()
+
+				_, err := db.Exec(
+					fmt.Sprintf(
+						"INSERT INTO logs (id, message, entity_type, entity_id, created_at, updated_at) "+
+							"VALUES (%s, %s, %s, %s, %s, %s)",
+						placeholder(1), placeholder(2), placeholder(3), placeholder(4), placeholder(5), placeholder(6),
+					),
+					"0b7e2c4d-9a1f-4e3b-8c5d-6f7a8b9c0d1e", "Duplicate log", "publishers", duplicateID, time.Now(), time.Now(),
+				)
+				require.NoError(t, err)
+			},
+			query: "POST /v1/publishers/" + publisherID + "/merge",
+			body:  `{"id": "` + duplicateID + `"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, publisherID, response["id"])
+				assert.Len(t, response["codeHosting"], 4)
+
+				assert.Equal(t, 0, dbCount(t, "publishers", "id", duplicateID))
+				assert.Equal(t, 4, dbCount(t, "publishers_code_hosting", "publisher_id", publisherID))
+				assert.Equal(t, 1, dbCount(t, "webhooks", "entity_id", publisherID))
+				assert.Equal(t, 2, dbCount(t, "logs", "entity_id", publisherID))
+				assert.Equal(t, 0, dbCount(t, "logs", "entity_id", duplicateID))
+				assert.Equal(t, publisherID, dbValue(t, "redirects", "to_id", "from_id", duplicateID))
+
+				// The software under the code hosting of the duplicate, in
+				// another catalog than the publisher
+				assert.Equal(t, 1, dbCount(t, "software", "publisher_id IS NULL AND id", "9f135268-a37e-4ead-96ec-e4a24bb9344a"))
+				assert.Equal(t, 0, dbCount(t, "software", "publisher_id", duplicateID))
+				assert.Equal(t, 4, dbCount(t, "publishers_code_hosting", "catalog_id", italiaID))
+
+				assert.Equal(t, 1, dbCount(t, "events", "type = 'delete' AND entity_id", duplicateID))
+				assert.Equal(t, 1, dbCount(t, "events", "type = 'update' AND entity_id", publisherID))
+			},
+		},
+		{
+			description: "POST merge publisher by alternativeId",
+			query:       "POST /v1/publishers/" + publisherID + "/merge",
+			body:        `{"id": "alternative-id-12345"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, publisherID, response["id"])
+				assert.Len(t, response["codeHosting"], 4)
+
+				assert.Equal(t, publisherID, dbValue(t, "redirects", "to_id", "from_id", "alternative-id-12345"))
+				assert.Equal(t, publisherID, dbValue(t, "redirects", "to_id", "from_id", "15fda7c4-6bbf-4387-8f89-258c1e6facb0"))
+			},
+		},
+		{
+			description: "POST merge publisher into itself",
+			query:       "POST /v1/publishers/" + publisherID + "/merge",
+			body:        `{"id": "` + publisherID + `"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't merge Publisher","detail":"can't merge into itself","status":422}`,
+		},
+		{
+			description: "POST merge non-existent duplicate publisher",
+			query:       "POST /v1/publishers/" + publisherID + "/merge",
+			body:        `{"id": "NO_SUCH_PUBLISHER"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't merge Publisher","detail":"duplicate was not found","status":422}`,
+		},
+		{
+			description: "POST merge into non-existent publisher",
+			query:       "POST /v1/publishers/NO_SUCH_PUBLISHER/merge",
+			body:        `{"id": "` + duplicateID + `"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't merge Publisher","detail":"Publisher was not found","status":404}`,
+		},
+	}
+
+	runTestCases(t, tests)
+
+	t.Run("GET merged publisher redirects", func(t *testing.T) {
+		loadFixtures(t)
+
+		req, err := newTestRequest("POST", "/v1/publishers/"+publisherID+"/merge", strings.NewReader(`{"id": "`+duplicateID+`"}`))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		req, err = newTestRequest("GET", "/v1/publishers/"+duplicateID, nil)
+		require.NoError(t, err)
+
+		res, err = app.Test(req, -1)
+		require.NoError(t, err)
+		assert.Equal(t, 301, res.StatusCode)
+		assert.Equal(t, "/v1/publishers/"+publisherID, res.Header.Get("Location"))
+	})
+}
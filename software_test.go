@@ -3,6 +3,8 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -42,7 +44,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 
 				assert.Equal(t, true, firstSoftware["active"])
 
-				assertOnlyKeys(t, firstSoftware, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId")
+				assertOnlyKeys(t, firstSoftware, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId", "publisherId")
 			},
 		},
 		{
@@ -71,7 +73,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 				assert.Equal(t, true, firstSoftware["active"])
 
 				assertTimestamps(t, firstSoftware)
-				assertOnlyKeys(t, firstSoftware, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId")
+				assertOnlyKeys(t, firstSoftware, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId", "publisherId")
 			},
 		},
 		{
@@ -97,7 +99,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 
 				assertUUID(t, firstSoftware["id"])
 				assertTimestamps(t, firstSoftware)
-				assertOnlyKeys(t, firstSoftware, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId")
+				assertOnlyKeys(t, firstSoftware, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId", "publisherId")
 			},
 		},
 		{
@@ -126,7 +128,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 				assert.Equal(t, "2014-05-01T00:00:00Z", firstSoftware["createdAt"])
 				assert.Equal(t, "2014-05-01T00:00:00Z", firstSoftware["updatedAt"])
 
-				assertOnlyKeys(t, firstSoftware, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId")
+				assertOnlyKeys(t, firstSoftware, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId", "publisherId")
 			},
 		},
 		{
@@ -303,6 +305,275 @@ func TestSoftwareEndpoints(t *testing.T) {
 				assert.Equal(t, "invalid date time format (RFC 3339 needed)", response["detail"])
 			},
 		},
+		{
+			description: "GET with license filter",
+			query:       "GET /v1/software?license=AGPL-3.0-or-later",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				assert.Equal(t, 2, len(data))
+				assert.ElementsMatch(t, []any{
+					"c353756e-8597-4e46-a99b-7da2e141603b", "9f135268-a37e-4ead-96ec-e4a24bb9344a",
+				}, []any{data[0]["id"], data[1]["id"]})
+			},
+		},
+		{
+			description: "GET with name filter",
+			query:       "GET /v1/software?name=Medusa",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				require.Equal(t, 1, len(data))
+				assert.Equal(t, "c353756e-8597-4e46-a99b-7da2e141603b", data[0]["id"])
+			},
+		},
+		{
+			description: "GET with category filter",
+			query:       "GET /v1/software?category=crm",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				assert.Equal(t, 2, len(data))
+				assert.ElementsMatch(t, []any{
+					"9f135268-a37e-4ead-96ec-e4a24bb9344a", "18348f13-1076-4a1e-b204-ed541b824d64",
+				}, []any{data[0]["id"], data[1]["id"]})
+			},
+		},
+		{
+			description: "GET with combined publiccode filters",
+			query:       "GET /v1/software?category=cms&developmentStatus=stable&platform=web&country=it",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				assert.Equal(t, 1, len(data))
+				assert.Equal(t, "c353756e-8597-4e46-a99b-7da2e141603b", data[0]["id"])
+			},
+		},
+		{
+			description: "GET with publiccode filters matching different software",
+			query:       "GET /v1/software?category=cms&maintenanceType=none",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				assert.Equal(t, 0, len(data))
+			},
+		},
+		{
+			description: "GET with full-text search",
+			query:       "GET /v1/software?q=medusa",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				// Matches in the name rank above the ones in the description
+				require.Equal(t, 2, len(data))
+				assert.Equal(t, "c353756e-8597-4e46-a99b-7da2e141603b", data[0]["id"])
+				assert.Equal(t, "9f135268-a37e-4ead-96ec-e4a24bb9344a", data[1]["id"])
+
+				assert.Contains(t, data[0]["snippet"], "<mark>Medusa</mark>")
+				assert.Contains(t, data[1]["snippet"], "<mark>Medusa</mark>")
+			},
+		},
+		{
+			description: "GET with full-text search matching all the terms",
+			query:       "GET /v1/software?q=medusa+citizens",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				require.Equal(t, 1, len(data))
+				assert.Equal(t, "9f135268-a37e-4ead-96ec-e4a24bb9344a", data[0]["id"])
+			},
+		},
+		{
+			description: "GET with full-text search in features",
+			query:       "GET /v1/software?q=protocol",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				require.Equal(t, 1, len(data))
+				assert.Equal(t, "18348f13-1076-4a1e-b204-ed541b824d64", data[0]["id"])
+				assert.Contains(t, data[0]["snippet"], "<mark>protocol</mark>")
+			},
+		},
+		{
+			description: "GET with full-text search and filters",
+			query:       "GET /v1/software?q=medusa&developmentStatus=beta",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				require.Equal(t, 1, len(data))
+				assert.Equal(t, "9f135268-a37e-4ead-96ec-e4a24bb9344a", data[0]["id"])
+			},
+		},
+		{
+			description: "GET with full-text search paginated by relevance",
+			query:       "GET /v1/software?q=medusa&page[size]=1",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				require.Equal(t, 1, len(data))
+				assert.Equal(t, "c353756e-8597-4e46-a99b-7da2e141603b", data[0]["id"])
+
+				links := response["links"].(map[string]interface{})
+				require.IsType(t, "", links["next"])
+
+				req, err := newTestRequest("GET", "/v1/software"+links["next"].(string), nil)
+				require.NoError(t, err)
+
+				res, err := app.Test(req, -1)
+				require.NoError(t, err)
+
+				var next map[string]interface{}
+				require.NoError(t, json.NewDecoder(res.Body).Decode(&next))
+
+				data = assertListResponse(t, next)
+
+				require.Equal(t, 1, len(data))
+				assert.Equal(t, "9f135268-a37e-4ead-96ec-e4a24bb9344a", data[0]["id"])
+			},
+		},
+		{
+			description: "GET with full-text search without terms",
+			query:       `GET /v1/software?q=%22%2D`,
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				assert.Equal(t, 0, len(data))
+			},
+		},
+
+		// GET /software/facets
+		{
+			description: "GET software facets",
+			query:       "GET /v1/software/facets",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assertOnlyKeys(t, response,
+					"active", "catalog", "category", "country", "developmentStatus",
+					"license", "maintenanceType", "platform", "scope", "softwareType",
+				)
+
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{"value": "true", "count": 30.0},
+				}, response["active"])
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{"value": "AGPL-3.0-or-later", "count": 2.0},
+					map[string]interface{}{"value": "MIT", "count": 1.0},
+				}, response["license"])
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{"value": "cms", "count": 2.0},
+					map[string]interface{}{"value": "crm", "count": 2.0},
+				}, response["category"])
+			},
+		},
+		{
+			description: "GET software facets with filters",
+			query:       "GET /v1/software/facets?facets=license,category&developmentStatus=stable",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assertOnlyKeys(t, response, "license", "category")
+
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{"value": "AGPL-3.0-or-later", "count": 1.0},
+					map[string]interface{}{"value": "MIT", "count": 1.0},
+				}, response["license"])
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{"value": "cms", "count": 1.0},
+					map[string]interface{}{"value": "crm", "count": 1.0},
+				}, response["category"])
+			},
+		},
+		{
+			description: "GET software facets with all",
+			query:       "GET /v1/software/facets?facets=active,catalog&all=true",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{"value": "true", "count": 30.0},
+					map[string]interface{}{"value": "false", "count": 1.0},
+				}, response["active"])
+
+				// The root catalog has a null value
+				catalogs := response["catalog"].([]interface{})
+				require.Len(t, catalogs, 3)
+				assert.Equal(t, map[string]interface{}{"value": nil, "count": 29.0}, catalogs[0])
+			},
+		},
+		{
+			description: "GET software facets with full-text search",
+			query:       "GET /v1/software/facets?facets=category&q=medusa",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{"value": "cms", "count": 2.0},
+					map[string]interface{}{"value": "crm", "count": 1.0},
+				}, response["category"])
+			},
+		},
+		{
+			description: "GET software facets with url filter not matching",
+			query:       "GET /v1/software/facets?facets=license&url=https://no.such.url.in.db.example.org/code/repo",
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, []interface{}{}, response["license"])
+			},
+		},
+		{
+			description:         "GET software facets with unknown facet",
+			query:               "GET /v1/software/facets?facets=license,stars",
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "can't get Software facets", response["title"])
+				assert.Equal(t,
+					"unknown facet stars, must be one of active, catalog, category, country, developmentStatus, "+
+						"license, maintenanceType, platform, scope, softwareType",
+					response["detail"],
+				)
+			},
+		},
 
 		// GET /software/:id
 		{
@@ -326,7 +597,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 
 				assertUUID(t, response["id"])
 				assertTimestamps(t, response)
-				assertOnlyKeys(t, response, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId")
+				assertOnlyKeys(t, response, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId", "publisherId")
 			},
 		},
 		{
@@ -342,15 +613,17 @@ func TestSoftwareEndpoints(t *testing.T) {
 				assert.IsType(t, []interface{}{}, response["aliases"])
 				assert.Equal(t, 1, len(response["aliases"].([]interface{})))
 
+				assert.Equal(t, float64(90), response["vitality"])
+
 				assertUUID(t, response["id"])
 				assertTimestamps(t, response)
-				assertOnlyKeys(t, response, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId")
+				assertOnlyKeys(t, response, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId", "publisherId")
 			},
 		},
 
 		// POST /software
 		{
-			query: "POST /v1/software",
+			query: "POST /v1/software?validation=off",
 			body:  `{"publiccodeYml": "-", "url": "https://software.example.org"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -370,13 +643,13 @@ func TestSoftwareEndpoints(t *testing.T) {
 
 				assert.Equal(t, true, response["active"])
 
-				assertOnlyKeys(t, response, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId")
+				assertOnlyKeys(t, response, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId", "publisherId")
 
 			},
 		},
 		{
 			description: "POST software with aliases",
-			query:       "POST /v1/software",
+			query:       "POST /v1/software?validation=off",
 			body:        `{"publiccodeYml": "-", "url": "https://software.example.org", "aliases": ["https://software-1.example.org", "https://software-2.example.org"]}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -398,13 +671,13 @@ func TestSoftwareEndpoints(t *testing.T) {
 
 				assertUUID(t, response["id"])
 				assertTimestamps(t, response)
-				assertOnlyKeys(t, response, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId")
+				assertOnlyKeys(t, response, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId", "publisherId")
 
 			},
 		},
 		{
 			description: "POST software with non-normalized URL",
-			query:       "POST /v1/software",
+			query:       "POST /v1/software?validation=off",
 			body:        `{"publiccodeYml": "-", "url": "https://www.software.example.org", "aliases": ["https://www.alias.example.org/"]}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -422,33 +695,19 @@ func TestSoftwareEndpoints(t *testing.T) {
 		},
 		{
 			description: "POST software with vitality field",
-			query:       "POST /v1/software",
+			query:       "POST /v1/software?validation=off",
 			body:        `{"publiccodeYml": "-", "url": "https://software.example.net", "vitality": "90,90,90"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
 				"Content-Type":  {"application/json"},
 			},
-			expectedCode:        200,
-			expectedContentType: "application/json",
-			expectedBody:        "x",
-			validateFunc: func(t *testing.T, response map[string]interface{}) {
-				assert.Equal(t, "https://software.example.net", response["url"])
-				assert.NotEmpty(t, response["publiccodeYml"])
-
-				assert.IsType(t, []interface{}{}, response["aliases"])
-				assert.Empty(t, response["aliases"].([]interface{}))
-
-				assert.Equal(t, "90,90,90", response["vitality"])
-
-				assertUUID(t, response["id"])
-				assertTimestamps(t, response)
-				assertOnlyKeys(t, response, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality", "catalogId")
-
-			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Software","detail":"unknown field in JSON input","status":422}`,
 		},
 		{
 			description: "POST software with analysis field is rejected",
-			query:       "POST /v1/software",
+			query:       "POST /v1/software?validation=off",
 			body:        `{"publiccodeYml": "-", "url": "https://analysis.example.org", "analysis": {"badges": {"v": 1, "score": 90}}}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -462,7 +721,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 		},
 		{
 			description: "POST software with invalid payload",
-			query:       "POST /v1/software",
+			query:       "POST /v1/software?validation=off",
 			body:        `{"publiccodeYml": "-"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -474,7 +733,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 		},
 		{
 			description: "POST software - wrong token",
-			query:       "POST /v1/software",
+			query:       "POST /v1/software?validation=off",
 			body:        `{"publiccodeYml":  "-", "url": "https://software.example.org"}`,
 			headers: map[string][]string{
 				"Authorization": {badToken},
@@ -500,7 +759,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 		},
 		{
 			description: "POST /v1/software with JSON with extra fields",
-			query:       "POST /v1/software",
+			query:       "POST /v1/software?validation=off",
 			body:        `{"publiccodeYml": "-", "EXTRA_FIELD": "extra field not in schema"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -512,7 +771,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 		},
 		{
 			description: "POST software with optional boolean field set to false",
-			query:       "POST /v1/software",
+			query:       "POST /v1/software?validation=off",
 			body:        `{"active": false, "url": "https://example.org", "publiccodeYml": "-"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -526,7 +785,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 		},
 		{
 			description: "POST software with validation errors",
-			query:       "POST /v1/software",
+			query:       "POST /v1/software?validation=off",
 			body:        `{"url":"", "publiccodeYml": "-"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -565,132 +824,168 @@ func TestSoftwareEndpoints(t *testing.T) {
 				assert.Equal(t, "invalid or malformed JSON", response["detail"])
 			},
 		},
-		// PATCH /software/:id
 		{
-			description: "PATCH non-existing software",
-			query:       "PATCH /v1/software/NO_SUCH_SOFTWARE",
-			body:        ``,
+			description: "POST software with an existing URL",
+			query:       "POST /v1/software?validation=off",
+			body:        `{"publiccodeYml": "-", "url": "https://www.3-b.example.org/code/repo"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
 				"Content-Type":  {"application/json"},
 			},
-			expectedCode:        404,
-			expectedBody:        `{"title":"can't update Software","detail":"Software was not found","status":404}`,
+			expectedCode:        409,
 			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Software","detail":"url already exists","status":409}`,
 		},
+
+		// PUT /software
 		{
-			description: "PATCH a software resource",
-			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a",
-			body:        `{"publiccodeYml": "publiccodedata", "url": "https://software-new.example.org", "aliases": ["https://software.example.com", "https://software-old.example.org"]}`,
+			description: "PUT software creates it",
+			query:       "PUT /v1/software?url=https://www.upsert.example.org/&validation=off",
+			body:        `{"publiccodeYml": "-", "url": "https://upsert.example.org"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
 				"Content-Type":  {"application/json"},
 			},
-
-			expectedCode:        200,
+			expectedCode:        201,
 			expectedContentType: "application/json",
 			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "https://upsert.example.org", response["url"])
+				assert.Empty(t, response["aliases"])
+				assert.Nil(t, response["vitality"])
 				assert.Equal(t, true, response["active"])
-				assert.Equal(t, "https://software-new.example.org", response["url"])
-
-				assert.IsType(t, []interface{}{}, response["aliases"])
-
-				aliases := response["aliases"].([]interface{})
-				assert.Equal(t, 2, len(aliases))
-
-				assert.Equal(t, "https://software-old.example.org", aliases[0])
-				assert.Equal(t, "https://software.example.com", aliases[1])
-
-				assert.Equal(t, "publiccodedata", response["publiccodeYml"])
+				assert.NotContains(t, response, "catalogId")
 
 				assertUUID(t, response["id"])
-
-				created := assertRFC3339(t, response["createdAt"])
-				updated := assertRFC3339(t, response["updatedAt"])
-
-				assert.Greater(t, updated, created)
+				assertTimestamps(t, response)
+				assertOnlyKeys(t, response, "id", "createdAt", "updatedAt", "url", "aliases", "publiccodeYml", "active", "vitality")
 			},
 		},
 		{
-			description: "PATCH software with no aliases (should leave current aliases untouched)",
-			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a",
-			body:        `{"publiccodeYml": "publiccodedata", "url": "https://software-new.example.org"}`,
+			description: "PUT software updates the one with the URL as alias",
+			query:       "PUT /v1/software?url=https://1-b.example.org/code/repo&validation=off",
+			body:        `{"publiccodeYml": "name: Medusa", "url": "https://1-b.example.org/code/repo", "aliases": ["https://1-a.example.org/code/repo"], "active": false}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
 				"Content-Type":  {"application/json"},
 			},
-
 			expectedCode:        200,
 			expectedContentType: "application/json",
-			expectedBody:        "",
 			validateFunc: func(t *testing.T, response map[string]interface{}) {
-				assert.Equal(t, true, response["active"])
-				assert.Equal(t, "https://software-new.example.org", response["url"])
-
-				assert.IsType(t, []interface{}{}, response["aliases"])
-
-				aliases := response["aliases"].([]interface{})
-				assert.Equal(t, 1, len(aliases))
-
-				assert.Equal(t, "https://18-b.example.org/code/repo", aliases[0])
-
-				assert.Equal(t, "publiccodedata", response["publiccodeYml"])
-
-				assertUUID(t, response["id"])
-
-				created := assertRFC3339(t, response["createdAt"])
-				updated := assertRFC3339(t, response["updatedAt"])
-
-				assert.Greater(t, updated, created)
+				assert.Equal(t, "c353756e-8597-4e46-a99b-7da2e141603b", response["id"])
+				assert.Equal(t, "https://1-b.example.org/code/repo", response["url"])
+				assert.Equal(t, []interface{}{"https://1-a.example.org/code/repo"}, response["aliases"])
+				assert.Equal(t, "name: Medusa", response["publiccodeYml"])
+				assert.Equal(t, false, response["active"])
+				assert.Equal(t, "a8e5e6d7-0b1c-4f2a-8e3d-9c4b5a6f7e8d", response["catalogId"])
 			},
 		},
 		{
-			description: "PATCH software with empty aliases (should remove aliases)",
-			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a",
-			body:        `{"publiccodeYml": "publiccodedata", "url": "https://software-new.example.org", "aliases": []}`,
+			description: "PUT software keeps vitality when omitted",
+			query:       "PUT /v1/software?url=https://2-a.example.org/code/repo&validation=off",
+			body:        `{"publiccodeYml": "-", "url": "https://2-a.example.org/code/repo"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
 				"Content-Type":  {"application/json"},
 			},
-
 			expectedCode:        200,
 			expectedContentType: "application/json",
 			validateFunc: func(t *testing.T, response map[string]interface{}) {
-				assert.Equal(t, true, response["active"])
-				assert.Equal(t, "https://software-new.example.org", response["url"])
-
-				assert.IsType(t, []interface{}{}, response["aliases"])
-
-				aliases := response["aliases"].([]interface{})
-				assert.Equal(t, 0, len(aliases))
-
-				assert.Equal(t, "publiccodedata", response["publiccodeYml"])
-
-				assertUUID(t, response["id"])
-
-				created := assertRFC3339(t, response["createdAt"])
-				updated := assertRFC3339(t, response["updatedAt"])
-
-				assert.Greater(t, updated, created)
+				assert.Equal(t, "9f135268-a37e-4ead-96ec-e4a24bb9344a", response["id"])
+				assert.Equal(t, float64(90), response["vitality"])
 			},
 		},
 		{
-			description: "PATCH software with an already existing URL (of another software)",
-			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a",
-			body:        `{"publiccodeYml": "publiccodedata", "url": "https://21-b.example.org/code/repo"}`,
+			description: "PUT software with an alias of other software",
+			query:       "PUT /v1/software?url=https://upsert.example.org&validation=off",
+			body:        `{"publiccodeYml": "-", "url": "https://upsert.example.org", "aliases": ["https://3-b.example.org/code/repo"]}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
 				"Content-Type":  {"application/json"},
 			},
-
 			expectedCode:        409,
 			expectedContentType: "application/problem+json",
-			expectedBody:        `{"title":"can't update Software","detail":"url already exists","status":409}`,
+			expectedBody:        `{"title":"can't create or update Software","detail":"url already exists","status":409}`,
 		},
 		{
-			description: "PATCH software, change active",
-			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a",
-			body:        `{"active": false}`,
+			description: "PUT software without url",
+			query:       "PUT /v1/software?validation=off",
+			body:        `{"publiccodeYml": "-", "url": "https://upsert.example.org"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create or update Software","detail":"url is required","status":422}`,
+		},
+		{
+			description: "PUT software with url not in the body",
+			query:       "PUT /v1/software?url=https://other.example.org&validation=off",
+			body:        `{"publiccodeYml": "-", "url": "https://upsert.example.org", "aliases": ["https://alias.example.org"]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create or update Software","detail":"url must be the url or one of the aliases of the body","status":422}`,
+		},
+		{
+			description: "PUT software with url as alias of the body",
+			query:       "PUT /v1/software?url=https://www.alias.example.org/&validation=off",
+			body:        `{"publiccodeYml": "-", "url": "https://upsert.example.org", "aliases": ["https://alias.example.org"]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        201,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "https://upsert.example.org", response["url"])
+			},
+		},
+		{
+			description: "PUT software with invalid payload",
+			query:       "PUT /v1/software?url=https://upsert.example.org&validation=off",
+			body:        `{"publiccodeYml": "-"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create or update Software","detail":"invalid format: url is required","status":422,"validationErrors":[{"field":"url","rule":"required","value":""}]}`,
+		},
+		{
+			description: "PUT software - wrong token",
+			query:       "PUT /v1/software?url=https://upsert.example.org",
+			body:        `{"publiccodeYml": "-", "url": "https://upsert.example.org"}`,
+			headers: map[string][]string{
+				"Authorization": {badToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        401,
+			expectedBody:        `{"title":"token authentication failed","status":401}`,
+			expectedContentType: "application/problem+json",
+		},
+
+		// PATCH /software/:id
+		{
+			description: "PATCH non-existing software",
+			query:       "PATCH /v1/software/NO_SUCH_SOFTWARE",
+			body:        ``,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        404,
+			expectedBody:        `{"title":"can't update Software","detail":"Software was not found","status":404}`,
+			expectedContentType: "application/problem+json",
+		},
+		{
+			description: "PATCH a software resource",
+			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a?validation=off",
+			body:        `{"publiccodeYml": "publiccodedata", "url": "https://software-new.example.org", "aliases": ["https://software.example.com", "https://software-old.example.org"]}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
 				"Content-Type":  {"application/json"},
@@ -699,8 +994,42 @@ func TestSoftwareEndpoints(t *testing.T) {
 			expectedCode:        200,
 			expectedContentType: "application/json",
 			validateFunc: func(t *testing.T, response map[string]interface{}) {
-				assert.Equal(t, false, response["active"])
-				assert.Equal(t, "https://18-a.example.org/code/repo", response["url"])
+				assert.Equal(t, true, response["active"])
+				assert.Equal(t, "https://software-new.example.org", response["url"])
+
+				assert.IsType(t, []interface{}{}, response["aliases"])
+
+				aliases := response["aliases"].([]interface{})
+				assert.Equal(t, 2, len(aliases))
+
+				assert.Equal(t, "https://software-old.example.org", aliases[0])
+				assert.Equal(t, "https://software.example.com", aliases[1])
+
+				assert.Equal(t, "publiccodedata", response["publiccodeYml"])
+
+				assertUUID(t, response["id"])
+
+				created := assertRFC3339(t, response["createdAt"])
+				updated := assertRFC3339(t, response["updatedAt"])
+
+				assert.Greater(t, updated, created)
+			},
+		},
+		{
+			description: "PATCH software with no aliases (should leave current aliases untouched)",
+			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a?validation=off",
+			body:        `{"publiccodeYml": "publiccodedata", "url": "https://software-new.example.org"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			expectedBody:        "",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, true, response["active"])
+				assert.Equal(t, "https://software-new.example.org", response["url"])
 
 				assert.IsType(t, []interface{}{}, response["aliases"])
 
@@ -709,7 +1038,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 
 				assert.Equal(t, "https://18-b.example.org/code/repo", aliases[0])
 
-				assert.Equal(t, "-", response["publiccodeYml"])
+				assert.Equal(t, "publiccodedata", response["publiccodeYml"])
 
 				assertUUID(t, response["id"])
 
@@ -720,9 +1049,52 @@ func TestSoftwareEndpoints(t *testing.T) {
 			},
 		},
 		{
-			description: "PATCH software, switch url and alias",
+			description: "PATCH software with empty aliases (should remove aliases)",
+			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a?validation=off",
+			body:        `{"publiccodeYml": "publiccodedata", "url": "https://software-new.example.org", "aliases": []}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, true, response["active"])
+				assert.Equal(t, "https://software-new.example.org", response["url"])
+
+				assert.IsType(t, []interface{}{}, response["aliases"])
+
+				aliases := response["aliases"].([]interface{})
+				assert.Equal(t, 0, len(aliases))
+
+				assert.Equal(t, "publiccodedata", response["publiccodeYml"])
+
+				assertUUID(t, response["id"])
+
+				created := assertRFC3339(t, response["createdAt"])
+				updated := assertRFC3339(t, response["updatedAt"])
+
+				assert.Greater(t, updated, created)
+			},
+		},
+		{
+			description: "PATCH software with an already existing URL (of another software)",
+			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a?validation=off",
+			body:        `{"publiccodeYml": "publiccodedata", "url": "https://21-b.example.org/code/repo"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't update Software","detail":"url already exists","status":409}`,
+		},
+		{
+			description: "PATCH software, change active",
 			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a",
-			body:        `{"url": "https://18-b.example.org/code/repo", "aliases": ["https://18-a.example.org/code/repo"]}`,
+			body:        `{"active": false}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
 				"Content-Type":  {"application/json"},
@@ -731,14 +1103,15 @@ func TestSoftwareEndpoints(t *testing.T) {
 			expectedCode:        200,
 			expectedContentType: "application/json",
 			validateFunc: func(t *testing.T, response map[string]interface{}) {
-				assert.Equal(t, "https://18-b.example.org/code/repo", response["url"])
+				assert.Equal(t, false, response["active"])
+				assert.Equal(t, "https://18-a.example.org/code/repo", response["url"])
 
 				assert.IsType(t, []interface{}{}, response["aliases"])
 
 				aliases := response["aliases"].([]interface{})
 				assert.Equal(t, 1, len(aliases))
 
-				assert.Equal(t, "https://18-a.example.org/code/repo", aliases[0])
+				assert.Equal(t, "https://18-b.example.org/code/repo", aliases[0])
 
 				assert.Equal(t, "-", response["publiccodeYml"])
 
@@ -751,9 +1124,9 @@ func TestSoftwareEndpoints(t *testing.T) {
 			},
 		},
 		{
-			description: "PATCH software, vitality",
+			description: "PATCH software, switch url and alias",
 			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a",
-			body:        `{"vitality": "80,90,99"}`,
+			body:        `{"url": "https://18-b.example.org/code/repo", "aliases": ["https://18-a.example.org/code/repo"]}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
 				"Content-Type":  {"application/json"},
@@ -762,18 +1135,16 @@ func TestSoftwareEndpoints(t *testing.T) {
 			expectedCode:        200,
 			expectedContentType: "application/json",
 			validateFunc: func(t *testing.T, response map[string]interface{}) {
-				assert.Equal(t, true, response["active"])
-				assert.Equal(t, "https://18-a.example.org/code/repo", response["url"])
+				assert.Equal(t, "https://18-b.example.org/code/repo", response["url"])
 
 				assert.IsType(t, []interface{}{}, response["aliases"])
 
 				aliases := response["aliases"].([]interface{})
 				assert.Equal(t, 1, len(aliases))
 
-				assert.Equal(t, "https://18-b.example.org/code/repo", aliases[0])
+				assert.Equal(t, "https://18-a.example.org/code/repo", aliases[0])
 
 				assert.Equal(t, "-", response["publiccodeYml"])
-				assert.Equal(t, "80,90,99", response["vitality"])
 
 				assertUUID(t, response["id"])
 
@@ -784,8 +1155,21 @@ func TestSoftwareEndpoints(t *testing.T) {
 			},
 		},
 		{
-			description: "PATCH a software resource with JSON Patch - replace",
+			description: "PATCH software, vitality",
 			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a",
+			body:        `{"vitality": 80}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't update Software","detail":"unknown field in JSON input","status":422}`,
+		},
+		{
+			description: "PATCH a software resource with JSON Patch - replace",
+			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a?validation=off",
 			body:        `[{"op": "replace", "path": "/publiccodeYml", "value": "new publiccode data"}]`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -817,7 +1201,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 		{
 			description: "PATCH a software resource with JSON Patch - replace vitality",
 			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a",
-			body:        `[{"op": "replace", "path": "/vitality", "value": "10,11"}]`,
+			body:        `[{"op": "replace", "path": "/vitality", "value": 10}]`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
 				"Content-Type":  {"application/json-patch+json"},
@@ -828,7 +1212,9 @@ func TestSoftwareEndpoints(t *testing.T) {
 			validateFunc: func(t *testing.T, response map[string]interface{}) {
 				assert.Equal(t, true, response["active"])
 				assert.Equal(t, "https://18-a.example.org/code/repo", response["url"])
-				assert.Equal(t, "10,11", response["vitality"])
+
+				// The vitality is computed from its data points
+				assert.Nil(t, response["vitality"])
 
 				assert.IsType(t, []interface{}{}, response["aliases"])
 
@@ -881,7 +1267,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 		},
 		{
 			description: "PATCH a software resource with JSON Patch as Content-Type, but non JSON Patch payload",
-			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a",
+			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a?validation=off",
 			body:        `{"publiccodeYml": "publiccodedata", "url": "https://software-new.example.org"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -1001,7 +1387,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 		},
 		{
 			description: "PATCH software with JSON with extra fields",
-			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a",
+			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a?validation=off",
 			body:        `{"publiccodeYml": "-", "EXTRA_FIELD": "extra field not in schema"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -1013,7 +1399,7 @@ func TestSoftwareEndpoints(t *testing.T) {
 		},
 		{
 			description: "PATCH software with validation errors",
-			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a",
+			query:       "PATCH /v1/software/59803fb7-8eec-4fe5-a354-8926009c364a?validation=off",
 			body:        `{"url": "INVALID_URL", "publiccodeYml": "-"}`,
 			headers: map[string][]string{
 				"Authorization": {goodToken},
@@ -1465,7 +1851,7 @@ func TestSoftwarePostDBChecks(t *testing.T) {
 		loadFixtures(t)
 
 		body := `{"publiccodeYml":"persisted-publiccode","url":"https://www.software-dbcheck.example.org/","aliases":["https://www.alias-one.example.org/","https://alias-two.example.org"]}`
-		req, err := newTestRequest("POST", "/v1/software", strings.NewReader(body))
+		req, err := newTestRequest("POST", "/v1/software?validation=off", strings.NewReader(body))
 		require.NoError(t, err)
 		req.Header = map[string][]string{
 			"Authorization": {goodToken},
@@ -1498,6 +1884,52 @@ func TestSoftwarePostDBChecks(t *testing.T) {
 	})
 }
 
+func TestSoftwarePutDBChecks(t *testing.T) {
+	const softwareID = "c353756e-8597-4e46-a99b-7da2e141603b"
+
+	put := func(t *testing.T, url string, body string) int {
+		t.Helper()
+
+		req, err := newTestRequest("PUT", "/v1/software?validation=off&url="+url, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+
+		return res.StatusCode
+	}
+
+	t.Run("PUT software twice creates it once", func(t *testing.T) {
+		loadFixtures(t)
+
+		body := `{"publiccodeYml": "-", "url": "https://upsert.example.org", "aliases": ["https://old.example.org"]}`
+
+		assert.Equal(t, 201, put(t, "https://upsert.example.org", body))
+		assert.Equal(t, 200, put(t, "https://old.example.org", body))
+
+		id := dbValue(t, "software_urls", "software_id", "url", "https://upsert.example.org")
+		assert.Equal(t, 2, dbCount(t, "software_urls", "software_id", id))
+		assert.Equal(t, 1, dbCount(t, "revisions", "entity_id", id), "nothing changed, no new revision")
+	})
+
+	t.Run("PUT software replaces URL and aliases", func(t *testing.T) {
+		loadFixtures(t)
+
+		body := `{"publiccodeYml": "-", "url": "https://moved.example.org/code/repo", "aliases": ["https://1-a.example.org/code/repo"]}`
+
+		assert.Equal(t, 200, put(t, "https://1-a.example.org/code/repo", body))
+
+		assert.Equal(t, softwareID, dbValue(t, "software_urls", "software_id", "url", "https://moved.example.org/code/repo"))
+		assert.Equal(t, 2, dbCount(t, "software_urls", "software_id", softwareID))
+		assert.Equal(t, 0, dbCount(t, "software_urls", "url", "https://1-b.example.org/code/repo"))
+		assert.Equal(t, 3, dbCount(t, "revisions", "entity_id", softwareID))
+	})
+}
+
 func TestSoftwarePatchDBChecks(t *testing.T) {
 	t.Run("PATCH software persists changes to DB", func(t *testing.T) {
 		loadFixtures(t)
@@ -1505,7 +1937,7 @@ func TestSoftwarePatchDBChecks(t *testing.T) {
 		const softwareID = "59803fb7-8eec-4fe5-a354-8926009c364a"
 
 		body := `{"publiccodeYml": "publiccodedata", "url": "https://software-new.example.org", "aliases": ["https://software.example.com", "https://software-old.example.org"]}`
-		req, err := newTestRequest("PATCH", "/v1/software/"+softwareID, strings.NewReader(body))
+		req, err := newTestRequest("PATCH", "/v1/software/"+softwareID+"?validation=off", strings.NewReader(body))
 		require.NoError(t, err)
 		req.Header = map[string][]string{
 			"Authorization": {goodToken},
@@ -1686,7 +2118,7 @@ func TestSoftwareAnalysisDBChecks(t *testing.T) {
 }
 
 func TestSoftwareDeleteDBChecks(t *testing.T) {
-	t.Run("DELETE software removes software_urls rows", func(t *testing.T) {
+	t.Run("DELETE software moves it to the trash, keeping its software_urls", func(t *testing.T) {
 		loadFixtures(t)
 
 		const softwareID = "11e101c4-f989-4cc4-a665-63f9f34e83f6"
@@ -1704,7 +2136,8 @@ func TestSoftwareDeleteDBChecks(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, 204, res.StatusCode)
 
-		assert.Equal(t, 0, dbCount(t, "software_urls", "software_id", softwareID))
+		assert.Equal(t, 1, dbCount(t, "software", "deleted_at IS NOT NULL AND id", softwareID))
+		assert.Equal(t, 2, dbCount(t, "software_urls", "software_id", softwareID))
 	})
 }
 
@@ -1751,3 +2184,1205 @@ func addSoftwareForPaginationCapTest(t *testing.T) {
 		_, _ = db.Exec("DELETE FROM software WHERE id LIKE "+placeholder(1), "11111111-1111-1111-1111-%")
 	})
 }
+
+func TestSoftwarePubliccodeValidation(t *testing.T) {
+	valid, err := os.ReadFile("internal/publiccode/testdata/valid.yml")
+	require.NoError(t, err)
+
+	invalid := strings.Replace(string(valid), "developmentStatus: stable", "developmentStatus: done", 1)
+	statusProblem := `developmentStatus must be one of the following: ` +
+		`"concept", "development", "beta", "stable" or "obsolete"`
+
+	legacy := strings.Replace(string(valid), `publiccodeYmlVersion: "0"`, `publiccodeYmlVersion: "0.2"`, 1)
+
+	request := func(t *testing.T, method, path string, fields map[string]any) (int, map[string]any, []string) {
+		t.Helper()
+
+		body, err := json.Marshal(fields)
+		require.NoError(t, err)
+
+		req, err := newTestRequest(method, path, strings.NewReader(string(body)))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+
+		var response map[string]any
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+
+		return res.StatusCode, response, res.Header.Values("Warning")
+	}
+
+	t.Run("POST valid publiccodeYml", func(t *testing.T) {
+		loadFixtures(t)
+
+		code, _, warnings := request(t, "POST", "/v1/software", map[string]any{
+			"url": "https://valid.example.org/repo", "publiccodeYml": string(valid),
+		})
+
+		assert.Equal(t, 200, code)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("POST invalid publiccodeYml", func(t *testing.T) {
+		loadFixtures(t)
+
+		code, response, _ := request(t, "POST", "/v1/software", map[string]any{
+			"url": "https://invalid.example.org/repo", "publiccodeYml": invalid,
+		})
+
+		assert.Equal(t, 422, code)
+		assert.Equal(t, "invalid format: publiccodeYml.developmentStatus: "+statusProblem, response["detail"])
+		assert.Equal(t, []any{
+			map[string]any{"field": "publiccodeYml.developmentStatus", "rule": "publiccode", "value": statusProblem},
+		}, response["validationErrors"])
+		assert.Zero(t, dbCount(t, "software_urls", "url", "https://invalid.example.org/repo"))
+	})
+
+	t.Run("POST publiccodeYml that isn't YAML", func(t *testing.T) {
+		loadFixtures(t)
+
+		code, response, _ := request(t, "POST", "/v1/software", map[string]any{
+			"url": "https://garbage.example.org/repo", "publiccodeYml": "-",
+		})
+
+		assert.Equal(t, 422, code)
+		assert.Equal(t,
+			"invalid format: publiccodeYml.publiccodeYmlVersion: publiccodeYmlVersion is a required field",
+			response["detail"],
+		)
+	})
+
+	t.Run("POST legacy publiccodeYml returns a warning", func(t *testing.T) {
+		loadFixtures(t)
+
+		code, _, warnings := request(t, "POST", "/v1/software", map[string]any{
+			"url": "https://legacy.example.org/repo", "publiccodeYml": legacy,
+		})
+
+		assert.Equal(t, 200, code)
+		assert.Equal(t, []string{
+			`299 - "invalid format: publiccodeYml.publiccodeYmlVersion: ` +
+				`v0.2 is not the latest version, use '0'. Parsing this file as v0.5."`,
+		}, warnings)
+	})
+
+	t.Run("POST invalid publiccodeYml with validation=warn", func(t *testing.T) {
+		loadFixtures(t)
+
+		code, response, warnings := request(t, "POST", "/v1/software?validation=warn", map[string]any{
+			"url": "https://warn.example.org/repo", "publiccodeYml": invalid,
+		})
+
+		assert.Equal(t, 200, code)
+		assert.Equal(t, invalid, response["publiccodeYml"])
+		assert.Equal(t, []string{
+			`299 - "invalid format: publiccodeYml.developmentStatus: ` + strings.ReplaceAll(statusProblem, `"`, `'`) + `"`,
+		}, warnings)
+	})
+
+	t.Run("POST with invalid validation mode", func(t *testing.T) {
+		loadFixtures(t)
+
+		code, response, _ := request(t, "POST", "/v1/software?validation=lenient", map[string]any{
+			"url": "https://mode.example.org/repo", "publiccodeYml": string(valid),
+		})
+
+		assert.Equal(t, 422, code)
+		assert.Equal(t, "validation must be one of strict, warn or off", response["detail"])
+	})
+
+	t.Run("POST stores the publiccodeYml fields", func(t *testing.T) {
+		loadFixtures(t)
+
+		code, response, _ := request(t, "POST", "/v1/software", map[string]any{
+			"url": "https://fields.example.org/repo", "publiccodeYml": string(valid),
+		})
+		require.Equal(t, 200, code)
+
+		id := response["id"].(string)
+
+		assert.Equal(t, "Medusa", dbValue(t, "software", "name", "id", id))
+		assert.Equal(t, "AGPL-3.0-or-later", dbValue(t, "software", "license", "id", id))
+		assert.Equal(t, "community", dbValue(t, "software", "maintenance_type", "id", id))
+		assert.Equal(t, 1, dbCount(t, "software_descriptions", "software_id", id))
+		assert.Equal(t, 4, dbCount(t, "software_terms", "software_id", id))
+
+		code, response, _ = request(t, "GET", "/v1/software?category=cloud-management&scope=government", nil)
+		require.Equal(t, 200, code)
+
+		data := response["data"].([]any)
+		require.Len(t, data, 1)
+		assert.Equal(t, id, data[0].(map[string]any)["id"])
+	})
+
+	t.Run("PATCH replaces the publiccodeYml fields", func(t *testing.T) {
+		loadFixtures(t)
+
+		const softwareID = "c353756e-8597-4e46-a99b-7da2e141603b"
+
+		code, _, _ := request(t, "PATCH", "/v1/software/"+softwareID, map[string]any{"publiccodeYml": string(valid)})
+		require.Equal(t, 200, code)
+
+		assert.Equal(t, "stable", dbValue(t, "software", "development_status", "id", softwareID))
+		assert.Equal(t, 1, dbCount(t, "software_descriptions", "software_id", softwareID))
+		assert.Equal(t, 4, dbCount(t, "software_terms", "software_id", softwareID))
+
+		code, response, _ := request(t, "GET", "/v1/software?category=cms", nil)
+		require.Equal(t, 200, code)
+
+		data := response["data"].([]any)
+		require.Len(t, data, 1)
+		assert.Equal(t, "9f135268-a37e-4ead-96ec-e4a24bb9344a", data[0].(map[string]any)["id"])
+	})
+
+	t.Run("PATCH invalid publiccodeYml", func(t *testing.T) {
+		loadFixtures(t)
+
+		const softwareID = "59803fb7-8eec-4fe5-a354-8926009c364a"
+
+		code, _, _ := request(t, "PATCH", "/v1/software/"+softwareID, map[string]any{"publiccodeYml": invalid})
+
+		assert.Equal(t, 422, code)
+		assert.Equal(t, "-", dbValue(t, "software", "publiccode_yml", "id", softwareID))
+	})
+
+	t.Run("PATCH without changing publiccodeYml skips validation", func(t *testing.T) {
+		loadFixtures(t)
+
+		const softwareID = "59803fb7-8eec-4fe5-a354-8926009c364a"
+
+		code, _, _ := request(t, "PATCH", "/v1/software/"+softwareID, map[string]any{"active": false})
+
+		assert.Equal(t, 200, code)
+	})
+}
+
+func TestSoftwareRevisions(t *testing.T) {
+	const (
+		softwareID        = "c353756e-8597-4e46-a99b-7da2e141603b"
+		deletedSoftwareID = "0b8f3c2a-6e1d-4f7a-9c5b-2d4e6f8a0b1c"
+	)
+
+	tests := []TestCase{
+		{
+			description:         "GET software revisions",
+			query:               "GET /v1/software/" + softwareID + "/revisions",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				revisions := assertListResponse(t, response)
+				require.Len(t, revisions, 2)
+
+				assertOnlyKeys(t, revisions[0], "version", "type", "data", "createdAt")
+				assert.Equal(t, 2.0, revisions[0]["version"])
+				assert.Equal(t, "update", revisions[0]["type"])
+				assert.Equal(t, 1.0, revisions[1]["version"])
+				assert.Equal(t, "create", revisions[1]["type"])
+
+				data := revisions[0]["data"].(map[string]interface{})
+				assert.Equal(t, []interface{}{"https://1-b.example.org/code/repo"}, data["aliases"])
+			},
+		},
+		{
+			description:         "GET software revisions of a deleted software",
+			query:               "GET /v1/software/" + deletedSoftwareID + "/revisions",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				revisions := assertListResponse(t, response)
+				require.Len(t, revisions, 2)
+
+				assert.Equal(t, "delete", revisions[0]["type"])
+				assert.Nil(t, revisions[0]["data"])
+			},
+		},
+		{
+			description:         "GET software revisions of a software without revisions",
+			query:               "GET /v1/software/9f135268-a37e-4ead-96ec-e4a24bb9344a/revisions",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Empty(t, assertListResponse(t, response))
+			},
+		},
+		{
+			description:         "GET software revisions of a non-existent software",
+			query:               "GET /v1/software/NO_SUCH_SOFTWARE/revisions",
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Revisions","detail":"resource was not found","status":404}`,
+		},
+
+		{
+			description:         "GET software revisions diff",
+			query:               "GET /v1/software/" + softwareID + "/revisions/diff",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, 1.0, response["from"])
+				assert.Equal(t, 2.0, response["to"])
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{"op": "add", "path": "/aliases/0", "to": "https://1-b.example.org/code/repo"},
+					map[string]interface{}{
+						"op":   "replace",
+						"path": "/publiccodeYml/legal/license",
+						"from": "MIT",
+						"to":   "AGPL-3.0-or-later",
+					},
+					map[string]interface{}{
+						"op":   "replace",
+						"path": "/updatedAt",
+						"from": "2014-05-01T00:00:00Z",
+						"to":   "2014-07-01T00:00:00Z",
+					},
+				}, response["changes"])
+			},
+		},
+		{
+			description:         "GET software revisions diff from creation",
+			query:               "GET /v1/software/" + softwareID + "/revisions/diff?from=0&to=1",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				changes := response["changes"].([]interface{})
+				require.Len(t, changes, 1)
+
+				change := changes[0].(map[string]interface{})
+				assert.Equal(t, "add", change["op"])
+				assert.Equal(t, "", change["path"])
+			},
+		},
+		{
+			description:         "GET software revisions diff with invalid to",
+			query:               "GET /v1/software/" + softwareID + "/revisions/diff?to=last",
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Revisions diff","detail":"to must be a positive integer","status":422}`,
+		},
+		{
+			description:         "GET software revisions diff with non-existent revision",
+			query:               "GET /v1/software/" + softwareID + "/revisions/diff?from=1&to=3",
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Revisions diff","detail":"Revision was not found","status":404}`,
+		},
+
+		{
+			description:         "GET software as of a time",
+			query:               "GET /v1/software/" + softwareID + "?asOf=2014-06-01T00:00:00Z",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, softwareID, response["id"])
+				assert.Equal(t, "name: Medusa\nlegal:\n  license: MIT\n", response["publiccodeYml"])
+				assert.Equal(t, []interface{}{}, response["aliases"])
+			},
+		},
+		{
+			description:         "GET software as of a time before it was created",
+			query:               "GET /v1/software/" + softwareID + "?asOf=2014-01-01T00:00:00Z",
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Software","detail":"Software was not found","status":404}`,
+		},
+		{
+			description:         "GET deleted software as of a time before it was deleted",
+			query:               "GET /v1/software/" + deletedSoftwareID + "?asOf=2014-03-15T00:00:00Z",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "https://deleted.example.org/code/repo", response["url"])
+			},
+		},
+		{
+			description:         "GET deleted software as of a time after it was deleted",
+			query:               "GET /v1/software/" + deletedSoftwareID + "?asOf=2014-05-15T00:00:00Z",
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Software","detail":"Software was not found","status":404}`,
+		},
+		{
+			description:         "GET software as of an invalid time",
+			query:               "GET /v1/software/" + softwareID + "?asOf=yesterday",
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Software","detail":"asOf must be a RFC 3339 timestamp","status":422}`,
+		},
+	}
+
+	runTestCases(t, tests)
+
+	t.Run("PATCH and DELETE software append revisions", func(t *testing.T) {
+		loadFixtures(t)
+
+		const softwareID = "59803fb7-8eec-4fe5-a354-8926009c364a"
+
+		req, err := newTestRequest("PATCH", "/v1/software/"+softwareID, strings.NewReader(`{"active": false}`))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		assert.Equal(t, 1, dbCount(t, "revisions", "entity_id", softwareID))
+		assert.Equal(t, "update", dbValue(t, "revisions", "type", "entity_id", softwareID))
+		assert.Contains(t, dbValue(t, "revisions", "data", "entity_id", softwareID), `"active":false`)
+
+		req, err = newTestRequest("DELETE", "/v1/software/"+softwareID, nil)
+		require.NoError(t, err)
+		req.Header = map[string][]string{"Authorization": {goodToken}}
+
+		res, err = app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 204, res.StatusCode)
+
+		assert.Equal(t, 2, dbCount(t, "revisions", "entity_id", softwareID))
+	})
+}
+
+func TestSoftwarePublisherLinks(t *testing.T) {
+	const (
+		publisherID = "2ded32eb-c45e-4167-9166-a44e18b8adde"
+		softwareID  = "c353756e-8597-4e46-a99b-7da2e141603b"
+	)
+
+	tests := []TestCase{
+		{
+			description:         "GET software of a publisher",
+			query:               "GET /v1/publishers/" + publisherID + "/software",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				software := assertListResponse(t, response)
+				require.Len(t, software, 1)
+
+				assert.Equal(t, softwareID, software[0]["id"])
+				assert.Equal(t, publisherID, software[0]["publisherId"])
+				assert.Equal(t, "https://1-a.example.org/code/repo", software[0]["url"])
+				assert.Equal(t, []interface{}{"https://1-b.example.org/code/repo"}, software[0]["aliases"])
+			},
+		},
+		{
+			description:         "GET software of a non-existent publisher",
+			query:               "GET /v1/publishers/NO_SUCH_PUBLISHER/software",
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Software","detail":"Publisher was not found","status":404}`,
+		},
+		{
+			description:         "GET software with publisher filter",
+			query:               "GET /v1/software?publisher=" + publisherID,
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				software := assertListResponse(t, response)
+				require.Len(t, software, 1)
+
+				assert.Equal(t, softwareID, software[0]["id"])
+			},
+		},
+		{
+			description:         "GET software with publisher filter not matching",
+			query:               "GET /v1/software?publisher=47807e0c-0613-4aea-9917-5455cc6eddad",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Empty(t, assertListResponse(t, response))
+			},
+		},
+	}
+
+	runTestCases(t, tests)
+
+	post := func(t *testing.T, url string) map[string]interface{} {
+		t.Helper()
+
+		body := `{"publiccodeYml": "-", "url": "` + url + `"}`
+		req, err := newTestRequest("POST", "/v1/catalogs/"+italiaID+"/software?validation=off", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		var software map[string]interface{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&software))
+
+		return software
+	}
+
+	t.Run("POST software under a group code hosting", func(t *testing.T) {
+		loadFixtures(t)
+
+		software := post(t, "https://1-a.example.org/code/repo/sub")
+
+		assert.Equal(t, publisherID, software["publisherId"])
+		assert.Equal(t, publisherID, dbValue(t, "software", "publisher_id", "id", software["id"].(string)))
+	})
+
+	t.Run("POST software under a single repository code hosting", func(t *testing.T) {
+		loadFixtures(t)
+
+		software := post(t, "https://1-b.example.org/code/repo/sub")
+
+		assert.NotContains(t, software, "publisherId")
+	})
+
+	t.Run("POST software under the code hosting of another catalog", func(t *testing.T) {
+		loadFixtures(t)
+
+		software := post(t, "https://2-a.example.org/code/repo/sub")
+
+		assert.NotContains(t, software, "publisherId")
+	})
+
+	t.Run("PATCH software aliases relinks it", func(t *testing.T) {
+		loadFixtures(t)
+
+		software := post(t, "https://unowned.example.org/code/repo")
+		require.NotContains(t, software, "publisherId")
+
+		id := software["id"].(string)
+
+		body := `{"aliases": ["https://1-a.example.org/code/repo/unowned"]}`
+		req, err := newTestRequest("PATCH", "/v1/software/"+id, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		assert.Equal(t, publisherID, dbValue(t, "software", "publisher_id", "id", id))
+	})
+}
+
+func TestSoftwareTrash(t *testing.T) {
+	const (
+		softwareID  = "6f4ab18d-0e5c-4b9c-93a7-8c1f5e2b4da6"
+		publisherID = "3c1d8e5a-7b2f-4e69-a0d4-5f8c2b9e1a73"
+	)
+
+	tests := []TestCase{
+		{
+			description:         "GET software in the trash",
+			query:               "GET /v1/software?deleted=true",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				software := assertListResponse(t, response)
+				require.Len(t, software, 1)
+
+				assert.Equal(t, softwareID, software[0]["id"])
+				assert.Equal(t, "https://29.example.org/code/repo", software[0]["url"])
+				assert.Equal(t, "2021-01-01T00:00:00Z", software[0]["deletedAt"])
+			},
+		},
+		{
+			description:         "GET software hides the software in the trash",
+			query:               "GET /v1/software?url=https://29.example.org/code/repo",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Empty(t, assertListResponse(t, response))
+			},
+		},
+		{
+			description:         "GET software in the trash by id",
+			query:               "GET /v1/software/" + softwareID,
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Software","detail":"Software was not found","status":404}`,
+		},
+		{
+			description: "DELETE software already in the trash",
+			query:       "DELETE /v1/software/" + softwareID,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't delete Software","detail":"Software was not found","status":404}`,
+		},
+		{
+			description: "PUT software with the URL of software in the trash",
+			query:       "PUT /v1/software?validation=off&url=https://29.example.org/code/repo",
+			body:        `{"publiccodeYml": "-", "url": "https://29.example.org/code/repo"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        409,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create or update Software","detail":"url belongs to Software in the trash","status":409}`,
+		},
+		{
+			description: "POST restore software",
+			query:       "POST /v1/software/" + softwareID + "/restore",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, softwareID, response["id"])
+				assert.Equal(t, "https://29.example.org/code/repo", response["url"])
+				assert.NotContains(t, response, "deletedAt")
+
+				// The code hosting of publishers in the trash doesn't own it
+				assert.NotContains(t, response, "publisherId")
+
+				assert.Equal(t, 0, dbCount(t, "software", "deleted_at IS NOT NULL AND id", softwareID))
+				assert.Equal(t, "update", dbValue(t, "events", "type", "entity_id", softwareID))
+			},
+		},
+		{
+			description: "POST restore software not in the trash",
+			query:       "POST /v1/software/c353756e-8597-4e46-a99b-7da2e141603b/restore",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't restore Software","detail":"Software was not found in the trash","status":404}`,
+		},
+		{
+			description:         "POST restore software with no token",
+			query:               "POST /v1/software/" + softwareID + "/restore",
+			expectedCode:        401,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"token authentication failed","status":401}`,
+		},
+	}
+
+	runTestCases(t, tests)
+
+	t.Run("DELETE and restore software", func(t *testing.T) {
+		loadFixtures(t)
+
+		const id = "c353756e-8597-4e46-a99b-7da2e141603b"
+
+		req, err := newTestRequest("DELETE", "/v1/software/"+id, nil)
+		require.NoError(t, err)
+		req.Header = map[string][]string{"Authorization": {goodToken}}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 204, res.StatusCode)
+
+		req, err = newTestRequest("POST", "/v1/software/"+id+"/restore", nil)
+		require.NoError(t, err)
+		req.Header = map[string][]string{"Authorization": {goodToken}}
+
+		res, err = app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		var software map[string]interface{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&software))
+
+		assert.Equal(t, "https://1-a.example.org/code/repo", software["url"])
+		assert.Equal(t, []interface{}{"https://1-b.example.org/code/repo"}, software["aliases"])
+		assert.Equal(t, "2ded32eb-c45e-4167-9166-a44e18b8adde", software["publisherId"])
+
+		// Deleted and restored
+		assert.Equal(t, "delete", dbValue(t, "revisions", "type", "version = 3 AND entity_id", id))
+		assert.Equal(t, "update", dbValue(t, "revisions", "type", "version = 4 AND entity_id", id))
+	})
+}
+
+func TestSoftwareMerge(t *testing.T) {
+	const (
+		softwareID  = "9f135268-a37e-4ead-96ec-e4a24bb9344a"
+		duplicateID = "c353756e-8597-4e46-a99b-7da2e141603b"
+	)
+
+	tests := []TestCase{
+		{
+			description: "POST merge software",
+			query:       "POST /v1/software/" + softwareID + "/merge",
+			body:        `{"id": "` + duplicateID + `"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, softwareID, response["id"])
+				assert.Equal(t, "https://2-a.example.org/code/repo", response["url"])
+				assert.Equal(t, []interface{}{
+					"https://1-a.example.org/code/repo",
+					"https://1-b.example.org/code/repo",
+					"https://2-b.example.org/code/repo",
+				}, response["aliases"])
+
+				assert.Equal(t, 0, dbCount(t, "software", "id", duplicateID))
+				assert.Equal(t, 0, dbCount(t, "software_descriptions", "software_id", duplicateID))
+				assert.Equal(t, 3, dbCount(t, "logs", "entity_id", softwareID))
+				assert.Equal(t, softwareID, dbValue(t, "redirects", "to_id", "from_id", duplicateID))
+
+				assert.Equal(t, 1, dbCount(t, "events", "type = 'delete' AND entity_id", duplicateID))
+				assert.Equal(t, 1, dbCount(t, "events", "type = 'update' AND entity_id", softwareID))
+				assert.Equal(t, "delete", dbValue(t, "revisions", "type", "version = 3 AND entity_id", duplicateID))
+			},
+		},
+		{
+			description: "POST merge software into itself",
+			query:       "POST /v1/software/" + softwareID + "/merge",
+			body:        `{"id": "` + softwareID + `"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't merge Software","detail":"can't merge into itself","status":422}`,
+		},
+		{
+			description: "POST merge non-existent duplicate",
+			query:       "POST /v1/software/" + softwareID + "/merge",
+			body:        `{"id": "eea19c82-0449-11ed-bd84-d8bbc146d165"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't merge Software","detail":"duplicate was not found","status":422}`,
+		},
+		{
+			description: "POST merge into non-existent software",
+			query:       "POST /v1/software/eea19c82-0449-11ed-bd84-d8bbc146d165/merge",
+			body:        `{"id": "` + duplicateID + `"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't merge Software","detail":"Software was not found","status":404}`,
+		},
+		{
+			description:         "POST merge software with no token",
+			query:               "POST /v1/software/" + softwareID + "/merge",
+			body:                `{"id": "` + duplicateID + `"}`,
+			expectedCode:        401,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"token authentication failed","status":401}`,
+		},
+	}
+
+	runTestCases(t, tests)
+
+	merge := func(t *testing.T, id string, duplicate string) map[string]interface{} {
+		t.Helper()
+
+		req, err := newTestRequest("POST", "/v1/software/"+id+"/merge", strings.NewReader(`{"id": "`+duplicate+`"}`))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, res.StatusCode)
+
+		var software map[string]interface{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&software))
+
+		return software
+	}
+
+	t.Run("GET merged software redirects", func(t *testing.T) {
+		loadFixtures(t)
+
+		merge(t, softwareID, duplicateID)
+
+		req, err := newTestRequest("GET", "/v1/software/"+duplicateID, nil)
+		require.NoError(t, err)
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		assert.Equal(t, 301, res.StatusCode)
+		assert.Equal(t, "/v1/software/"+softwareID, res.Header.Get("Location"))
+	})
+
+	t.Run("POST merge software moves webhooks", func(t *testing.T) {
+		loadFixtures(t)
+
+		merge(t, duplicateID, softwareID)
+
+		assert.Equal(t, 2, dbCount(t, "webhooks", "entity_id", duplicateID))
+		assert.Equal(t, 0, dbCount(t, "webhooks", "entity_id", softwareID))
+
+		// The vitality of the software merged is dropped
+		assert.Equal(t, 0, dbCount(t, "software_vitality", "software_id", softwareID))
+	})
+
+	t.Run("POST merge software moves analysis namespaces", func(t *testing.T) {
+		loadFixtures(t)
+
+		for id, analysis := range map[string]string{
+			softwareID:  `{"scanner":{"v":2},"security":{"v":1}}`,
+			duplicateID: `{"scanner":{"v":1},"licenses":{"v":1}}`,
+		} {
+			_, err := db.Exec("UPDATE software SET analysis = "+placeholder(1)+" WHERE id = "+placeholder(2), analysis, id)
+			require.NoError(t, err)
+		}
+
+		merge(t, softwareID, duplicateID)
+
+		var analysis map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(dbValue(t, "software", "analysis", "id", softwareID)), &analysis))
+
+		assert.Equal(t, map[string]interface{}{
+			"scanner":  map[string]interface{}{"v": float64(2)},
+			"security": map[string]interface{}{"v": float64(1)},
+			"licenses": map[string]interface{}{"v": float64(1)},
+		}, analysis)
+	})
+}
+
+func TestSoftwareURLRedirects(t *testing.T) {
+	const softwareID = "c353756e-8597-4e46-a99b-7da2e141603b"
+
+	getByURL := func(t *testing.T, url string) *http.Response {
+		t.Helper()
+
+		req, err := newTestRequest("GET", "/v1/software?url="+url, nil)
+		require.NoError(t, err)
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+
+		return res
+	}
+
+	send := func(t *testing.T, method string, path string, body string) {
+		t.Helper()
+
+		req, err := newTestRequest(method, path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Less(t, res.StatusCode, 300)
+	}
+
+	t.Run("GET software by a URL it doesn't have anymore redirects", func(t *testing.T) {
+		loadFixtures(t)
+
+		res := getByURL(t, "https://1-old.example.org/code/repo")
+		assert.Equal(t, 301, res.StatusCode)
+		assert.Equal(t, "/v1/software/"+softwareID, res.Header.Get("Location"))
+	})
+
+	t.Run("PATCH software redirects the URLs it drops", func(t *testing.T) {
+		loadFixtures(t)
+
+		send(t, "PATCH", "/v1/software/"+softwareID,
+			`{"url": "https://1-new.example.org/code/repo", "aliases": ["https://1-a.example.org/code/repo"]}`)
+
+		assert.Equal(t, softwareID, dbValue(t, "redirects", "to_id", "from_id", "https://1-b.example.org/code/repo"))
+		assert.Equal(t, 0, dbCount(t, "redirects", "from_id", "https://1-a.example.org/code/repo"))
+
+		res := getByURL(t, "https://1-b.example.org/code/repo")
+		assert.Equal(t, 301, res.StatusCode)
+		assert.Equal(t, "/v1/software/"+softwareID, res.Header.Get("Location"))
+	})
+
+	t.Run("PATCH software with a redirected URL drops the redirect", func(t *testing.T) {
+		loadFixtures(t)
+
+		send(t, "PATCH", "/v1/software/9f135268-a37e-4ead-96ec-e4a24bb9344a",
+			`{"aliases": ["https://2-b.example.org/code/repo", "https://1-old.example.org/code/repo"]}`)
+
+		assert.Equal(t, 0, dbCount(t, "redirects", "from_id", "https://1-old.example.org/code/repo"))
+	})
+
+	t.Run("POST software with a redirected URL drops the redirect", func(t *testing.T) {
+		loadFixtures(t)
+
+		send(t, "POST", "/v1/software?validation=off",
+			`{"publiccodeYml": "-", "url": "https://1-old.example.org/code/repo"}`)
+
+		assert.Equal(t, 0, dbCount(t, "redirects", "from_id", "https://1-old.example.org/code/repo"))
+	})
+
+	t.Run("POST merge software redirects the URLs of the duplicate", func(t *testing.T) {
+		loadFixtures(t)
+
+		const survivorID = "9f135268-a37e-4ead-96ec-e4a24bb9344a"
+
+		send(t, "POST", "/v1/software/"+survivorID+"/merge", `{"id": "`+softwareID+`"}`)
+
+		assert.Equal(t, survivorID, dbValue(t, "redirects", "to_id", "from_id", "https://1-old.example.org/code/repo"))
+	})
+}
+
+func TestSoftwareCatalogURLs(t *testing.T) {
+	send := func(t *testing.T, method string, path string, body string) (int, map[string]any) {
+		t.Helper()
+
+		req, err := newTestRequest(method, path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+
+		response := map[string]any{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+
+		return res.StatusCode, response
+	}
+
+	// Lists the software of the swiss catalog under url too
+	copyToSwiss := func(t *testing.T, url string) string {
+		t.Helper()
+
+		code, response := send(t, "POST", "/v1/catalogs/"+swissID+"/software?validation=off",
+			`{"publiccodeYml": "-", "url": "`+url+`"}`)
+		require.Equal(t, 200, code)
+
+		return response["id"].(string)
+	}
+
+	ids := func(response map[string]any) []string {
+		ids := []string{}
+		for _, software := range response["data"].([]any) {
+			ids = append(ids, software.(map[string]any)["id"].(string))
+		}
+
+		return ids
+	}
+
+	t.Run("GET software by URL lists the software of all the catalogs", func(t *testing.T) {
+		loadFixtures(t)
+
+		copyID := copyToSwiss(t, "https://3-a.example.org/code/repo")
+
+		code, response := send(t, "GET", "/v1/software?url=https://3-a.example.org/code/repo", "")
+		assert.Equal(t, 200, code)
+		assert.ElementsMatch(t, []string{rootSoftwareID, copyID}, ids(response))
+	})
+
+	t.Run("GET software copies lists the software of the other catalogs with its URLs", func(t *testing.T) {
+		loadFixtures(t)
+
+		copyID := copyToSwiss(t, "https://3-b.example.org/code/repo")
+
+		code, response := send(t, "GET", "/v1/software/"+rootSoftwareID+"/copies", "")
+		assert.Equal(t, 200, code)
+		assert.Equal(t, []string{copyID}, ids(response))
+
+		code, response = send(t, "GET", "/v1/software/"+copyID+"/copies", "")
+		assert.Equal(t, 200, code)
+		assert.Equal(t, []string{rootSoftwareID}, ids(response))
+
+		data := response["data"].([]any)
+		assert.Equal(t, "https://3-a.example.org/code/repo", data[0].(map[string]any)["url"])
+		assert.Equal(t, []any{"https://3-b.example.org/code/repo"}, data[0].(map[string]any)["aliases"])
+	})
+
+	t.Run("GET software copies of software without any", func(t *testing.T) {
+		loadFixtures(t)
+
+		code, response := send(t, "GET", "/v1/software/"+swissSoftwareID+"/copies", "")
+		assert.Equal(t, 200, code)
+		assert.Empty(t, response["data"])
+	})
+
+	t.Run("GET software copies of non-existent software", func(t *testing.T) {
+		code, response := send(t, "GET", "/v1/software/NO_SUCH_SOFTWARE/copies", "")
+		assert.Equal(t, 404, code)
+		assert.Equal(t, "Software was not found", response["detail"])
+	})
+
+	t.Run("PUT software with a URL of more catalogs updates the root one's", func(t *testing.T) {
+		loadFixtures(t)
+
+		copyToSwiss(t, "https://3-a.example.org/code/repo")
+
+		code, response := send(t, "PUT", "/v1/software?url=https://3-a.example.org/code/repo&validation=off",
+			`{"publiccodeYml": "-", "url": "https://3-a.example.org/code/repo"}`)
+		assert.Equal(t, 200, code)
+		assert.Equal(t, rootSoftwareID, response["id"])
+	})
+
+	t.Run("PUT software with a URL of more catalogs, none the root one", func(t *testing.T) {
+		loadFixtures(t)
+
+		copyToSwiss(t, "https://1-a.example.org/code/repo")
+
+		code, response := send(t, "PUT", "/v1/software?url=https://1-a.example.org/code/repo&validation=off",
+			`{"publiccodeYml": "-", "url": "https://1-a.example.org/code/repo"}`)
+		assert.Equal(t, 409, code)
+		assert.Equal(t, "url belongs to Software of more Catalogs", response["detail"])
+	})
+
+	t.Run("PATCH software dropping a URL another catalog has doesn't redirect it", func(t *testing.T) {
+		loadFixtures(t)
+
+		copyID := copyToSwiss(t, "https://3-a.example.org/code/repo")
+
+		code, _ := send(t, "PATCH", "/v1/software/"+rootSoftwareID, `{"url": "https://3-b.example.org/code/repo", "aliases": []}`)
+		require.Equal(t, 200, code)
+
+		code, response := send(t, "GET", "/v1/software?url=https://www.3-a.example.org/code/repo", "")
+		assert.Equal(t, 200, code)
+		assert.Equal(t, []string{copyID}, ids(response))
+	})
+
+	t.Run("POST merge software of another catalog with the same URLs", func(t *testing.T) {
+		loadFixtures(t)
+
+		copyID := copyToSwiss(t, "https://3-a.example.org/code/repo")
+
+		code, response := send(t, "POST", "/v1/software/"+rootSoftwareID+"/merge", `{"id": "`+copyID+`"}`)
+		assert.Equal(t, 200, code)
+		assert.Equal(t, "https://3-a.example.org/code/repo", response["url"])
+
+		assert.Equal(t, 1, dbCount(t, "software_urls", "url", "https://3-a.example.org/code/repo"))
+		assert.Equal(t, 0, dbCount(t, "software_urls", "software_id", copyID))
+	})
+}
+
+func TestSoftwareVitality(t *testing.T) {
+	const softwareID = "9f135268-a37e-4ead-96ec-e4a24bb9344a"
+
+	tests := []TestCase{
+		{
+			description:         "GET software vitality",
+			query:               "GET /v1/software/" + softwareID + "/vitality",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				points := assertListResponse(t, response)
+				require.Len(t, points, 4)
+
+				assert.Equal(t, map[string]interface{}{
+					"date":       "2014-05-13",
+					"score":      float64(90),
+					"components": map[string]interface{}{"codeActivity": float64(95), "userCommunity": float64(85)},
+				}, points[0])
+				assert.Equal(t, "2014-05-16", points[3]["date"])
+			},
+		},
+		{
+			description:         "GET software vitality in a range",
+			query:               "GET /v1/software/" + softwareID + "/vitality?from=2014-05-14&to=2014-05-15",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				points := assertListResponse(t, response)
+				require.Len(t, points, 2)
+
+				assert.Equal(t, "2014-05-14", points[0]["date"])
+				assert.Equal(t, float64(85), points[0]["score"])
+				assert.Equal(t, "2014-05-15", points[1]["date"])
+			},
+		},
+		{
+			description:         "GET software vitality with an invalid range",
+			query:               "GET /v1/software/" + softwareID + "/vitality?from=2014-05-14T00:00:00Z",
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Software vitality","detail":"from must be a date (YYYY-MM-DD)","status":422}`,
+		},
+		{
+			description:         "GET vitality of non-existent software",
+			query:               "GET /v1/software/NO_SUCH_SOFTWARE/vitality",
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Software vitality","detail":"Software was not found","status":404}`,
+		},
+		{
+			description: "POST software vitality",
+			query:       "POST /v1/software/" + softwareID + "/vitality",
+			body:        `{"data": [{"date": "2014-05-17", "score": 70, "components": {"codeActivity": 60}}, {"date": "2014-05-16", "score": 80}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				points := assertListResponse(t, response)
+				require.Len(t, points, 2)
+
+				assert.Equal(t, "2014-05-17", points[0]["date"])
+				assert.Equal(t, map[string]interface{}{"codeActivity": float64(60)}, points[0]["components"])
+
+				assert.Equal(t, 5, dbCount(t, "software_vitality", "software_id", softwareID))
+				assert.Equal(t, "80", dbValue(t, "software_vitality", "score", "date LIKE '2014-05-16%' AND software_id", softwareID))
+
+				// The vitality of the software is the latest score
+				assert.Equal(t, "70", dbValue(t, "software", "vitality_score", "id", softwareID))
+				assert.Equal(t, 1, dbCount(t, "events", "type = 'update' AND entity_id", softwareID))
+			},
+		},
+		{
+			description: "POST software vitality before the latest",
+			query:       "POST /v1/software/" + softwareID + "/vitality",
+			body:        `{"data": [{"date": "2014-05-01", "score": 10}, {"date": "2014-05-01", "score": 20}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				// The last data point of a date wins
+				assert.Len(t, assertListResponse(t, response), 1)
+				assert.Equal(t, "20", dbValue(t, "software_vitality", "score", "date LIKE '2014-05-01%' AND software_id", softwareID))
+
+				assert.Equal(t, "90", dbValue(t, "software", "vitality_score", "id", softwareID))
+				assert.Equal(t, 0, dbCount(t, "events", "type = 'update' AND entity_id", softwareID))
+			},
+		},
+		{
+			description: "POST software vitality with an invalid score",
+			query:       "POST /v1/software/" + softwareID + "/vitality",
+			body:        `{"data": [{"date": "2014-05-17", "score": 101}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Software vitality","detail":"invalid format: score does not meet its size limits (too long)","status":422,"validationErrors":[{"field":"score","rule":"max","value":""}]}`,
+		},
+		{
+			description: "POST software vitality with an invalid date",
+			query:       "POST /v1/software/" + softwareID + "/vitality",
+			body:        `{"data": [{"date": "17/05/2014", "score": 70}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Software vitality","detail":"invalid format: date is not a valid date","status":422,"validationErrors":[{"field":"date","rule":"datetime","value":"17/05/2014"}]}`,
+		},
+		{
+			description: "POST vitality of non-existent software",
+			query:       "POST /v1/software/NO_SUCH_SOFTWARE/vitality",
+			body:        `{"data": [{"date": "2014-05-17", "score": 70}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        404,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't create Software vitality","detail":"Software was not found","status":404}`,
+		},
+		{
+			description: "POST software vitality without authentication",
+			query:       "POST /v1/software/" + softwareID + "/vitality",
+			body:        `{"data": [{"date": "2014-05-17", "score": 70}]}`,
+			headers: map[string][]string{
+				"Content-Type": {"application/json"},
+			},
+			expectedCode:        401,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"token authentication failed","status":401}`,
+		},
+
+		// sort=vitality
+		{
+			description: "GET software sorted by vitality",
+			query:       "GET /v1/software?sort=vitality",
+			setupFunc: func(t *testing.T) {
+				_, err := db.Exec("UPDATE software SET vitality_score = 95 WHERE id = "+placeholder(1), "c353756e-8597-4e46-a99b-7da2e141603b")
+				require.NoError(t, err)
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				software := assertListResponse(t, response)
+				require.Greater(t, len(software), 2)
+
+				assert.Equal(t, "c353756e-8597-4e46-a99b-7da2e141603b", software[0]["id"])
+				assert.Equal(t, softwareID, software[1]["id"])
+				assert.Nil(t, software[2]["vitality"])
+			},
+		},
+		{
+			description:         "GET software sorted by vitality paginated",
+			query:               "GET /v1/software?sort=vitality&page[size]=1",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				data := assertListResponse(t, response)
+
+				require.Equal(t, 1, len(data))
+				assert.Equal(t, softwareID, data[0]["id"])
+
+				links := response["links"].(map[string]interface{})
+				require.IsType(t, "", links["next"])
+
+				req, err := newTestRequest("GET", "/v1/software"+links["next"].(string), nil)
+				require.NoError(t, err)
+
+				res, err := app.Test(req, -1)
+				require.NoError(t, err)
+
+				var next map[string]interface{}
+				require.NoError(t, json.NewDecoder(res.Body).Decode(&next))
+
+				data = assertListResponse(t, next)
+
+				require.Equal(t, 1, len(data))
+				assert.NotEqual(t, softwareID, data[0]["id"])
+				assert.Nil(t, data[0]["vitality"])
+			},
+		},
+		{
+			description:         "GET catalog software sorted by vitality",
+			query:               "GET /v1/catalogs/b9f6f7e8-1c2d-4f3b-9f4e-0d5c6b7a8f9e/software?sort=vitality",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				software := assertListResponse(t, response)
+				require.NotEmpty(t, software)
+
+				assert.Equal(t, softwareID, software[0]["id"])
+			},
+		},
+		{
+			description:         "GET software with an invalid sort",
+			query:               "GET /v1/software?sort=name",
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"title":"can't get Software","detail":"sort must be vitality","status":422}`,
+		},
+	}
+
+	runTestCases(t, tests)
+}
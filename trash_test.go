@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/italia/developers-italia-api/internal/database"
+	"github.com/italia/developers-italia-api/internal/trash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrashPurge(t *testing.T) {
+	const (
+		softwareID  = "6f4ab18d-0e5c-4b9c-93a7-8c1f5e2b4da6"
+		publisherID = "3c1d8e5a-7b2f-4e69-a0d4-5f8c2b9e1a73"
+	)
+
+	gormDB, err := database.NewDatabase(os.Getenv("DATABASE_DSN"))
+	require.NoError(t, err)
+
+	t.Run("purges what is in the trash for longer than the retention", func(t *testing.T) {
+		loadFixtures(t)
+
+		purged, err := trash.Purge(gormDB, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+
+		assert.Equal(t, trash.Purged{Software: 1, Publishers: 2, Catalogs: 1}, purged)
+
+		assert.Equal(t, 0, dbCount(t, "software", "id", softwareID))
+		assert.Equal(t, 0, dbCount(t, "software_urls", "software_id", softwareID))
+		assert.Equal(t, 0, dbCount(t, "redirects", "to_id", softwareID))
+		assert.Equal(t, 0, dbCount(t, "publishers", "id", publisherID))
+		assert.Equal(t, 0, dbCount(t, "publishers_code_hosting", "publisher_id", publisherID))
+		assert.Equal(t, 0, dbCount(t, "publishers", "id", trashedPublisherID))
+		assert.Equal(t, 0, dbCount(t, "catalogs", "id", trashedCatalogID))
+		assert.Equal(t, 0, dbCount(t, "catalog_sources", "catalog_id", trashedCatalogID))
+
+		// The URL can be used again
+		body := `{"publiccodeYml": "-", "url": "https://29.example.org/code/repo"}`
+		req, err := newTestRequest("POST", "/v1/software?validation=off", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header = map[string][]string{
+			"Authorization": {goodToken},
+			"Content-Type":  {"application/json"},
+		}
+
+		res, err := app.Test(req, -1)
+		require.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode)
+	})
+
+	t.Run("keeps what is in the trash for less than the retention", func(t *testing.T) {
+		loadFixtures(t)
+
+		purged, err := trash.Purge(gormDB, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+
+		assert.Equal(t, trash.Purged{}, purged)
+
+		assert.Equal(t, 1, dbCount(t, "software", "id", softwareID))
+		assert.Equal(t, 1, dbCount(t, "redirects", "to_id", softwareID))
+		assert.Equal(t, 1, dbCount(t, "publishers", "id", publisherID))
+		assert.Equal(t, 1, dbCount(t, "catalogs", "id", trashedCatalogID))
+	})
+
+	t.Run("keeps catalogs with resources still in the trash", func(t *testing.T) {
+		loadFixtures(t)
+
+		_, err := db.Exec(
+			"UPDATE software SET catalog_id = "+placeholder(1)+", deleted_at = "+placeholder(2)+" WHERE id = "+placeholder(3),
+			trashedCatalogID, time.Now(), softwareID,
+		)
+		require.NoError(t, err)
+
+		purged, err := trash.Purge(gormDB, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+
+		assert.Equal(t, trash.Purged{Publishers: 2}, purged)
+
+		assert.Equal(t, 1, dbCount(t, "catalogs", "id", trashedCatalogID))
+		assert.Equal(t, 1, dbCount(t, "catalog_sources", "catalog_id", trashedCatalogID))
+
+		// The software doesn't point to the purged publisher anymore
+		assert.Equal(t, 1, dbCount(t, "software", "publisher_id IS NULL AND id", softwareID))
+	})
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"database/sql"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestWebhooksEndpoints(t *testing.T) {
@@ -38,7 +40,99 @@ func TestWebhooksEndpoints(t *testing.T) {
 				assert.Equal(t, "https://example.org/receiver", response["url"])
 			},
 		},
+		{
+			description: "POST webhook with custom headers hides secret values",
+			query:       "POST /v1/software/webhooks",
+			body: `{"url": "https://example.org/receiver", "headers": [
+				{"name": "authorization", "value": "Bearer s3cr3t", "secret": true},
+				{"name": "X-Tenant", "value": "developers-italia"}
+			]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assertUUID(t, response["id"])
+
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{"name": "Authorization", "secret": true},
+					map[string]interface{}{"name": "X-Tenant", "value": "developers-italia", "secret": false},
+				}, response["headers"])
+
+				id := response["id"].(string)
+				assert.Equal(t, 2, dbCount(t, "webhook_headers", "webhook_id", id))
+
+				stored := dbValue(t, "webhook_headers", "value", "name", "Authorization")
+				assert.NotContains(t, stored, "s3cr3t", "secret header values must be stored encrypted")
+			},
+		},
+		{
+			description: "POST webhook with reserved header returns 422",
+			query:       "POST /v1/software/webhooks",
+			body:        `{"url": "https://example.org/receiver", "headers": [{"name": "Content-Type", "value": "text/plain"}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "can't create Webhook", response["title"])
+				assert.Equal(t, "invalid format: name is not a valid or allowed header name", response["detail"])
+			},
+		},
+		{
+			description: "POST webhook in batched mode",
+			query:       "POST /v1/software/webhooks",
+			body:        `{"url": "https://example.org/receiver", "batch": {"window": 60000, "maxEvents": 100}}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, map[string]interface{}{"window": 60000.0, "maxEvents": 100.0}, response["batch"])
+			},
+		},
+		{
+			description: "POST webhook with too short batch window returns 422",
+			query:       "POST /v1/software/webhooks",
+			body:        `{"url": "https://example.org/receiver", "batch": {"window": 10}}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "invalid format: window does not meet its size limits (too short)", response["detail"])
+			},
+		},
+		{
+			description: "POST webhook with maxEvents but no batch window returns 422",
+			query:       "POST /v1/software/webhooks",
+			body:        `{"url": "https://example.org/receiver", "batch": {"maxEvents": 100}}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        422,
+			expectedContentType: "application/problem+json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, "invalid format: window is required", response["detail"])
+			},
+		},
 		// GET /webhooks/:id
+		{
+			description:         "GET webhook with custom headers",
+			query:               "GET /v1/webhooks/e7f6dbda-c3f5-4b2f-b3d8-39a34026e60a",
+			expectedCode:        200,
+			expectedBody:        `{"id":"e7f6dbda-c3f5-4b2f-b3d8-39a34026e60a","url":"https://3-a.example.org/receiver","headers":[{"name":"Authorization","secret":true},{"name":"X-Tenant","value":"developers-italia","secret":false}],"createdAt":"2017-05-01T00:00:00Z","updatedAt":"2017-05-01T00:00:00Z"}`,
+			expectedContentType: "application/json",
+		},
 		{
 			query:               "GET /v1/webhooks/007bc84a-7e2d-43a0-b7e1-a256d4114aa7",
 			expectedCode:        200,
@@ -73,6 +167,73 @@ func TestWebhooksEndpoints(t *testing.T) {
 				assertOnlyKeys(t, response, "id", "url", "createdAt", "updatedAt")
 			},
 		},
+		{
+			description: "PATCH webhook replaces custom headers",
+			query:       "PATCH /v1/webhooks/e7f6dbda-c3f5-4b2f-b3d8-39a34026e60a",
+			body:        `{"url": "https://3-a.example.org/receiver", "headers": [{"name": "X-Api-Key", "value": "k3y", "secret": true}]}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{"name": "X-Api-Key", "secret": true},
+				}, response["headers"])
+
+				assert.Equal(t, 1, dbCount(t, "webhook_headers", "webhook_id", "e7f6dbda-c3f5-4b2f-b3d8-39a34026e60a"))
+			},
+		},
+		{
+			description: "PATCH webhook without headers keeps them",
+			query:       "PATCH /v1/webhooks/e7f6dbda-c3f5-4b2f-b3d8-39a34026e60a",
+			body:        `{"url": "https://3-a.example.org/new-receiver"}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Len(t, response["headers"], 2)
+				assert.Equal(t, 2, dbCount(t, "webhook_headers", "webhook_id", "e7f6dbda-c3f5-4b2f-b3d8-39a34026e60a"))
+			},
+		},
+		{
+			description: "PATCH webhook turns batched mode on",
+			query:       "PATCH /v1/webhooks/007bc84a-7e2d-43a0-b7e1-a256d4114aa7",
+			body:        `{"url": "https://1-b.example.org/receiver", "batch": {"window": 5000}}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, map[string]interface{}{"window": 5000.0}, response["batch"])
+			},
+		},
+		{
+			description: "PATCH webhook turns batched mode off",
+			query:       "PATCH /v1/webhooks/d6334000-69a8-43a1-ab43-50bb04e14eed",
+			body:        `{"url": "https://3-b.example.org/receiver", "batch": {"window": 0}}`,
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+				"Content-Type":  {"application/json"},
+			},
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			validateFunc: func(t *testing.T, response map[string]interface{}) {
+				assert.NotContains(t, response, "batch")
+
+				var batch sql.NullString
+				require.NoError(t, db.QueryRow(
+					"SELECT batch FROM webhooks WHERE id = "+placeholder(1), "d6334000-69a8-43a1-ab43-50bb04e14eed",
+				).Scan(&batch))
+				assert.False(t, batch.Valid, "batch must be cleared in the database")
+			},
+		},
 		{
 			description: "PATCH webhook with non-normalized URL",
 			query:       "PATCH /v1/webhooks/007bc84a-7e2d-43a0-b7e1-a256d4114aa7",
@@ -193,6 +354,21 @@ func TestWebhooksEndpoints(t *testing.T) {
 			expectedBody:        `{"title":"token authentication failed","status":401}`,
 			expectedContentType: "application/problem+json",
 		},
+		{
+			description: "DELETE webhook removes its custom headers",
+			query:       "DELETE /v1/webhooks/e7f6dbda-c3f5-4b2f-b3d8-39a34026e60a",
+			headers: map[string][]string{
+				"Authorization": {goodToken},
+			},
+			expectedCode:        204,
+			expectedBody:        "",
+			expectedContentType: "",
+			setupFunc: func(t *testing.T) {
+				t.Cleanup(func() {
+					assert.Equal(t, 0, dbCount(t, "webhook_headers", "webhook_id", "e7f6dbda-c3f5-4b2f-b3d8-39a34026e60a"))
+				})
+			},
+		},
 		{
 			query: "DELETE /v1/webhooks/24bc1b5d-fe81-47be-9d55-910f820bdd04",
 			headers: map[string][]string{